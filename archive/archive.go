@@ -0,0 +1,156 @@
+// Package archive extracts and packs MGSV:TPP archives and converts fox2 and lng2 files.
+// Nothing is read from or written to fixed locations, process is never exited:
+// every failure is returned as *Error.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+)
+
+// ErrCorrupt is returned by VerifyQar if archive has damaged entries.
+var ErrCorrupt = errors.New("corrupt entries found")
+
+// Error is an operation failure. Err is the cause, for example qar.ExtractErrors listing failed entries.
+type Error struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// FS receives extracted files.
+type FS interface {
+	// Create creates or truncates file, name is a slash separated entry path,
+	// leading slash is optional. Parent directories are created by implementation.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// DirFS is a directory on disk, entries cannot be written outside of it.
+type DirFS string
+
+func (d DirFS) Create(name string) (io.WriteCloser, error) {
+	// cleaning rooted path drops leading ".."
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(p, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0644)
+}
+
+// Progress is reported after each entry is processed.
+type Progress struct {
+	Path  string
+	Hash  uint64 // dat/qar entries only
+	Done  int
+	Total int
+	// Err is entry failure, extraction continues with other entries
+	Err error
+}
+
+// ProgressFunc receives progress, calls are never concurrent.
+type ProgressFunc func(p Progress)
+
+// progress counts processed entries and serializes calls to f
+type progress struct {
+	f     ProgressFunc
+	total int
+	done  int
+	lock  sync.Mutex
+}
+
+func newProgress(f ProgressFunc, total int) *progress {
+	return &progress{f: f, total: total}
+}
+
+func (p *progress) report(entryPath string, hash uint64, err error) {
+	if p.f == nil {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.done++
+	p.f(Progress{Path: entryPath, Hash: hash, Done: p.done, Total: p.total, Err: err})
+}
+
+// ExtractOptions configure ExtractQar and ExtractFpk.
+type ExtractOptions struct {
+	// Names resolves dat/qar entry names by hash, for example hashing.Dictionary.GetByHash.
+	// Unresolved entries are named by hash.
+	Names qar.Resolver
+	// Output receives extracted files, default is DirFS(UnpackedDir(path)).
+	Output FS
+	// Filter selects extracted entries, nil filter selects everything.
+	Filter *util.Filter
+	// Workers is the number of dat/qar goroutines, runtime.NumCPU() if less than 1.
+	Workers  int
+	Logger   *slog.Logger
+	Progress ProgressFunc
+}
+
+// PackOptions configure PackQar and PackFpk.
+type PackOptions struct {
+	// InputDir holds entry files named by entry paths.
+	InputDir string
+	// Workers is the number of dat/qar goroutines, runtime.NumCPU() if less than 1.
+	Workers int
+	// Source is the archive definition was extracted from, unmodified dat/qar entries are copied from it as is.
+	Source   *qar.Qar
+	Logger   *slog.Logger
+	Progress ProgressFunc
+}
+
+// logger returns l or logger discarding everything
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+
+	return l
+}
+
+// UnpackedDir returns default directory archive of format is extracted to:
+// file.dat is extracted to file_dat, file.fpkd to file_fpkd, extensionless file to file_<format>.
+func UnpackedDir(archivePath string, format detect.Format) string {
+	base := filepath.Base(archivePath)
+	if format == detect.Qar {
+		return filepath.Join(filepath.Dir(archivePath), strings.TrimSuffix(base, ".dat")+"_dat")
+	}
+
+	ext := filepath.Ext(base)
+	if ext == "" {
+		return filepath.Join(filepath.Dir(archivePath), base+"_"+string(format))
+	}
+
+	return filepath.Join(filepath.Dir(archivePath), strings.TrimSuffix(base, ext)+strings.ReplaceAll(ext, ".", "_"))
+}
+
+func wrap(op string, p string, format string, a ...any) error {
+	return &Error{Op: op, Path: p, Err: fmt.Errorf(format, a...)}
+}
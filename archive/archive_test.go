@@ -0,0 +1,250 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/unknown321/datfpk/fpk"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+)
+
+// memFS keeps extracted files in memory
+type memFS struct {
+	files map[string]*bytes.Buffer
+	lock  sync.Mutex
+}
+
+type memFile struct {
+	*bytes.Buffer
+}
+
+func (m memFile) Close() error {
+	return nil
+}
+
+func (m *memFS) Create(name string) (io.WriteCloser, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.files == nil {
+		m.files = map[string]*bytes.Buffer{}
+	}
+
+	b := &bytes.Buffer{}
+	m.files[name] = b
+
+	return memFile{b}, nil
+}
+
+func TestExtractQar(t *testing.T) {
+	output := &memFS{}
+	var progress []Progress
+
+	q, err := ExtractQar("../qar/testdata/plain.dat", ExtractOptions{
+		Output:   output,
+		Workers:  2,
+		Progress: func(p Progress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if len(q.Entries) != 1 || len(output.files) != 1 {
+		t.Fatalf("want 1 entry, have %d in definition, %d extracted", len(q.Entries), len(output.files))
+	}
+
+	data, ok := output.files[q.Entries[0].Header.FilePath]
+	if !ok {
+		t.Fatalf("entry %s not extracted", q.Entries[0].Header.FilePath)
+	}
+
+	if data.String() != "data1234567890\n" {
+		t.Errorf("unexpected data %q", data.String())
+	}
+
+	if len(progress) != 1 || progress[0].Done != 1 || progress[0].Total != 1 || progress[0].Err != nil {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+}
+
+func TestExtractQar_Errors(t *testing.T) {
+	_, err := ExtractQar("testdata/missing.dat", ExtractOptions{Output: &memFS{}})
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("want *Error, have %v", err)
+	}
+
+	if e.Op != "read" || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unexpected error %s", err.Error())
+	}
+}
+
+func TestExtractQar_DuplicatePaths(t *testing.T) {
+	q := &qar.Qar{Flags: 3150304, Version: 1}
+	copy(q.Magic[:], "SQAR")
+	q.Entries = []qar.Entry{
+		{Header: qar.EntryHeader{FilePath: "/Assets/a.lua"}, Data: []byte("a")},
+		{Header: qar.EntryHeader{FilePath: "/Assets/b.lua"}, Data: []byte("b")},
+	}
+
+	file := &util.ByteArrayReaderWriter{}
+	if err := q.Write(file, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	datPath := filepath.Join(t.TempDir(), "test.dat")
+	if err := os.WriteFile(datPath, file.Bytes(), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	// both entries resolve to the same path
+	output := &memFS{}
+	res, err := ExtractQar(datPath, ExtractOptions{
+		Output: output,
+		Names:  func(uint64) (string, bool) { return "/Assets/a.lua", true },
+	})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if len(output.files) != 2 {
+		t.Fatalf("want 2 extracted entries, have %d", len(output.files))
+	}
+
+	for i, want := range []string{"a", "b"} {
+		e := res.Entries[i]
+		if data, ok := output.files[e.Header.FilePath]; !ok || data.String() != want {
+			t.Errorf("entry %s: want %q, have %v", e.Header.FilePath, want, data)
+		}
+	}
+
+	if h := res.Entries[1].Header; h.NameHashForPacking != h.PathHash {
+		t.Errorf("renamed entry does not keep its hash, %+v", h)
+	}
+}
+
+func TestPackFpk(t *testing.T) {
+	dir := t.TempDir()
+
+	filter, err := util.NewFilter([]string{"*.lua"}, nil, nil)
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	def, err := ExtractFpk("../fpk/testdata/title.fpkd", ExtractOptions{Output: DirFS(dir)})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	def = def.Subset(filter)
+	var packed []string
+	out := util.NewByteArrayReaderWriter(nil)
+	err = PackFpk(def, out, PackOptions{
+		InputDir: dir,
+		Progress: func(p Progress) { packed = append(packed, p.Path) },
+	})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if len(packed) == 0 || len(packed) != len(def.Entries) {
+		t.Fatalf("want progress for %d entries, have %v", len(def.Entries), packed)
+	}
+
+	f := fpk.Fpk{}
+	if err = f.Read(bytes.NewReader(out.Bytes()), false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, p := range packed {
+		want, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		have := util.NewByteArrayReaderWriter(nil)
+		if err = f.ExtractTo(p, have); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if !bytes.Equal(want, have.Bytes()) {
+			t.Errorf("%s: data mismatch", p)
+		}
+	}
+}
+
+func TestVerifyQar(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("../qar/testdata/plain.dat")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	good := filepath.Join(dir, "good.dat")
+	if err = os.WriteFile(good, data, 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	report, err := VerifyQar(good, nil)
+	if err != nil || !report.OK() {
+		t.Fatalf("unexpected result %v, %v", report, err)
+	}
+
+	// damage entry data
+	q := qar.Qar{}
+	if err = q.Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	data[q.Entries[0].Header.DataOffset+4] ^= 0xFF
+
+	bad := filepath.Join(dir, "bad.dat")
+	if err = os.WriteFile(bad, data, 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	report, err = VerifyQar(bad, nil)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("want ErrCorrupt, have %v", err)
+	}
+
+	if report == nil || len(report.Corrupt) != 1 {
+		t.Errorf("want 1 corrupt entry, have %v", report)
+	}
+}
+
+func TestDirFS_Create(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"/Assets/a.lua", "../../b.lua", "c/../../../c.lua"} {
+		w, err := DirFS(dir).Create(name)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+		_ = w.Close()
+	}
+
+	var have []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			have = append(have, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	want := []string{"Assets/a.lua", "b.lua", "c.lua"}
+	slices.Sort(have)
+	if !slices.Equal(have, want) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
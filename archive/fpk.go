@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/fpk"
+)
+
+// ExtractFpk extracts fpk/fpkd entries selected by opts.Filter to opts.Output and returns definition
+// describing every entry. Extraction stops at first failed entry.
+func ExtractFpk(fpkPath string, opts ExtractOptions) (*fpk.Fpk, error) {
+	log := logger(opts.Logger)
+
+	f := &fpk.Fpk{}
+	if err := f.ReadFrom(fpkPath, false); err != nil {
+		return nil, &Error{Op: "read", Path: fpkPath, Err: err}
+	}
+	defer f.Close()
+
+	log.Info("fpk", "fileCount", len(f.Entries), "references", len(f.References))
+
+	output := opts.Output
+	if output == nil {
+		format := detect.Fpk
+		if f.Header.IsFpkd() {
+			format = detect.Fpkd
+		}
+		output = DirFS(UnpackedDir(fpkPath, format))
+	}
+
+	subset := f.Subset(opts.Filter)
+	p := newProgress(opts.Progress, len(subset.Entries))
+	for _, e := range subset.Entries {
+		entryPath := e.FilePath.Data
+		err := extractFpkEntry(f, entryPath, output)
+		p.report(entryPath, 0, err)
+		if err != nil {
+			return nil, wrap("extract", fpkPath, "%s: %w", entryPath, err)
+		}
+	}
+
+	return f, nil
+}
+
+func extractFpkEntry(f *fpk.Fpk, entryPath string, output FS) error {
+	w, err := output.Create(entryPath)
+	if err != nil {
+		return err
+	}
+
+	if err = f.ExtractTo(entryPath, w); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// ReadFpkDefinition reads definition saved by ExtractFpk.
+func ReadFpkDefinition(definitionPath string) (*fpk.Fpk, error) {
+	data, err := os.ReadFile(definitionPath)
+	if err != nil {
+		return nil, &Error{Op: "read definition", Path: definitionPath, Err: err}
+	}
+
+	f := &fpk.Fpk{}
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, &Error{Op: "read definition", Path: definitionPath, Err: err}
+	}
+
+	return f, nil
+}
+
+// PackFpk packs entries of definition from opts.InputDir to out, opts.Workers and opts.Source are not used.
+func PackFpk(def *fpk.Fpk, out io.ReadWriteSeeker, opts PackOptions) error {
+	log := logger(opts.Logger)
+	log.Info("fpk", "fileCount", len(def.Entries), "references", len(def.References))
+
+	p := newProgress(opts.Progress, len(def.Entries))
+	def.OnWrite = func(e *fpk.Entry) {
+		p.report(e.FilePath.Data, 0, nil)
+	}
+	defer func() {
+		def.OnWrite = nil
+	}()
+
+	if err := def.Write(out, opts.InputDir, false); err != nil {
+		return &Error{Op: "pack", Path: opts.InputDir, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package archive
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/qar"
+
+	"github.com/unknown321/hashing"
+)
+
+// ExtractQar extracts dat/qar entries selected by opts.Filter to opts.Output and returns definition
+// describing every entry. Failed entries do not stop extraction, they are listed in qar.ExtractErrors
+// wrapped in returned *Error, definition is returned with it.
+func ExtractQar(qarPath string, opts ExtractOptions) (*qar.Qar, error) {
+	log := logger(opts.Logger)
+
+	q := &qar.Qar{}
+	if err := q.ReadFrom(qarPath); err != nil {
+		return nil, &Error{Op: "read", Path: qarPath, Err: err}
+	}
+	defer q.Close()
+
+	log.Info("QAR",
+		"version", q.Version,
+		"flags", q.Flags,
+		"file count", q.FileCount,
+		"first file offset", q.OffsetFirstFile,
+		"block file end", q.BlockFileEnd,
+		"entries", len(q.Entries))
+
+	names := opts.Names
+	if names == nil {
+		names = (&hashing.Dictionary{}).GetByHash
+	}
+	// entries sharing a path are extracted under paths with their hash
+	if err := q.Resolve(names); err != nil {
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			log.Warn("duplicate path", "path", qarPath, "error", e.Error())
+		}
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = DirFS(UnpackedDir(qarPath, detect.Qar))
+	}
+
+	total := 0
+	for _, e := range q.Entries {
+		if opts.Filter.Match(e.Header.FilePath, e.Header.PathHash) {
+			total++
+		}
+	}
+
+	p := newProgress(opts.Progress, total)
+	err := q.ExtractWith(opts.Workers, opts.Filter, func(e *qar.Entry) (io.WriteCloser, error) {
+		return output.Create(e.Header.FilePath)
+	}, func(e *qar.Entry, err error) {
+		p.report(e.Header.FilePath, e.Header.PathHash, err)
+	})
+	if err != nil {
+		return q, &Error{Op: "extract", Path: qarPath, Err: err}
+	}
+
+	return q, nil
+}
+
+// ReadQarDefinition reads definition saved by ExtractQar.
+func ReadQarDefinition(definitionPath string) (*qar.Qar, error) {
+	data, err := os.ReadFile(definitionPath)
+	if err != nil {
+		return nil, &Error{Op: "read definition", Path: definitionPath, Err: err}
+	}
+
+	q := &qar.Qar{}
+	if err = json.Unmarshal(data, q); err != nil {
+		return nil, &Error{Op: "read definition", Path: definitionPath, Err: err}
+	}
+
+	return q, nil
+}
+
+// PackQar packs entries of definition from opts.InputDir to out.
+func PackQar(def *qar.Qar, out io.ReadWriteSeeker, opts PackOptions) error {
+	log := logger(opts.Logger)
+	log.Info("QAR", "version", def.Version, "filecount", len(def.Entries), "flags", def.Flags)
+
+	p := newProgress(opts.Progress, len(def.Entries))
+	def.Source = opts.Source
+	def.OnWrite = func(e *qar.Entry) {
+		p.report(e.Header.FilePath, e.Header.PathHash, nil)
+	}
+	defer func() {
+		def.OnWrite = nil
+	}()
+
+	if err := def.WriteParallel(out, opts.InputDir, false, opts.Workers); err != nil {
+		return &Error{Op: "pack", Path: opts.InputDir, Err: err}
+	}
+
+	return nil
+}
+
+// VerifyQar checks md5 sums and sizes of dat/qar entries, see qar.Verify. Names are used in report only.
+// If there are damaged entries, report is returned with error wrapping ErrCorrupt.
+func VerifyQar(qarPath string, names qar.Resolver) (*qar.VerifyReport, error) {
+	q := &qar.Qar{}
+	if err := q.ReadFrom(qarPath); err != nil {
+		return nil, &Error{Op: "read", Path: qarPath, Err: err}
+	}
+	defer q.Close()
+
+	// names are only reported, entries sharing a path are verified separately
+	if names != nil {
+		_ = q.Resolve(names)
+	}
+
+	report, err := q.Verify()
+	if err != nil {
+		return nil, &Error{Op: "verify", Path: qarPath, Err: err}
+	}
+
+	if !report.OK() {
+		return report, wrap("verify", qarPath, "%w: %d of %d entries", ErrCorrupt, len(report.Corrupt), report.Entries)
+	}
+
+	return report, nil
+}
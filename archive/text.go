@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"io"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
+	"github.com/unknown321/datfpk/lng"
+)
+
+// DecompileFox2 converts fox2 to xml, hashes missing from fox2 string table are resolved using names, if set.
+func DecompileFox2(r io.ReadSeeker, names fox.Names, w io.Writer) error {
+	f := &fox2.Fox2{}
+	if err := f.Read(r, names); err != nil {
+		return &Error{Op: "read fox2", Err: err}
+	}
+
+	if err := f.ToXML(w); err != nil {
+		return &Error{Op: "write fox2 xml", Err: err}
+	}
+
+	return nil
+}
+
+// Fox2Options control fox2 compilation
+type Fox2Options struct {
+	// Schema fills missing static properties with defaults, nil disables filling.
+	Schema *schema.Registry
+	// Validate checks entities against Schema.
+	Validate bool
+	// Strict makes classes missing from Schema a validation error.
+	Strict bool
+	// Renumber assigns sequential addresses and IDs to every entity, see fox2.Fox2.Renumber.
+	Renumber bool
+}
+
+// CompileFox2 converts xml made by DecompileFox2 back to fox2. Entities without addresses and IDs get new ones.
+func CompileFox2(r io.Reader, opts Fox2Options, w io.WriteSeeker) error {
+	f := &fox2.Fox2{Schema: opts.Schema, CheckSchema: opts.Validate, StrictSchema: opts.Strict}
+	if err := f.FromXML(r); err != nil {
+		return &Error{Op: "read fox2 xml", Err: err}
+	}
+
+	if opts.Renumber {
+		if err := f.Renumber(); err != nil {
+			return &Error{Op: "renumber fox2", Err: err}
+		}
+	}
+
+	if err := f.Write(w); err != nil {
+		return &Error{Op: "write fox2", Err: err}
+	}
+
+	return nil
+}
+
+// DecompileLng converts lng2 to json, keys are resolved using dict.
+func DecompileLng(r io.ReadSeeker, dict dictionary.DictStrCode64, w io.Writer) error {
+	l := &lng.Lng{}
+	if err := l.Read(r, dict); err != nil {
+		return &Error{Op: "read lng", Err: err}
+	}
+
+	data, err := l.MarshalJSON()
+	if err != nil {
+		return &Error{Op: "write lng json", Err: err}
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return &Error{Op: "write lng json", Err: err}
+	}
+
+	return nil
+}
+
+// CompileLng converts json made by DecompileLng back to lng2.
+func CompileLng(r io.Reader, w io.WriteSeeker) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return &Error{Op: "read lng json", Err: err}
+	}
+
+	l := &lng.Lng{}
+	if err = l.UnmarshalJSON(data); err != nil {
+		return &Error{Op: "read lng json", Err: err}
+	}
+
+	if err = l.Write(w); err != nil {
+		return &Error{Op: "write lng", Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,501 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/unknown321/datfpk/archive"
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/util"
+)
+
+// Exit codes returned by Main
+const (
+	ExitOK        = 0
+	ExitError     = 1 // operation failed
+	ExitUsage     = 2 // bad command line
+	ExitCorrupt   = 3 // verify found corrupt entries
+	ExitDifferent = 4 // diff found differences
+	ExitInvalid   = 5 // validate found invalid entities or references
+)
+
+var (
+	ErrCorrupt   = archive.ErrCorrupt
+	ErrDifferent = errors.New("files differ")
+	ErrInvalid   = errors.New("validation failed")
+)
+
+// usageError is a command line error, command usage is printed after it
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, a ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+type command struct {
+	name        string
+	args        string
+	description string
+	// setup registers command flags and returns function running command with positional arguments
+	setup func(fs *flag.FlagSet, program string) func(args []string) error
+}
+
+var commands = []command{
+	{name: "unpack", args: "<file>", description: "Extract dat/qar or fpk/fpkd archive, decompile fox2 or lng2 file.", setup: setupUnpack},
+	{name: "pack", args: "<definition>", description: "Pack archive from json definition, compile fox2 xml or lng2 json.", setup: setupPack},
+	{name: "list", args: "<archive> [dictionary.txt]", description: "List dat/qar or fpk/fpkd entries without extracting.", setup: setupList},
+	{name: "verify", args: "<file.dat> [dictionary.txt]", description: "Verify md5 sums and sizes of dat/qar entries.", setup: setupVerify},
+	{name: "patch", args: "<file.dat>", description: "Replace, add or remove dat/qar entries in place.", setup: setupPatch},
+	{name: "hash", args: "<string>...", description: "Print hashes of strings and file paths.", setup: setupHash},
+	{name: "diff", args: "<a> <b>", description: "Compare entries of two dat/qar or fpk/fpkd archives by content.", setup: setupDiff},
+	{name: "info", args: "<file>", description: "Print detected format and header fields of a file.", setup: setupInfo},
+	{name: "resolve", args: "<file.dat>", description: "Recover paths of dat/qar entries missing from dictionary.", setup: setupResolve},
+	{name: "validate", args: "<file.fox2>...", description: "Check fox2 entities against class definitions and references between them.", setup: setupValidate},
+	{name: "graph", args: "<file.fox2>", description: "Print entity reference graph of fox2 file as Graphviz dot or json.", setup: setupGraph},
+	{name: "merge", args: "<target.fox2> <source.fox2> <out>", description: "Copy fox2 entities with their dependencies into another fox2 file.", setup: setupMerge},
+	{name: "split", args: "<file.fox2> <out>", description: "Extract fox2 entities with their dependencies into a new fox2 file.", setup: setupSplit},
+	{name: "harvest", args: "<dir>...", description: "Grow qar and fox2 dictionaries with strings from extracted files.", setup: setupHarvest},
+}
+
+func Run() {
+	os.Exit(Main(os.Args))
+}
+
+// Main runs command line, args[0] is the program name. Exit code is returned.
+// Arguments not starting with a command name are handled by short syntax.
+func Main(args []string) int {
+	program := args[0]
+	if len(args) > 1 {
+		for _, c := range commands {
+			if c.name == args[1] {
+				return c.run(program, args[2:])
+			}
+		}
+
+		if args[1] == "help" {
+			if len(args) > 2 {
+				for _, c := range commands {
+					if c.name == args[2] {
+						return c.run(program, []string{"-help"})
+					}
+				}
+			}
+
+			fs, _ := newShortFlags(program)
+			fs.Usage()
+			return ExitOK
+		}
+	}
+
+	return runShort(program, args[1:])
+}
+
+func (c *command) run(program string, args []string) int {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		c.usage(fs.Output(), fs, program)
+	}
+
+	run := c.setup(fs, program)
+	positional, err := parseSubcommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	// flag package has already printed error and usage
+	if err != nil {
+		return ExitUsage
+	}
+
+	if err = run(positional); err != nil {
+		return exitCode(c.name, err, fs.Usage)
+	}
+
+	return ExitOK
+}
+
+func (c *command) usage(w io.Writer, fs *flag.FlagSet, program string) {
+	fmt.Fprintf(w, "Usage: %s %s [flags] %s\n", program, c.name, c.args)
+	fmt.Fprintln(w, c.description)
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		fs.PrintDefaults()
+	}
+}
+
+// exitCode logs err and converts it to exit code, usage is printed for usage errors
+func exitCode(name string, err error, usage func()) int {
+	var ue *usageError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &ue):
+		slog.Error(name, "error", ue.msg)
+		usage()
+		return ExitUsage
+	case errors.Is(err, ErrDifferent):
+		return ExitDifferent
+	case errors.Is(err, ErrCorrupt):
+		slog.Error(name+" failed", "error", err.Error())
+		return ExitCorrupt
+	case errors.Is(err, ErrInvalid):
+		slog.Error(name+" failed", "error", err.Error())
+		return ExitInvalid
+	}
+
+	slog.Error(name+" failed", "error", err.Error())
+	return ExitError
+}
+
+// defaultDictionary returns path to dictionary placed next to executable
+func defaultDictionary(program string, name string) string {
+	exePath, err := filepath.Abs(program)
+	if err != nil {
+		return name
+	}
+
+	return filepath.Join(filepath.Dir(exePath), name)
+}
+
+type unpackOptions struct {
+	path      string
+	out       string
+	dict      *dictFlags
+	workers   int
+	recursive bool
+	subset    bool
+	filter    *util.Filter
+}
+
+// unpack extracts archive or decompiles file depending on its format
+func unpack(o unpackOptions) error {
+	format, err := detect.Guess(o.path)
+	if err != nil {
+		return fmt.Errorf("cannot detect file format of %s: %w", o.path, err)
+	}
+
+	var namespaces []dictionary.Namespace
+	switch {
+	case o.recursive:
+		namespaces = []dictionary.Namespace{dictionary.PathCode64, dictionary.StrCode32, dictionary.StrCode64}
+	case format == detect.Qar:
+		namespaces = []dictionary.Namespace{dictionary.PathCode64}
+	case format == detect.Lng:
+		namespaces = []dictionary.Namespace{dictionary.StrCode32}
+	case format == detect.Fox2:
+		namespaces = []dictionary.Namespace{dictionary.StrCode64}
+	}
+
+	dict, err := o.dict.load(namespaces...)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case detect.Qar:
+		if err = ExtractQar(o.path, dict, o.out, o.workers, o.filter, o.subset); err != nil {
+			return fmt.Errorf("extract: %w", err)
+		}
+	case detect.Fpk, detect.Fpkd:
+		slog.Info("extracting fpk(d)")
+		if err = ExtractFpk(o.path, o.out, o.filter, o.subset); err != nil {
+			return fmt.Errorf("extract: %w", err)
+		}
+	case detect.Fox2:
+		slog.Info("decompiling fox2")
+		if err = DecompileFox2(o.path, dict.Fox(), o.out); err != nil {
+			return fmt.Errorf("fox2 decompilation: %w", err)
+		}
+		return nil
+	case detect.Lng:
+		slog.Info("decompiling lng")
+		if err = DecompileLng(o.path, dict.Lng(), o.out); err != nil {
+			return fmt.Errorf("lng decompilation: %w", err)
+		}
+		return nil
+	case detect.Unknown:
+		return fmt.Errorf("unknown file format: %s", o.path)
+	default:
+		return usagef("%s is a %s, use pack", o.path, format)
+	}
+
+	if o.recursive {
+		outDir := o.out
+		if outDir == "" {
+			outDir = archive.UnpackedDir(o.path, format)
+		}
+
+		if err = ExtractRecursive(outDir, dict, o.workers); err != nil {
+			return fmt.Errorf("recursive extract: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type packOptions struct {
+	path        string
+	out         string
+	inputDir    string
+	workers     int
+	recursive   bool
+	passthrough bool
+	schema      *schemaFlags
+	renumber    bool
+}
+
+// fox2Options loads class definitions for fox2 compilation
+func (o packOptions) fox2Options() (archive.Fox2Options, error) {
+	reg, err := o.schema.load()
+	return archive.Fox2Options{Schema: reg, Validate: o.schema.validate || o.schema.strict, Strict: o.schema.strict, Renumber: o.renumber}, err
+}
+
+// pack packs archive from definition or compiles file depending on format of definition
+func pack(o packOptions) error {
+	format, err := detect.Guess(o.path)
+	if err != nil {
+		return fmt.Errorf("cannot detect file format of %s: %w", o.path, err)
+	}
+
+	switch format {
+	case detect.QarDefinition, detect.FpkDefinition, detect.FpkdDefinition:
+		if o.recursive {
+			dir := o.inputDir
+			if dir == "" {
+				dir = archive.UnpackedDir(strings.TrimSuffix(o.path, ".json"), format.Binary())
+			}
+
+			fox2Opts, err := o.fox2Options()
+			if err != nil {
+				return err
+			}
+
+			if err = PackRecursive(dir, fox2Opts, o.workers, o.passthrough); err != nil {
+				return fmt.Errorf("recursive pack: %w", err)
+			}
+		}
+
+		if format == detect.QarDefinition {
+			err = PackQar(o.path, o.out, o.inputDir, o.workers, o.passthrough)
+		} else {
+			slog.Info("fpk")
+			err = PackFpk(o.path, o.out, o.inputDir)
+		}
+
+		if err != nil {
+			return fmt.Errorf("pack: %w", err)
+		}
+	case detect.LngDefinition:
+		slog.Info("compiling lng")
+		if err = CompileLng(o.path, o.out); err != nil {
+			return fmt.Errorf("lng compilation: %w", err)
+		}
+	case detect.Fox2XML:
+		slog.Info("compiling fox2")
+		fox2Opts, err := o.fox2Options()
+		if err != nil {
+			return err
+		}
+
+		if err = CompileFox2(o.path, fox2Opts, o.out); err != nil {
+			return fmt.Errorf("fox2 compilation: %w", err)
+		}
+	case detect.Unknown:
+		return fmt.Errorf("unknown file format: %s", o.path)
+	default:
+		return usagef("%s is a %s file, use unpack", o.path, format)
+	}
+
+	return nil
+}
+
+func addWorkersFlag(fs *flag.FlagSet) *int {
+	return fs.Int("j", runtime.NumCPU(), "number of parallel dat/qar workers")
+}
+
+func setupUnpack(fs *flag.FlagSet, program string) func(args []string) error {
+	o := unpackOptions{}
+	fs.StringVar(&o.out, "out", "", "output directory, output file for fox2 and lng2 (default <filename>_<extension>/)")
+	o.dict = addDictFlags(fs, program)
+	addLngDictFlag(fs, o.dict)
+	addFoxDictFlag(fs, o.dict)
+	fs.BoolVar(&o.recursive, "recursive", false, "unpack nested containers found by magic")
+	fs.BoolVar(&o.subset, "subset", false, "save definition of extracted entries only")
+	workers := addWorkersFlag(fs)
+	filterArgs := addFilterFlags(fs)
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 file, got %d arguments", len(args))
+		}
+
+		var err error
+		if o.filter, err = filterArgs.filter(); err != nil {
+			return usagef("bad filter: %s", err.Error())
+		}
+
+		o.path = args[0]
+		o.workers = *workers
+
+		return unpack(o)
+	}
+}
+
+func setupPack(fs *flag.FlagSet, _ string) func(args []string) error {
+	o := packOptions{}
+	fs.StringVar(&o.out, "out", "", "output file (default is definition path without extension)")
+	fs.StringVar(&o.inputDir, "in", "", "input directory path (default <jsonFilename>_<extension>/)")
+	fs.BoolVar(&o.recursive, "recursive", false, "pack changed nested containers unpacked with unpack -recursive bottom-up")
+	fs.BoolVar(&o.passthrough, "passthrough", false, "pack unmodified dat/qar entries from original archive as is")
+	o.schema = addSchemaFlags(fs, true)
+	fs.BoolVar(&o.renumber, "renumber", false, "assign sequential addresses and ids to every fox2 entity")
+	workers := addWorkersFlag(fs)
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 definition, got %d arguments", len(args))
+		}
+
+		o.path = args[0]
+		o.workers = *workers
+
+		return pack(o)
+	}
+}
+
+// dictionaryArg returns file path and loads qar dictionary, optional positional dictionary has priority over flags
+func dictionaryArg(args []string, dicts *dictFlags) (string, *dictionary.Manager, error) {
+	switch len(args) {
+	case 1:
+	case 2:
+		dicts.prepend(dictionary.PathCode64, args[1])
+	default:
+		return "", nil, usagef("want file and optional dictionary, got %d arguments", len(args))
+	}
+
+	dict, err := dicts.load(dictionary.PathCode64)
+	return args[0], dict, err
+}
+
+func setupList(fs *flag.FlagSet, program string) func(args []string) error {
+	dicts := addDictFlags(fs, program)
+	format := fs.String("format", FormatTable, "output format: table, json or csv")
+	filterArgs := addFilterFlags(fs)
+
+	return func(args []string) error {
+		path, dict, err := dictionaryArg(args, dicts)
+		if err != nil {
+			return err
+		}
+
+		filter, err := filterArgs.filter()
+		if err != nil {
+			return usagef("bad filter: %s", err.Error())
+		}
+
+		return ListArchive(path, dict, *format, filter, os.Stdout)
+	}
+}
+
+func setupVerify(fs *flag.FlagSet, program string) func(args []string) error {
+	dicts := addDictFlags(fs, program)
+
+	return func(args []string) error {
+		path, dict, err := dictionaryArg(args, dicts)
+		if err != nil {
+			return err
+		}
+
+		return VerifyQar(path, dict)
+	}
+}
+
+func setupPatch(fs *flag.FlagSet, _ string) func(args []string) error {
+	var replace, add, remove stringList
+	fs.Var(&replace, "replace", "replace entry, archivePath=localPath, repeatable")
+	fs.Var(&add, "add", "add entry, archivePath=localPath, repeatable")
+	fs.Var(&remove, "remove", "remove entry by archive path, repeatable")
+	compact := fs.Bool("compact", false, "reclaim space left by replaced and removed entries")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 dat/qar file, got %d arguments", len(args))
+		}
+
+		return PatchQar(args[0], replace, add, remove, *compact)
+	}
+}
+
+func setupHash(fs *flag.FlagSet, _ string) func(args []string) error {
+	format := fs.String("format", FormatTable, "output format: table, json or csv")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return usagef("want at least 1 string")
+		}
+
+		return PrintHashes(args, *format, os.Stdout)
+	}
+}
+
+func setupDiff(fs *flag.FlagSet, program string) func(args []string) error {
+	dicts := addDictFlags(fs, program)
+	format := fs.String("format", FormatTable, "output format: table, json or csv")
+
+	return func(args []string) error {
+		if len(args) != 2 {
+			return usagef("want 2 archives, got %d arguments", len(args))
+		}
+
+		dict, err := dicts.load(dictionary.PathCode64)
+		if err != nil {
+			return err
+		}
+
+		changes, err := DiffArchives(args[0], args[1], dict)
+		if err != nil {
+			return err
+		}
+
+		if err = writeDiff(changes, *format, os.Stdout); err != nil {
+			return err
+		}
+
+		if len(changes) > 0 {
+			return fmt.Errorf("%w: %d entries", ErrDifferent, len(changes))
+		}
+
+		return nil
+	}
+}
+
+func setupInfo(fs *flag.FlagSet, _ string) func(args []string) error {
+	format := fs.String("format", FormatTable, "output format: table or json")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 file, got %d arguments", len(args))
+		}
+
+		return PrintInfo(args[0], *format, os.Stdout)
+	}
+}
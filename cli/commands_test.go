@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPack_FillDefaults(t *testing.T) {
+	data, err := os.ReadFile("../fox2/testdata/game/player2_add_parts_prqst_x1.fox2.xml")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	// TexturePackLoadConditioner with name only
+	sparse := string(data)
+	for _, p := range []string{
+		`        <property name="dataSet" type="EntityHandle" container="StaticArray" arraySize="1">
+          <containerEntry>0x6BB71D0</containerEntry>
+        </property>
+`,
+		`        <property name="texturePackPath" type="Path" container="StaticArray" arraySize="1">
+          <containerEntry hash="0xB8A0BF169F98"></containerEntry>
+        </property>
+`,
+	} {
+		if !strings.Contains(sparse, p) {
+			t.Fatalf("no property to remove")
+		}
+		sparse = strings.Replace(sparse, p, "", 1)
+	}
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "sparse.fox2.xml")
+	out := filepath.Join(dir, "sparse.fox2")
+	if err = os.WriteFile(in, []byte(sparse), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if code := Main([]string{"datfpk", "pack", "-out", out, in}); code != ExitOK {
+		t.Fatalf("pack: exit code %d", code)
+	}
+
+	if code := Main([]string{"datfpk", "validate", out}); code != ExitOK {
+		t.Fatalf("validate: exit code %d", code)
+	}
+}
+
+func TestValidate_Strict(t *testing.T) {
+	data, err := os.ReadFile("../fox2/testdata/game/player2_add_parts_prqst_x1.fox2.xml")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	unknown := strings.ReplaceAll(string(data), `class="TppPlayer2AdditionalPartsBlockData"`, `class="NoSuchClass"`)
+	if unknown == string(data) {
+		t.Fatalf("no class to replace")
+	}
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "unknown.fox2.xml")
+	if err = os.WriteFile(in, []byte(unknown), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"validate", in}, want: ExitOK},
+		{args: []string{"validate", "-strict", in}, want: ExitInvalid},
+		{args: []string{"pack", "-out", filepath.Join(dir, "a.fox2"), in}, want: ExitOK},
+		{args: []string{"pack", "-strict", "-out", filepath.Join(dir, "b.fox2"), in}, want: ExitError},
+	}
+
+	for _, tt := range tests {
+		if code := Main(append([]string{"datfpk"}, tt.args...)); code != tt.want {
+			t.Errorf("%v: want exit code %d, have %d", tt.args, tt.want, code)
+		}
+	}
+}
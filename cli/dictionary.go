@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/unknown321/datfpk/dictionary"
+)
+
+// defaultDictionaries are loaded from executable directory for namespaces without -dict sources
+var defaultDictionaries = []struct {
+	namespace dictionary.Namespace
+	name      string
+}{
+	{namespace: dictionary.PathCode64, name: dictionaryName},
+	{namespace: dictionary.StrCode32, name: lngDictionaryName},
+	{namespace: dictionary.StrCode64, name: foxDictionaryName},
+}
+
+// dictFlags is a repeatable -dict flag
+type dictFlags struct {
+	program string
+	sources stringList
+}
+
+func addDictFlags(fs *flag.FlagSet, program string) *dictFlags {
+	d := &dictFlags{program: program}
+	fs.Var(&d.sources, "dict", "dictionary file or directory, [qar:|lng:|fox:]path, qar if no prefix, gzip is supported, "+
+		"repeatable, first has priority (default "+dictionaryName+", "+lngDictionaryName+" and "+foxDictionaryName+" next to executable)")
+
+	return d
+}
+
+// addLngDictFlag registers -lng-dict, an alias of -dict lng:path
+func addLngDictFlag(fs *flag.FlagSet, d *dictFlags) {
+	fs.Func("lng-dict", "path to lng2 dictionary, same as -dict lng:path", func(v string) error {
+		d.sources = append(d.sources, dictionary.StrCode32.String()+":"+v)
+		return nil
+	})
+}
+
+// addFoxDictFlag registers -fox-dict, an alias of -dict fox:path
+func addFoxDictFlag(fs *flag.FlagSet, d *dictFlags) {
+	fs.Func("fox-dict", "path to fox2 dictionary, same as -dict fox:path", func(v string) error {
+		d.sources = append(d.sources, dictionary.StrCode64.String()+":"+v)
+		return nil
+	})
+}
+
+// prepend adds source with the highest priority
+func (d *dictFlags) prepend(ns dictionary.Namespace, path string) {
+	d.sources = append(stringList{ns.String() + ":" + path}, d.sources...)
+}
+
+// path returns the first plain file source of namespace or default dictionary path,
+// strings are appended to it
+func (d *dictFlags) path(ns dictionary.Namespace) string {
+	for _, s := range d.sources {
+		sourceNs, path := dictionary.ParseSource(s)
+		if sourceNs != ns {
+			continue
+		}
+
+		if strings.HasSuffix(path, ".gz") {
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+			continue
+		}
+
+		return path
+	}
+
+	for _, def := range defaultDictionaries {
+		if def.namespace == ns {
+			return defaultDictionary(d.program, def.name)
+		}
+	}
+
+	return ""
+}
+
+// load reads dictionaries of namespaces: -dict sources in order, then default dictionaries of namespaces
+// without sources, then embedded ones. Missing default dictionaries are skipped.
+func (d *dictFlags) load(namespaces ...dictionary.Namespace) (*dictionary.Manager, error) {
+	m := dictionary.NewManager()
+	explicit := map[dictionary.Namespace]bool{}
+	for _, s := range d.sources {
+		ns, path := dictionary.ParseSource(s)
+		explicit[ns] = true
+		if !slices.Contains(namespaces, ns) {
+			continue
+		}
+
+		if err := m.Load(ns, path); err != nil {
+			return nil, fmt.Errorf("cannot read %s dictionary: %w", ns, err)
+		}
+	}
+
+	for _, def := range defaultDictionaries {
+		if explicit[def.namespace] || !slices.Contains(namespaces, def.namespace) {
+			continue
+		}
+
+		path := defaultDictionary(d.program, def.name)
+		err := m.Load(def.namespace, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("no default dictionary", "namespace", def.namespace.String(), "path", path)
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s dictionary: %w", def.namespace, err)
+		}
+	}
+
+	if err := m.LoadDefaults(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range m.Collisions {
+		slog.Warn("dictionary collision", "namespace", c.Namespace.String(), "hash", fmt.Sprintf("%x", c.Hash),
+			"kept", c.Kept, "dropped", c.Dropped, "source", c.Source)
+	}
+
+	for _, ns := range namespaces {
+		slog.Debug("dictionary", "namespace", ns.String(), "entries", m.Len(ns), "sources", strings.Join(m.Sources(ns), ","))
+	}
+
+	if slices.Contains(namespaces, dictionary.PathCode64) && len(m.Sources(dictionary.PathCode64)) == 0 {
+		slog.Warn("no QAR dictionary, entry names will not be resolved", "path", d.path(dictionary.PathCode64))
+	}
+
+	return m, nil
+}
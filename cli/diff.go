@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"crypto/md5"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fpk"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// DiffEntry is a change of a single entry between two archives.
+type DiffEntry struct {
+	Change string `json:"change"`
+	Path   string `json:"path"`
+	Hash   uint64 `json:"hash,omitempty"`
+}
+
+// DiffArchives compares entries of two dat/qar or two fpk/fpkd archives by decoded content.
+// Dat/qar entries are matched by path hash, fpk/fpkd entries by path. Changes are sorted by path.
+func DiffArchives(a string, b string, dict *dictionary.Manager) ([]DiffEntry, error) {
+	var err error
+	var formatA, formatB detect.Format
+	if formatA, err = detect.Guess(a); err != nil {
+		return nil, fmt.Errorf("cannot detect file format of %s: %w", a, err)
+	}
+
+	if formatB, err = detect.Guess(b); err != nil {
+		return nil, fmt.Errorf("cannot detect file format of %s: %w", b, err)
+	}
+
+	isFpk := func(f detect.Format) bool {
+		return f == detect.Fpk || f == detect.Fpkd
+	}
+
+	var res []DiffEntry
+	switch {
+	case formatA == detect.Qar && formatB == detect.Qar:
+		res, err = diffQar(a, b, dict)
+	case isFpk(formatA) && isFpk(formatB):
+		res, err = diffFpk(a, b)
+	default:
+		return nil, usagef("cannot compare %s (%s) with %s (%s), want two dat/qar or two fpk/fpkd archives", a, formatA, b, formatB)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(res, func(x, y DiffEntry) int {
+		return strings.Compare(x.Path, y.Path)
+	})
+
+	return res, nil
+}
+
+func diffQar(a string, b string, dict *dictionary.Manager) ([]DiffEntry, error) {
+	var err error
+	qa := &qar.Qar{}
+	if err = qa.ReadFrom(a); err != nil {
+		return nil, fmt.Errorf("QAR read error %s: %w", a, err)
+	}
+	defer qa.Close()
+
+	qb := &qar.Qar{}
+	if err = qb.ReadFrom(b); err != nil {
+		return nil, fmt.Errorf("QAR read error %s: %w", b, err)
+	}
+	defer qb.Close()
+
+	// entries are matched by hash, shared paths do not matter
+	_ = qa.Resolve(dict.GetByHash)
+	_ = qb.Resolve(dict.GetByHash)
+
+	old := make(map[uint64]*qar.Entry, len(qa.Entries))
+	for i := range qa.Entries {
+		old[qa.Entries[i].Header.PathHash] = &qa.Entries[i]
+	}
+
+	var res []DiffEntry
+	seen := make(map[uint64]bool, len(qb.Entries))
+	for i := range qb.Entries {
+		e := &qb.Entries[i]
+		seen[e.Header.PathHash] = true
+
+		o, ok := old[e.Header.PathHash]
+		if !ok {
+			res = append(res, DiffEntry{Change: ChangeAdded, Path: e.Header.FilePath, Hash: e.Header.PathHash})
+			continue
+		}
+
+		// equal stored sums mean equal data as stored, no need to decode it
+		if o.Header.Md5Sum == e.Header.Md5Sum && o.Header.UncompressedSize == e.Header.UncompressedSize {
+			continue
+		}
+
+		// data md5 sums are set by extraction
+		if _, err = qa.ExtractTo(o.Header.FilePath, o.Header.PathHash, io.Discard); err != nil {
+			return nil, fmt.Errorf("read %s from %s: %w", o.Header.FilePath, a, err)
+		}
+
+		if _, err = qb.ExtractTo(e.Header.FilePath, e.Header.PathHash, io.Discard); err != nil {
+			return nil, fmt.Errorf("read %s from %s: %w", e.Header.FilePath, b, err)
+		}
+
+		if o.Header.DataMd5 != e.Header.DataMd5 {
+			res = append(res, DiffEntry{Change: ChangeModified, Path: e.Header.FilePath, Hash: e.Header.PathHash})
+		}
+	}
+
+	for _, e := range qa.Entries {
+		if !seen[e.Header.PathHash] {
+			res = append(res, DiffEntry{Change: ChangeRemoved, Path: e.Header.FilePath, Hash: e.Header.PathHash})
+		}
+	}
+
+	return res, nil
+}
+
+func diffFpk(a string, b string) ([]DiffEntry, error) {
+	var err error
+
+	fa := &fpk.Fpk{}
+	if err = fa.ReadFrom(a, false); err != nil {
+		return nil, fmt.Errorf("fpk(d) read %s: %w", a, err)
+	}
+	defer fa.Close()
+
+	fb := &fpk.Fpk{}
+	if err = fb.ReadFrom(b, false); err != nil {
+		return nil, fmt.Errorf("fpk(d) read %s: %w", b, err)
+	}
+	defer fb.Close()
+
+	contentMd5 := func(f *fpk.Fpk, path string) ([md5.Size]byte, error) {
+		data := util.NewByteArrayReaderWriter(nil)
+		if err := f.ExtractTo(path, data); err != nil {
+			return [md5.Size]byte{}, fmt.Errorf("read %s from %s: %w", path, f.FilePath, err)
+		}
+
+		return md5.Sum(data.Bytes()), nil
+	}
+
+	old := make(map[string]bool, len(fa.Entries))
+	for _, e := range fa.Entries {
+		old[e.FilePath.Data] = true
+	}
+
+	var res []DiffEntry
+	seen := make(map[string]bool, len(fb.Entries))
+	for _, e := range fb.Entries {
+		path := e.FilePath.Data
+		seen[path] = true
+
+		if !old[path] {
+			res = append(res, DiffEntry{Change: ChangeAdded, Path: path})
+			continue
+		}
+
+		var sumA, sumB [md5.Size]byte
+		if sumA, err = contentMd5(fa, path); err != nil {
+			return nil, err
+		}
+
+		if sumB, err = contentMd5(fb, path); err != nil {
+			return nil, err
+		}
+
+		if sumA != sumB {
+			res = append(res, DiffEntry{Change: ChangeModified, Path: path})
+		}
+	}
+
+	for _, e := range fa.Entries {
+		if !seen[e.FilePath.Data] {
+			res = append(res, DiffEntry{Change: ChangeRemoved, Path: e.FilePath.Data})
+		}
+	}
+
+	return res, nil
+}
+
+func writeDiff(changes []DiffEntry, format string, writer io.Writer) error {
+	var err error
+
+	header := []string{"change", "path", "hash"}
+	fields := func(d DiffEntry) []string {
+		return []string{d.Change, d.Path, hex(d.Hash)}
+	}
+
+	switch format {
+	case FormatJSON:
+		if changes == nil {
+			changes = []DiffEntry{}
+		}
+
+		enc := json.NewEncoder(writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	case FormatCSV:
+		w := csv.NewWriter(writer)
+		if err = w.Write(header); err != nil {
+			return err
+		}
+
+		for _, d := range changes {
+			if err = w.Write(fields(d)); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+	case FormatTable, "":
+		if len(changes) == 0 {
+			return nil
+		}
+
+		w := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(header, "\t")))
+		for _, d := range changes {
+			fmt.Fprintln(w, strings.Join(fields(d), "\t"))
+		}
+
+		return w.Flush()
+	default:
+		return usagef("unknown format %q, want one of %s, %s, %s", format, FormatTable, FormatJSON, FormatCSV)
+	}
+}
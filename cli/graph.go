@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+)
+
+const FormatDOT = "dot"
+
+// PrintGraph writes entity reference graph of fox2 or fox2 xml file to writer in dot or json format.
+func PrintGraph(path string, names fox.Names, format string, writer io.Writer) error {
+	if format != FormatDOT && format != FormatJSON {
+		return usagef("unknown format %q, want one of %s, %s", format, FormatDOT, FormatJSON)
+	}
+
+	f, err := readFox2(path, names)
+	if err != nil {
+		return err
+	}
+
+	g := fox2.NewGraph(f)
+	if format == FormatJSON {
+		return g.WriteJSON(writer)
+	}
+
+	return g.WriteDOT(writer)
+}
+
+func setupGraph(fs *flag.FlagSet, program string) func(args []string) error {
+	format := fs.String("format", FormatDOT, "output format: dot or json")
+	out := fs.String("out", "", "output file (default stdout)")
+	dicts := addDictFlags(fs, program)
+	addFoxDictFlag(fs, dicts)
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 fox2 file, got %d arguments", len(args))
+		}
+
+		dict, err := dicts.load(dictionary.StrCode64)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+
+		return PrintGraph(args[0], dict.Fox(), *format, w)
+	}
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/unknown321/datfpk/archive"
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/resolve"
+)
+
+// HarvestDirs collects strings from extracted files in dirs. Paths of unresolved entries from dat/qar definitions
+// found in dirs are appended to qar dictionary file, fox2 string literals missing from dict are appended to
+// fox2 dictionary file.
+func HarvestDirs(dirs []string, dict *dictionary.Manager, dictionaryPath string, foxDictionaryPath string) error {
+	var err error
+	var unresolved []uint64
+	for _, dir := range dirs {
+		err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			if format, err := detect.File(path); err != nil || format != detect.QarDefinition {
+				return nil
+			}
+
+			q, err := archive.ReadQarDefinition(path)
+			if err != nil {
+				slog.Warn("harvest", "path", path, "error", err.Error())
+				return nil
+			}
+
+			// dictionary might have been updated since extraction
+			unresolved = append(unresolved, resolve.Unresolved(q, dict.GetByHash)...)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	r := resolve.New(unresolved)
+	var literals []string
+	for _, dir := range dirs {
+		err = resolve.Walk(dir, func(s string, kind resolve.Kind) {
+			if kind == resolve.Literal {
+				if h, ok := dictionary.StrCode64.Hash(s); ok {
+					if known, ok := dict.Get(dictionary.StrCode64, h); !ok || known != s {
+						literals = append(literals, s)
+					}
+				}
+				return
+			}
+
+			if len(unresolved) > 0 {
+				r.TryString(s)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for hash, name := range r.Found() {
+		slog.Info("resolved", "hash", fmt.Sprintf("%x", hash), "path", name)
+	}
+
+	added, err := appendLines(dictionaryPath, r.Delta())
+	if err != nil {
+		return err
+	}
+	slog.Info("harvest", "unresolved", len(unresolved), "resolved", len(r.Found()), "dictionary", dictionaryPath, "added", added)
+
+	added, err = appendLines(foxDictionaryPath, literals)
+	if err != nil {
+		return err
+	}
+	slog.Info("harvest", "literals", len(literals), "dictionary", foxDictionaryPath, "added", added)
+
+	return nil
+}
+
+// appendLines appends lines missing from file, file is created if it does not exist even if there is nothing to add.
+// Returns number of appended lines.
+func appendLines(path string, lines []string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	// hashing.Dictionary splits by \r\n if file has it
+	newline := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	existing := map[string]bool{}
+	for _, l := range strings.Split(string(data), newline) {
+		existing[l] = true
+	}
+
+	var add []string
+	for _, l := range lines {
+		if l != "" && !existing[l] {
+			existing[l] = true
+			add = append(add, l)
+		}
+	}
+
+	slices.Sort(add)
+
+	buf := &bytes.Buffer{}
+	if len(add) > 0 && len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteString(newline)
+	}
+
+	for _, l := range add {
+		buf.WriteString(l + newline)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return 0, err
+	}
+
+	return len(add), f.Close()
+}
+
+func setupHarvest(fs *flag.FlagSet, program string) func(args []string) error {
+	dicts := addDictFlags(fs, program)
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return usagef("want at least 1 directory")
+		}
+
+		// strings are appended to the first plain qar and fox dictionary files, they are created if missing
+		dictionaryPath, foxDictionaryPath := dicts.path(dictionary.PathCode64), dicts.path(dictionary.StrCode64)
+		for _, p := range []string{dictionaryPath, foxDictionaryPath} {
+			if _, err := appendLines(p, nil); err != nil {
+				return err
+			}
+		}
+
+		dict, err := dicts.load(dictionary.PathCode64, dictionary.StrCode64)
+		if err != nil {
+			return err
+		}
+
+		return HarvestDirs(args, dict, dictionaryPath, foxDictionaryPath)
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/unknown321/hashing"
+)
+
+// Hashes of a single string as used by the game
+type Hashes struct {
+	String string `json:"string"`
+	// PathCode64 is a dat/qar entry hash of file path with extension
+	PathCode64 uint64 `json:"pathCode64"`
+	// PathHash is a hash of file path without extension, used in hash-named files
+	PathHash  uint64 `json:"pathHash"`
+	StrCode64 uint64 `json:"strCode64"`
+	StrCode32 uint64 `json:"strCode32"`
+}
+
+func HashString(s string) Hashes {
+	return Hashes{
+		String:     s,
+		PathCode64: hashing.HashFileNameWithExtension(s),
+		PathHash:   hashing.HashFileName(s, true),
+		StrCode64:  hashing.StrCode64([]byte(s)),
+		StrCode32:  hashing.StrCode32([]byte(s)),
+	}
+}
+
+// PrintHashes writes hashes of strings in table, json or csv format.
+func PrintHashes(strs []string, format string, writer io.Writer) error {
+	var err error
+
+	res := make([]Hashes, 0, len(strs))
+	for _, s := range strs {
+		res = append(res, HashString(s))
+	}
+
+	header := []string{"string", "pathCode64", "pathHash", "strCode64", "strCode32"}
+	fields := func(h Hashes) []string {
+		return []string{
+			h.String,
+			fmt.Sprintf("%x", h.PathCode64),
+			fmt.Sprintf("%x", h.PathHash),
+			fmt.Sprintf("%x", h.StrCode64),
+			fmt.Sprintf("%x", h.StrCode32),
+		}
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case FormatCSV:
+		w := csv.NewWriter(writer)
+		if err = w.Write(header); err != nil {
+			return err
+		}
+
+		for _, h := range res {
+			if err = w.Write(fields(h)); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+	case FormatTable, "":
+		w := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(header, "\t")))
+		for _, h := range res {
+			fmt.Fprintln(w, strings.Join(fields(h), "\t"))
+		}
+
+		return w.Flush()
+	default:
+		return usagef("unknown format %q, want one of %s, %s, %s", format, FormatTable, FormatJSON, FormatCSV)
+	}
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fpk"
+	"github.com/unknown321/datfpk/lng"
+	"github.com/unknown321/datfpk/qar"
+)
+
+type infoField struct {
+	name  string
+	value any
+}
+
+// PrintInfo writes detected format and header fields of file in table or json format.
+// Only headers and entry tables are read.
+func PrintInfo(path string, format string, writer io.Writer) error {
+	fields, err := fileInfo(path)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		res := make(map[string]any, len(fields))
+		for _, f := range fields {
+			res[f.name] = f.value
+		}
+
+		enc := json.NewEncoder(writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case FormatTable, "":
+		w := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		for _, f := range fields {
+			fmt.Fprintf(w, "%s:\t%v\n", f.name, f.value)
+		}
+
+		return w.Flush()
+	default:
+		return usagef("unknown format %q, want one of %s, %s", format, FormatTable, FormatJSON)
+	}
+}
+
+func fileInfo(path string) ([]infoField, error) {
+	format, err := detect.Guess(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect file format of %s: %w", path, err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []infoField{
+		{"path", path},
+		{"format", string(format)},
+		{"size", stat.Size()},
+	}
+
+	if format.IsDefinition() {
+		return append(fields, infoField{"packs to", string(format.Binary())}), nil
+	}
+
+	switch format {
+	case detect.Qar:
+		q := qar.Qar{}
+		if err = q.ReadFrom(path); err != nil {
+			return nil, fmt.Errorf("QAR read error: %w", err)
+		}
+		defer q.Close()
+
+		encrypted, compressed := 0, 0
+		for _, e := range q.Entries {
+			if e.DataHeader.EncryptionMagic > 0 {
+				encrypted++
+			}
+			if e.Header.Compressed {
+				compressed++
+			}
+		}
+
+		fields = append(fields,
+			infoField{"version", q.Version},
+			infoField{"flags", fmt.Sprintf("%x", q.Flags)},
+			infoField{"file count", q.FileCount},
+			infoField{"unknown count", q.UnknownCount},
+			infoField{"first file offset", q.OffsetFirstFile},
+			infoField{"block file end", q.BlockFileEnd},
+			infoField{"encrypted entries", encrypted},
+			infoField{"compressed entries", compressed},
+		)
+	case detect.Fpk, detect.Fpkd:
+		f := fpk.Fpk{}
+		if err = f.ReadFrom(path, false); err != nil {
+			return nil, fmt.Errorf("fpk(d) read: %w", err)
+		}
+		defer f.Close()
+
+		fields = append(fields,
+			infoField{"file size", f.Header.FileSize},
+			infoField{"entries", f.Header.EntryCount},
+			infoField{"references", f.Header.RefCount},
+		)
+	case detect.Fox2:
+		h := fox2.Header{}
+		if err = readHeader(path, func(r io.ReadSeeker) error { return h.Read(r) }); err != nil {
+			return nil, fmt.Errorf("fox2 header: %w", err)
+		}
+
+		fields = append(fields,
+			infoField{"entities", h.EntityCount},
+			infoField{"string table offset", h.StringTableOffset},
+			infoField{"data offset", h.DataOffset},
+		)
+	case detect.Lng:
+		h := lng.Header{}
+		if err = readHeader(path, h.Read); err != nil {
+			return nil, fmt.Errorf("lng header: %w", err)
+		}
+
+		endianness := "LE"
+		if h.Endianness == lng.EndiannessBE {
+			endianness = "BE"
+		}
+
+		fields = append(fields,
+			infoField{"version", h.Version},
+			infoField{"endianness", endianness},
+			infoField{"entries", h.EntryCount},
+		)
+	default:
+		return nil, fmt.Errorf("unknown file format: %s", path)
+	}
+
+	return fields, nil
+}
+
+func readHeader(path string, read func(r io.ReadSeeker) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return read(f)
+}
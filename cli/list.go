@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fpk"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+
+	"github.com/unknown321/hashing"
+)
+
+// ListEntry is a single archive entry as shown by list command.
+// Sizes and offset are taken from entry headers as is.
+type ListEntry struct {
+	Path             string `json:"path"`
+	Hash             uint64 `json:"hash,omitempty"`
+	CompressedSize   uint32 `json:"compressedSize"`
+	UncompressedSize uint32 `json:"uncompressedSize"`
+	Offset           int64  `json:"offset"`
+	Encrypted        bool   `json:"encrypted"`
+	Encryption       uint32 `json:"encryption,omitempty"` // dat/qar encryption magic
+	Key              uint32 `json:"key,omitempty"`
+	Compressed       bool   `json:"compressed"`
+	MetaFlag         bool   `json:"metaFlag"`
+}
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// ListArchive prints entries of dat/qar or fpk/fpkd file to writer without extracting them.
+// Dictionary is used for dat/qar files only, unresolved entries are listed by hash.
+func ListArchive(path string, dict *dictionary.Manager, format string, filter *util.Filter, writer io.Writer) error {
+	var entries []ListEntry
+	var err error
+
+	var archive detect.Format
+	if archive, err = detect.Guess(path); err != nil {
+		return fmt.Errorf("cannot detect file format: %w", err)
+	}
+
+	switch archive {
+	case detect.Fpk, detect.Fpkd:
+		entries, err = listFpk(path)
+	case detect.Qar:
+		entries, err = listQar(path, dict)
+	default:
+		return fmt.Errorf("%s is not a dat/qar or fpk/fpkd archive", path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	selected := make([]ListEntry, 0, len(entries))
+	for _, e := range entries {
+		hash := e.Hash
+		if hash == 0 {
+			hash = hashing.HashFileNameWithExtension(e.Path)
+		}
+
+		if filter.Match(e.Path, hash) {
+			selected = append(selected, e)
+		}
+	}
+
+	return writeList(selected, format, writer)
+}
+
+func listQar(path string, dict *dictionary.Manager) ([]ListEntry, error) {
+	q := qar.Qar{}
+	if err := q.ReadFrom(path); err != nil {
+		return nil, fmt.Errorf("QAR read error: %w", err)
+	}
+	defer q.Close()
+
+	// entries sharing a path are listed separately
+	_ = q.Resolve(dict.GetByHash)
+
+	res := make([]ListEntry, 0, len(q.Entries))
+	for _, e := range q.Entries {
+		res = append(res, ListEntry{
+			Path:             e.Header.FilePath,
+			Hash:             e.Header.PathHash,
+			CompressedSize:   e.Header.CompressedSize,
+			UncompressedSize: e.Header.UncompressedSize,
+			Offset:           e.Header.DataOffset,
+			Encrypted:        e.DataHeader.EncryptionMagic > 0,
+			Encryption:       e.DataHeader.EncryptionMagic,
+			Key:              e.DataHeader.Key,
+			Compressed:       e.Header.Compressed,
+			MetaFlag:         e.Header.MetaFlag,
+		})
+	}
+
+	return res, nil
+}
+
+func listFpk(path string) ([]ListEntry, error) {
+	f := fpk.Fpk{}
+	if err := f.ReadFrom(path, false); err != nil {
+		return nil, fmt.Errorf("fpk(d) read: %w", err)
+	}
+	defer f.Close()
+
+	res := make([]ListEntry, 0, len(f.Entries))
+	for _, e := range f.Entries {
+		res = append(res, ListEntry{
+			Path:             e.FilePath.Data,
+			CompressedSize:   e.DataSize,
+			UncompressedSize: uint32(len(e.Data)),
+			Offset:           int64(e.DataOffset),
+			Encrypted:        e.Encrypted,
+		})
+	}
+
+	return res, nil
+}
+
+func writeList(entries []ListEntry, format string, writer io.Writer) error {
+	var err error
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case FormatCSV:
+		w := csv.NewWriter(writer)
+		if err = w.Write(listHeader); err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err = w.Write(e.fields()); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+	case FormatTable, "":
+		w := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(listHeader, "\t")))
+		for _, e := range entries {
+			fmt.Fprintln(w, strings.Join(e.fields(), "\t"))
+		}
+
+		return w.Flush()
+	default:
+		return usagef("unknown format %q, want one of %s, %s, %s", format, FormatTable, FormatJSON, FormatCSV)
+	}
+}
+
+var listHeader = []string{"path", "hash", "compressedSize", "uncompressedSize", "offset", "encrypted", "encryption", "key", "compressed", "metaFlag"}
+
+func (e *ListEntry) fields() []string {
+	return []string{
+		e.Path,
+		hex(e.Hash),
+		strconv.FormatUint(uint64(e.CompressedSize), 10),
+		strconv.FormatUint(uint64(e.UncompressedSize), 10),
+		strconv.FormatInt(e.Offset, 10),
+		strconv.FormatBool(e.Encrypted),
+		hex(uint64(e.Encryption)),
+		hex(uint64(e.Key)),
+		strconv.FormatBool(e.Compressed),
+		strconv.FormatBool(e.MetaFlag),
+	}
+}
+
+// hex formats v, zero is empty
+func hex(v uint64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", v)
+}
+
+type filterFlags struct {
+	include stringList
+	exclude stringList
+	hashes  stringList
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.Var(&f.include, "include", "select entries matching glob, repeatable")
+	fs.Var(&f.exclude, "exclude", "skip entries matching glob, repeatable")
+	fs.Var(&f.hashes, "hash", "select entry by hex path hash, repeatable")
+
+	return f
+}
+
+func (f *filterFlags) filter() (*util.Filter, error) {
+	hashes := make([]uint64, 0, len(f.hashes))
+	for _, v := range f.hashes {
+		h, err := util.ParseHash(v)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", v, err)
+		}
+		hashes = append(hashes, h)
+	}
+
+	return util.NewFilter(f.include, f.exclude, hashes)
+}
+
+// parseSubcommand parses flags which may be mixed with positional arguments, positional arguments are returned.
+// Arguments after "--" are positional.
+func parseSubcommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		// flag package consumes "--" and stops
+		if len(args) > fs.NArg() && args[len(args)-fs.NArg()-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -1,18 +1,16 @@
 package cli
 
 import (
-	"encoding/json"
-	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/unknown321/datfpk/archive"
+	"github.com/unknown321/datfpk/detect"
 	"github.com/unknown321/datfpk/dictionary"
-	"github.com/unknown321/datfpk/fox2"
-	"github.com/unknown321/datfpk/fpk"
-	"github.com/unknown321/datfpk/lng"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
 	"github.com/unknown321/datfpk/qar"
 	"github.com/unknown321/datfpk/util"
 
@@ -22,66 +20,35 @@ import (
 const dictionaryName = "dictionary.txt"
 const dictUrl = "https://github.com/kapuragu/mgsv-lookup-strings/raw/refs/heads/master/GzsTool/qar_dictionary.txt"
 const lngDictionaryName = "lngDictionary.txt"
+const foxDictionaryName = "foxDictionary.txt"
 
-type jsonInput struct {
-	Type string `json:"type"`
-}
-
-func DecompileLng(in string, dictionaryPath string, out string) error {
-	dict := dictionary.DictStrCode64{}
-	df, err := os.OpenFile(dictionaryPath, os.O_RDONLY, 0444)
-	if err == nil {
-		if err = dict.Read(df); err != nil {
-			return err
-		}
-	} else {
-		slog.Warn("cannot open lng dictionary file", "error", err.Error())
-	}
-
+// DecompileLng decompiles lng2 file, keys are resolved with dict.
+func DecompileLng(in string, dict dictionary.DictStrCode64, out string) error {
 	input, err := os.Open(in)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
-	l := &lng.Lng{}
-	if err = l.Read(input, dict); err != nil {
-		return err
-	}
-
 	if out == "" {
 		out = in + ".json"
 	}
 
-	data, err := l.MarshalJSON()
-	if err != nil {
-		return err
-	}
-
 	outFile, err := os.OpenFile(out, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
 	defer outFile.Close()
 
-	if _, err = outFile.Write(data); err != nil {
-		return err
-	}
-
-	return nil
+	return archive.DecompileLng(input, dict, outFile)
 }
 
 func CompileLng(in string, out string) error {
-	var err error
-	input, err := os.ReadFile(in)
+	input, err := os.Open(in)
 	if err != nil {
 		return err
 	}
-
-	l := &lng.Lng{}
-	if err = l.UnmarshalJSON(input); err != nil {
-		return err
-	}
+	defer input.Close()
 
 	if out == "" {
 		out = strings.TrimSuffix(in, ".json")
@@ -93,26 +60,17 @@ func CompileLng(in string, out string) error {
 	}
 	defer outFile.Close()
 
-	if err = l.Write(outFile); err != nil {
-		return err
-	}
-
-	return nil
+	return archive.CompileLng(input, outFile)
 }
 
-func DecompileFox2(in string, out string) error {
-	var err error
+// DecompileFox2 decompiles fox2 file, hashes missing from its string table are resolved with names.
+func DecompileFox2(in string, names fox.Names, out string) error {
 	input, err := os.Open(in)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
-	f := &fox2.Fox2{}
-	if err = f.Read(input); err != nil {
-		return err
-	}
-
 	if out == "" {
 		out = in + ".xml"
 	}
@@ -123,26 +81,17 @@ func DecompileFox2(in string, out string) error {
 	}
 	defer outFile.Close()
 
-	if err = f.ToXML(outFile); err != nil {
-		return err
-	}
-
-	return nil
+	return archive.DecompileFox2(input, names, outFile)
 }
 
-func CompileFox2(in string, out string) error {
-	var err error
+// CompileFox2 compiles fox2 xml, see archive.CompileFox2.
+func CompileFox2(in string, opts archive.Fox2Options, out string) error {
 	input, err := os.Open(in)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
-	f := &fox2.Fox2{}
-	if err = f.FromXML(input); err != nil {
-		return err
-	}
-
 	if out == "" {
 		out = strings.TrimSuffix(in, ".xml")
 	}
@@ -153,82 +102,55 @@ func CompileFox2(in string, out string) error {
 	}
 	defer outFile.Close()
 
-	if err = f.Write(outFile); err != nil {
-		return err
-	}
+	return archive.CompileFox2(input, opts, outFile)
+}
 
-	return nil
+// logProgress logs every processed archive entry
+func logProgress(kind string) archive.ProgressFunc {
+	return func(p archive.Progress) {
+		if p.Err != nil {
+			slog.Error(kind, "entry", p.Path, "error", p.Err.Error())
+			return
+		}
+
+		slog.Info(kind, "entry", p.Path, "done", fmt.Sprintf("%d/%d", p.Done, p.Total))
+	}
 }
 
-func ExtractQar(qarPath string, dictionaryPath string, outDir string) error {
+// ExtractQar extracts entries selected by filter, definition describes either full archive or
+// only selected entries if subsetDefinition is set.
+func ExtractQar(qarPath string, dict *dictionary.Manager, outDir string, workers int, filter *util.Filter, subsetDefinition bool) error {
 	var err error
 
 	if qarPath == "" {
 		return fmt.Errorf("no dat/qar path provided")
 	}
 
-	if dictionaryPath == "" {
-		return fmt.Errorf("no dictionary.txt path provided")
+	if len(dict.Sources(dictionary.PathCode64)) == 0 {
+		return fmt.Errorf("no QAR dictionary, create empty %s to skip filename resolution", dictionaryName)
 	}
+	slog.Info("QAR dictionary entries", "count", dict.Len(dictionary.PathCode64))
 
-	dict := hashing.Dictionary{}
-
-	dictFile, err := os.Open(dictionaryPath)
-	if err != nil {
-		slog.Error("cannot open QAR dictionary", "path", dictionaryName, "error", err.Error())
-		os.Exit(1)
+	if err = prepareOutDir(outDir); err != nil {
+		return err
 	}
-	defer dictFile.Close()
 
-	if err = dict.Read(dictFile); err != nil {
-		slog.Error("cannot read QAR dictionary", "error", err.Error())
-		os.Exit(1)
+	opts := archive.ExtractOptions{
+		Names:    dict.GetByHash,
+		Filter:   filter,
+		Workers:  workers,
+		Logger:   slog.Default(),
+		Progress: logProgress("qar"),
 	}
-	slog.Info("QAR dictionary entries", "count", len(dict.Hashes))
 
 	if outDir != "" {
-		o, err := os.Stat(outDir)
-		if os.IsNotExist(err) {
-			if err = os.MkdirAll(outDir, 0755); err != nil {
-				slog.Error("cannot create outdir", "error", err.Error())
-				os.Exit(1)
-			}
-		}
-		if err == nil && o != nil {
-			if !o.IsDir() {
-				slog.Error("not a directory", "path", outDir)
-				os.Exit(1)
-			}
-		}
+		opts.Output = archive.DirFS(outDir)
 	}
 
-	q := qar.Qar{}
-	if err = q.ReadFrom(qarPath); err != nil {
-		slog.Error("QAR read error", "error", err.Error())
-		os.Exit(1)
-	}
-
-	defer q.Close()
-
-	slog.Info("QAR",
-		"version", q.Version,
-		"flags", q.Flags,
-		"file count", q.FileCount,
-		"first file offset", q.OffsetFirstFile,
-		"block file end", q.BlockFileEnd,
-		"entries", len(q.Entries))
-
-	for n, e := range q.Entries {
-		entryName, resolved := dict.GetByHash(e.Header.PathHash)
-		q.Entries[n].Header.FilePath = entryName
-		if !resolved {
-			q.Entries[n].Header.NameHashForPacking = e.Header.PathHash
-		}
-		slog.Info("qar", "entry", entryName, "pathHash", fmt.Sprintf("%x", e.Header.PathHash), "offset", e.Header.DataOffset, "encrypted", e.DataHeader.EncryptionMagic > 0, "key", fmt.Sprintf("%x", e.DataHeader.Key), "compressed", e.Header.Compressed)
-
-		if _, err = q.Extract(entryName, e.Header.PathHash, outDir); err != nil {
-			return err
-		}
+	// definition is saved after partial extraction too
+	q, extractErr := archive.ExtractQar(qarPath, opts)
+	if q == nil {
+		return extractErr
 	}
 
 	descName := qarPath + ".json"
@@ -236,346 +158,308 @@ func ExtractQar(qarPath string, dictionaryPath string, outDir string) error {
 	if err != nil {
 		return fmt.Errorf("cannot open description file %s for writing: %w", descName, err)
 	}
+	defer desc.Close()
 
-	if err = q.SaveDefinition(desc); err != nil {
+	def := q
+	if subsetDefinition {
+		def = q.Subset(filter)
+	}
+
+	if err = def.SaveDefinition(desc); err != nil {
 		return fmt.Errorf("cannot save description to %s: %w", descName, err)
 	}
 
-	return nil
+	return extractErr
 }
 
-func PackQar(jsonDefinitionPath string, outPath string, inputDir string) error {
-	var f []byte
-	var err error
-	if f, err = os.ReadFile(jsonDefinitionPath); err != nil {
-		return fmt.Errorf("read qar definition from %s: %w", jsonDefinitionPath, err)
+func VerifyQar(qarPath string, dict *dictionary.Manager) error {
+	report, err := archive.VerifyQar(qarPath, dict.GetByHash)
+	if report == nil {
+		return err
 	}
 
-	q := &qar.Qar{}
-	if err = json.Unmarshal(f, q); err != nil {
-		return fmt.Errorf("unmarshal qar definition: %w", err)
+	for _, v := range report.Corrupt {
+		slog.Error("corrupt",
+			"entry", v.Path,
+			"pathHash", fmt.Sprintf("%x", v.Hash),
+			"offset", v.Offset,
+			"problems", fmt.Sprintf("%v", v.Problems),
+			"storedMd5", fmt.Sprintf("%x", v.StoredMd5),
+			"computedMd5", fmt.Sprintf("%x", v.ComputedMd5),
+			"expectedSize", v.ExpectedSize,
+			"actualSize", v.ActualSize,
+			"error", v.Error,
+		)
 	}
 
-	slog.Info("QAR", "version", q.Version, "filecount", len(q.Entries), "flags", q.Flags)
-
-	if outPath == "" {
-		ext := filepath.Ext(jsonDefinitionPath)
-		outPath = strings.TrimSuffix(jsonDefinitionPath, ext)
+	for _, v := range report.DecompressedMd5 {
+		slog.Warn("md5 of decompressed data",
+			"entry", v.Path,
+			"pathHash", fmt.Sprintf("%x", v.Hash),
+			"offset", v.Offset,
+		)
 	}
 
-	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
-	if err != nil {
-		slog.Error("open QAR file for writing", "error", err.Error())
-		os.Exit(1)
-	}
+	slog.Info("verify", "path", qarPath, "entries", report.Entries, "corrupt", len(report.Corrupt),
+		"decompressedMd5", len(report.DecompressedMd5))
 
-	if inputDir == "" {
-		nojs := strings.TrimSuffix(jsonDefinitionPath, ".json")
-		ext := filepath.Ext(nojs)
-		inputDir = strings.TrimSuffix(nojs, ext) + strings.Replace(ext, ".", "_", -1)
+	return err
+}
+
+// patchEntry reads entry from archivePath=localPath pair
+func patchEntry(v string) (qar.Entry, string, error) {
+	e := qar.Entry{}
+	archivePath, localPath, ok := strings.Cut(v, "=")
+	if !ok {
+		return e, "", fmt.Errorf("bad entry %q, want archivePath=localPath", v)
 	}
-	slog.Info("input", "directory", inputDir, "output", outPath)
 
-	if err = q.Write(out, inputDir, true); err != nil {
-		slog.Error("write QAR", "error", err.Error())
-		os.Exit(1)
+	var err error
+	e.Header.FilePath = archivePath
+	if e.Data, err = os.ReadFile(localPath); err != nil {
+		return e, "", fmt.Errorf("read %s: %w", localPath, err)
 	}
 
-	return nil
+	return e, localPath, nil
 }
 
-func ExtractFpk(path string, outDir string) error {
-	var err error
-	if outDir != "" {
-		o, err := os.Stat(outDir)
-		if os.IsNotExist(err) {
-			if err = os.MkdirAll(outDir, 0755); err != nil {
-				slog.Error("cannot create outdir", "error", err.Error())
-				os.Exit(1)
-			}
-		}
-		if err == nil && o != nil {
-			if !o.IsDir() {
-				slog.Error("not a directory", "path", outDir)
-				os.Exit(1)
-			}
-		}
+// PatchQar changes entries of qarPath in place, see qar.Patch.
+// Replaced entries keep compression and encryption key of original entry, added entries are stored as is.
+// Items of replace and add are archivePath=localPath pairs.
+func PatchQar(qarPath string, replace []string, add []string, remove []string, compact bool) error {
+	file, err := os.OpenFile(qarPath, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("open QAR: %w", err)
 	}
+	defer file.Close()
 
-	f := fpk.Fpk{}
-	if err = f.ReadFrom(path, true); err != nil {
-		return fmt.Errorf("fpk(d) read: %w", err)
+	q := qar.Qar{}
+	if err = q.Read(file); err != nil {
+		return fmt.Errorf("QAR read error: %w", err)
 	}
 
-	slog.Info("extracting fpk(d)", "in", path, "out", outDir)
+	patch := qar.Patch{Remove: remove, Compact: compact}
+	for _, v := range replace {
+		e, localPath, err := patchEntry(v)
+		if err != nil {
+			return err
+		}
 
-	for _, v := range f.Entries {
-		if err = f.Extract(v.FilePath.Data, outDir); err != nil {
-			slog.Error("fpk extract", "path", v.FilePath.Data, "error", err.Error())
-			os.Exit(1)
+		existing, err := q.Find(e.Header.FilePath, hashing.HashFileNameWithExtension(e.Header.FilePath))
+		if err != nil {
+			return fmt.Errorf("replace: %w", err)
 		}
+
+		e.Header.Compressed = existing.Header.Compressed
+		e.DataHeader.Key = existing.DataHeader.Key
+
+		slog.Info("patch", "replace", e.Header.FilePath, "from", localPath)
+		patch.Put = append(patch.Put, e)
 	}
 
-	f.Close()
+	for _, v := range add {
+		e, localPath, err := patchEntry(v)
+		if err != nil {
+			return err
+		}
 
-	descName := path + ".json"
-	desc, err := os.OpenFile(descName, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("cannot open definition file %s for writing: %w", descName, err)
+		if _, err = q.Find(e.Header.FilePath, hashing.HashFileNameWithExtension(e.Header.FilePath)); err == nil {
+			return fmt.Errorf("add: entry already exists, path: %s", e.Header.FilePath)
+		}
+
+		slog.Info("patch", "add", e.Header.FilePath, "from", localPath)
+		patch.Put = append(patch.Put, e)
 	}
 
-	if err = f.SaveDefinition(desc); err != nil {
-		return fmt.Errorf("cannot save definition to %s: %w", descName, err)
+	for _, v := range remove {
+		slog.Info("patch", "remove", v)
+	}
+
+	if err = q.Patch(file, patch); err != nil {
+		return fmt.Errorf("patch: %w", err)
 	}
 
+	slog.Info("patched", "path", qarPath, "entries", len(q.Entries))
+
 	return nil
 }
 
-func PackFpk(jsonDefinitionPath string, outPath string, inputDir string) error {
-	var f []byte
-	var err error
-	if f, err = os.ReadFile(jsonDefinitionPath); err != nil {
-		return fmt.Errorf("read fpk definition from %s: %w", jsonDefinitionPath, err)
-	}
+// stringList is a repeatable flag
+type stringList []string
 
-	q := &fpk.Fpk{}
-	if err = json.Unmarshal(f, q); err != nil {
-		return fmt.Errorf("unmarshal fpk definition: %w", err)
-	}
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
 
-	slog.Info("Fpk", "fileCount", len(q.Entries), "references", len(q.References))
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// PackQar packs qar from definition. If passthrough is set, unmodified entries are copied from
+// archive the definition was extracted from.
+func PackQar(jsonDefinitionPath string, outPath string, inputDir string, workers int, passthrough bool) error {
+	q, err := archive.ReadQarDefinition(jsonDefinitionPath)
+	if err != nil {
+		return err
+	}
 
 	if outPath == "" {
 		ext := filepath.Ext(jsonDefinitionPath)
 		outPath = strings.TrimSuffix(jsonDefinitionPath, ext)
 	}
 
-	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	opts := archive.PackOptions{
+		InputDir: inputDir,
+		Workers:  workers,
+		Logger:   slog.Default(),
+		Progress: logProgress("writing"),
+	}
+
+	// source is read while output is written, output replaces source when done
+	writePath := outPath
+	if passthrough && q.SourcePath != "" {
+		sourcePath := filepath.Join(filepath.Dir(jsonDefinitionPath), q.SourcePath)
+		source := &qar.Qar{}
+		if err = source.ReadFrom(sourcePath); err != nil {
+			return fmt.Errorf("read passthrough source: %w", err)
+		}
+		defer source.Close()
+
+		opts.Source = source
+		slog.Info("passthrough", "source", sourcePath)
+
+		sourceStat, _ := os.Stat(sourcePath)
+		if outStat, err := os.Stat(outPath); err == nil && os.SameFile(sourceStat, outStat) {
+			writePath = outPath + ".tmp"
+		}
+	}
+
+	out, err := os.OpenFile(writePath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
 	if err != nil {
-		slog.Error("open fpk file for writing", "error", err.Error())
-		os.Exit(1)
+		return fmt.Errorf("open QAR file for writing: %w", err)
 	}
+	defer out.Close()
 
-	if inputDir == "" {
-		nojs := strings.TrimSuffix(jsonDefinitionPath, ".json")
-		ext := filepath.Ext(nojs)
-		inputDir = strings.TrimSuffix(nojs, ext) + strings.Replace(ext, ".", "_", -1)
+	if opts.InputDir == "" {
+		opts.InputDir = archive.UnpackedDir(strings.TrimSuffix(jsonDefinitionPath, ".json"), detect.Qar)
 	}
-	slog.Info("input", "directory", inputDir, "output", outPath)
+	slog.Info("input", "directory", opts.InputDir, "output", outPath)
+
+	if err = archive.PackQar(q, out, opts); err != nil {
+		return err
+	}
+
+	if writePath != outPath {
+		if err = out.Close(); err != nil {
+			return fmt.Errorf("close output: %w", err)
+		}
 
-	if err = q.Write(out, inputDir, true); err != nil {
-		slog.Error("write fpk", "error", err.Error())
-		os.Exit(1)
+		if err = os.Rename(writePath, outPath); err != nil {
+			return fmt.Errorf("replace source: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func Run() {
-	datPath := flag.String("dat", "", "path to dat/qar file")
+// ExtractFpk extracts entries selected by filter, see ExtractQar.
+func ExtractFpk(path string, outDir string, filter *util.Filter, subsetDefinition bool) error {
+	var err error
+	if err = prepareOutDir(outDir); err != nil {
+		return err
+	}
 
-	exePath, err := filepath.Abs(os.Args[0])
+	slog.Info("extracting fpk(d)", "in", path, "out", outDir)
+
+	opts := archive.ExtractOptions{
+		Filter:   filter,
+		Logger:   slog.Default(),
+		Progress: logProgress("fpk"),
+	}
+
+	if outDir != "" {
+		opts.Output = archive.DirFS(outDir)
+	}
+
+	f, err := archive.ExtractFpk(path, opts)
 	if err != nil {
-		slog.Error("cannot get executable path", "error", err.Error())
-		os.Exit(1)
-	}
-	fp := filepath.Join(filepath.Dir(exePath), dictionaryName)
-
-	dictPath := flag.String("dict", fp, "path to qar dict file")
-	out := flag.String("out", "", "output file/directory (default <filename>_<extension>/)")
-	jsonPath := flag.String("json", "", "path to qar definition file (.json)")
-	inputDir := flag.String("in", "", "input directory path (default <jsonFilename>_<extension>/)")
-	printVer := flag.Bool("version", false, "print version")
-
-	flag.CommandLine.SetOutput(os.Stdout)
-	flag.Usage = func() {
-		fmt.Printf("Usage of %s:\n", os.Args[0])
-		fmt.Println("Pack/unpack MGSV:TPP file formats.")
-		fmt.Println()
-		fmt.Println("Unpack (short syntax):")
-		fmt.Printf("\t%s file.dat [dictionary.txt]\n", os.Args[0])
-		fmt.Printf("\t%s file.dat [output dir] [dictionary.txt]\n", os.Args[0])
-		fmt.Printf("\t%s file.fpk [output dir]\n", os.Args[0])
-		fmt.Printf("\t%s file.fox2 [output file]\n", os.Args[0])
-		fmt.Printf("\t%s file.lng2 [output file] [dictionary.txt]\n", os.Args[0])
-		fmt.Println()
-		fmt.Println("Pack (short syntax):")
-		fmt.Printf("\t%s definition.json [output file] [input dir]\n", os.Args[0])
-		fmt.Printf("\t%s file.fox2.xml [output file]\n", os.Args[0])
-		fmt.Printf("\t%s file.lng2.json [output file]\n", os.Args[0])
-		fmt.Println()
-		fmt.Println("Options:")
-		flag.PrintDefaults()
-		fmt.Println()
-		fmt.Println("Tips:")
-		fmt.Printf("  - Get dictionary.txt from %s\n", dictUrl)
-		fmt.Printf("  - Create empty dictionary.txt to skip filename resolution.\n")
-	}
-
-	flag.Parse()
-
-	if *printVer {
-		v, err := util.GetVersion()
-		if err != nil {
-			slog.Error(err.Error())
-			os.Exit(1)
-		}
+		return err
+	}
 
-		slog.Info("version", "commit", v.Commit, "build date", v.Date, "dirty", fmt.Sprintf("%t", v.Dirty))
-		os.Exit(0)
+	descName := path + ".json"
+	desc, err := os.OpenFile(descName, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open definition file %s for writing: %w", descName, err)
 	}
+	defer desc.Close()
 
-	if len(os.Args) > 1 {
-		if strings.HasSuffix(os.Args[1], ".dat") {
-			dp := fp
-			if len(os.Args) > 2 {
-				if strings.HasSuffix(os.Args[2], ".txt") {
-					dp = os.Args[2]
-				} else {
-					*out = os.Args[2]
-				}
-			}
+	def := f
+	if subsetDefinition {
+		def = f.Subset(filter)
+	}
 
-			if len(os.Args) > 3 {
-				if strings.HasSuffix(os.Args[3], ".txt") {
-					dp = os.Args[3]
-				}
-			}
+	if err = def.SaveDefinition(desc); err != nil {
+		return fmt.Errorf("cannot save definition to %s: %w", descName, err)
+	}
 
-			if err = ExtractQar(os.Args[1], dp, *out); err != nil {
-				slog.Error("extract failed", "error", err.Error())
-				os.Exit(1)
-			}
+	return nil
+}
 
-			return
-		}
+func PackFpk(jsonDefinitionPath string, outPath string, inputDir string) error {
+	q, err := archive.ReadFpkDefinition(jsonDefinitionPath)
+	if err != nil {
+		return err
+	}
 
-		if strings.HasSuffix(os.Args[1], ".json") {
-			jj := jsonInput{}
-			data, err := os.ReadFile(os.Args[1])
-			if err != nil {
-				slog.Error("parse json", "error", err.Error())
-				os.Exit(1)
-			}
-
-			if err = json.Unmarshal(data, &jj); err != nil {
-				slog.Error("unmarshal json", "error", err.Error())
-				os.Exit(1)
-			}
-
-			if len(os.Args) > 2 {
-				if !strings.HasPrefix(os.Args[2], "-") {
-					*out = os.Args[2]
-				}
-			}
-
-			if len(os.Args) > 3 {
-				if !strings.HasPrefix(os.Args[3], "-") {
-					*inputDir = os.Args[3]
-				}
-			}
-
-			switch jj.Type {
-			case fpk.FpkdID, fpk.FpkID:
-				slog.Info("fpk")
-				if err = PackFpk(os.Args[1], *out, *inputDir); err != nil {
-					slog.Error("pack failed", "error", err.Error())
-					os.Exit(1)
-				}
-			case qar.QarID:
-				if err = PackQar(os.Args[1], *out, *inputDir); err != nil {
-					slog.Error("pack failed", "error", err.Error())
-					os.Exit(1)
-				}
-			case lng.LngID:
-				slog.Info("compiling lng")
-				if len(os.Args) > 2 {
-					if !strings.HasPrefix(os.Args[2], "-") {
-						*out = os.Args[2]
-					}
-				}
-				if err = CompileLng(os.Args[1], *out); err != nil {
-					slog.Error("lng compilation failed", "error", err.Error())
-					os.Exit(1)
-				}
-			default:
-				slog.Error("unknown type", "type", jj.Type)
-				os.Exit(1)
-			}
+	if outPath == "" {
+		ext := filepath.Ext(jsonDefinitionPath)
+		outPath = strings.TrimSuffix(jsonDefinitionPath, ext)
+	}
 
-			return
-		}
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("open fpk file for writing: %w", err)
+	}
+	defer out.Close()
 
-		if strings.HasSuffix(os.Args[1], ".fpk") || strings.HasSuffix(os.Args[1], ".fpkd") {
-			slog.Info("extracting fpk(d)")
-			if err = ExtractFpk(os.Args[1], *out); err != nil {
-				slog.Error("extract failed", "error", err.Error())
-				os.Exit(1)
-			}
+	if inputDir == "" {
+		format := detect.Fpk
+		if q.Header.IsFpkd() {
+			format = detect.Fpkd
 		}
+		inputDir = archive.UnpackedDir(strings.TrimSuffix(jsonDefinitionPath, ".json"), format)
+	}
+	slog.Info("input", "directory", inputDir, "output", outPath)
 
-		if strings.HasSuffix(os.Args[1], ".fox2") {
-			slog.Info("decompiling fox2")
-			if len(os.Args) > 2 {
-				if !strings.HasPrefix(os.Args[2], "-") {
-					*out = os.Args[2]
-				}
-			}
-			if err = DecompileFox2(os.Args[1], *out); err != nil {
-				slog.Error("fox2 decompilation failed", "error", err.Error())
-				os.Exit(1)
-			}
-		}
+	return archive.PackFpk(q, out, archive.PackOptions{
+		InputDir: inputDir,
+		Logger:   slog.Default(),
+		Progress: logProgress("entry"),
+	})
+}
 
-		if strings.HasSuffix(os.Args[1], ".fox2.xml") {
-			slog.Info("compiling fox2")
-			if len(os.Args) > 2 {
-				if !strings.HasPrefix(os.Args[2], "-") {
-					*out = os.Args[2]
-				}
-			}
-			if err = CompileFox2(os.Args[1], *out); err != nil {
-				slog.Error("fox2 compilation failed", "error", err.Error())
-				os.Exit(1)
-			}
-		}
+// prepareOutDir creates outDir if it does not exist, empty outDir is left as is
+func prepareOutDir(outDir string) error {
+	if outDir == "" {
+		return nil
+	}
 
-		if strings.HasSuffix(os.Args[1], ".lng2") {
-			slog.Info("decompiling lng")
-			if len(os.Args) > 2 {
-				if !strings.HasPrefix(os.Args[2], "-") {
-					*out = os.Args[2]
-				}
-			}
-
-			lngFp := filepath.Join(filepath.Dir(exePath), lngDictionaryName)
-			if len(os.Args) > 3 {
-				lngFp = os.Args[3]
-			}
-
-			if err = DecompileLng(os.Args[1], lngFp, *out); err != nil {
-				slog.Error("lng decompilation failed", "error", err.Error())
-				os.Exit(1)
-			}
+	o, err := os.Stat(outDir)
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(outDir, 0755); err != nil {
+			return fmt.Errorf("cannot create outdir: %w", err)
 		}
-	} else {
-		flag.Usage()
-		os.Exit(0)
+		return nil
 	}
 
-	if *datPath != "" {
-		if err = ExtractQar(*datPath, *dictPath, ""); err != nil {
-			slog.Error("extract failed", "error", err.Error())
-			os.Exit(1)
-		}
-		return
+	if err != nil {
+		return fmt.Errorf("outdir: %w", err)
 	}
 
-	if *jsonPath != "" {
-		if err = PackQar(*jsonPath, *out, *inputDir); err != nil {
-			slog.Error("pack failed", "error", err.Error())
-			os.Exit(1)
-		}
-		return
+	if !o.IsDir() {
+		return fmt.Errorf("not a directory: %s", outDir)
 	}
+
+	return nil
 }
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+)
+
+func TestExtractQar_Partial(t *testing.T) {
+	q := &qar.Qar{Flags: 3150304, Version: 1}
+	copy(q.Magic[:], "SQAR")
+	q.Entries = []qar.Entry{
+		{Header: qar.EntryHeader{FilePath: "/Assets/a.lua"}, Data: []byte("a")},
+		{Header: qar.EntryHeader{FilePath: "/Assets/b.lua"}, Data: []byte("b")},
+	}
+
+	out := &util.ByteArrayReaderWriter{}
+	if err := q.Write(out, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dir := t.TempDir()
+	datPath := filepath.Join(dir, "test.dat")
+	if err := os.WriteFile(datPath, out.Bytes(), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dict := dictionary.NewManager()
+	if err := dict.Read(dictionary.PathCode64, strings.NewReader("/Assets/a\n/Assets/b"), "test"); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	// directory in place of b.lua fails its extraction
+	outDir := filepath.Join(dir, "out")
+	if err := os.MkdirAll(filepath.Join(outDir, "Assets", "b.lua"), 0755); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	err := ExtractQar(datPath, dict, outDir, 1, nil, false)
+	var ee qar.ExtractErrors
+	if !errors.As(err, &ee) || len(ee) != 1 || ee[0].Path != "/Assets/b.lua" {
+		t.Fatalf("want extraction error for b.lua, have %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(outDir, "Assets", "a.lua")); err != nil || string(data) != "a" {
+		t.Fatalf("a.lua is not extracted: %v", err)
+	}
+
+	def, err := os.ReadFile(datPath + ".json")
+	if err != nil {
+		t.Fatalf("definition is not saved: %s", err.Error())
+	}
+
+	for _, p := range []string{"/Assets/a.lua", "/Assets/b.lua"} {
+		if !strings.Contains(string(def), p) {
+			t.Errorf("no %s in definition", p)
+		}
+	}
+}
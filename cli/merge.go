@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+)
+
+// selectFlags are repeatable entity selectors, entity is selected if any of them matches
+type selectFlags struct {
+	names     stringList
+	classes   stringList
+	addresses stringList
+}
+
+func addSelectFlags(fs *flag.FlagSet) *selectFlags {
+	s := &selectFlags{}
+	fs.Var(&s.names, "name", "select entities by name or ref, glob, repeatable")
+	fs.Var(&s.classes, "class", "select entities by class, glob, repeatable")
+	fs.Var(&s.addresses, "addr", "select entity by hex address, repeatable")
+
+	return s
+}
+
+func (s *selectFlags) empty() bool {
+	return len(s.names) == 0 && len(s.classes) == 0 && len(s.addresses) == 0
+}
+
+// predicate returns entity selector, patterns are checked before use
+func (s *selectFlags) predicate() (func(e *fox2.Entity) bool, error) {
+	for _, p := range append(append(stringList{}, s.names...), s.classes...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, usagef("bad pattern %q: %s", p, err.Error())
+		}
+	}
+
+	addresses := map[uint64]bool{}
+	for _, a := range s.addresses {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(a), "0x"), 16, 64)
+		if err != nil {
+			return nil, usagef("bad address %q", a)
+		}
+		addresses[v] = true
+	}
+
+	match := func(patterns stringList, v string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, v); ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(e *fox2.Entity) bool {
+		return addresses[e.Header.Address] || match(s.names, e.Symbol()) || match(s.classes, e.ClassNameString)
+	}, nil
+}
+
+// writeFox2 writes fox2 xml if out has .xml extension, binary fox2 otherwise
+func writeFox2(f *fox2.Fox2, out string) error {
+	file, err := os.OpenFile(out, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(strings.ToLower(out), ".xml") {
+		return f.ToXML(file)
+	}
+
+	return f.Write(file)
+}
+
+// MergeFox2 copies entities of source selected by opts into target and writes result to out.
+func MergeFox2(target, source string, names fox.Names, opts fox2.MergeOptions, out string) error {
+	a, err := readFox2(target, names)
+	if err != nil {
+		return err
+	}
+
+	b, err := readFox2(source, names)
+	if err != nil {
+		return err
+	}
+
+	res, err := fox2.Merge(a, b, opts)
+	if err != nil {
+		return fmt.Errorf("merge %s into %s: %w", source, target, err)
+	}
+
+	slog.Info("merge", "target", target, "source", source, "copied", len(res.Entities)-len(a.Entities), "out", out)
+
+	return writeFox2(res, out)
+}
+
+// SplitFox2 writes entities of file matching predicate with their dependencies to out.
+func SplitFox2(in string, names fox.Names, predicate func(e *fox2.Entity) bool, out string) error {
+	f, err := readFox2(in, names)
+	if err != nil {
+		return err
+	}
+
+	res, err := fox2.Extract(f, predicate)
+	if err != nil {
+		return fmt.Errorf("split %s: %w", in, err)
+	}
+
+	slog.Info("split", "path", in, "entities", len(res.Entities), "out", out)
+
+	return writeFox2(res, out)
+}
+
+func setupMerge(fs *flag.FlagSet, program string) func(args []string) error {
+	sel := addSelectFlags(fs)
+	renumber := fs.Bool("renumber", false, "renumber entity addresses and ids of result")
+	dicts := addDictFlags(fs, program)
+	addFoxDictFlag(fs, dicts)
+
+	return func(args []string) error {
+		if len(args) != 3 {
+			return usagef("want target, source and output files, got %d arguments", len(args))
+		}
+
+		opts := fox2.MergeOptions{Renumber: *renumber}
+		if !sel.empty() {
+			predicate, err := sel.predicate()
+			if err != nil {
+				return err
+			}
+			opts.Select = predicate
+		}
+
+		dict, err := dicts.load(dictionary.StrCode64)
+		if err != nil {
+			return err
+		}
+
+		return MergeFox2(args[0], args[1], dict.Fox(), opts, args[2])
+	}
+}
+
+func setupSplit(fs *flag.FlagSet, program string) func(args []string) error {
+	sel := addSelectFlags(fs)
+	dicts := addDictFlags(fs, program)
+	addFoxDictFlag(fs, dicts)
+
+	return func(args []string) error {
+		if len(args) != 2 {
+			return usagef("want input and output files, got %d arguments", len(args))
+		}
+
+		if sel.empty() {
+			return usagef("want at least one of -name, -class, -addr")
+		}
+
+		predicate, err := sel.predicate()
+		if err != nil {
+			return err
+		}
+
+		dict, err := dicts.load(dictionary.StrCode64)
+		if err != nil {
+			return err
+		}
+
+		return SplitFox2(args[0], dict.Fox(), predicate, args[1])
+	}
+}
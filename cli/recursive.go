@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/unknown321/datfpk/archive"
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+)
+
+// ExtractRecursive unpacks containers found in dir by magic: dat/qar and fpk/fpkd files are extracted next to them
+// with definitions, fox2 and lng2 files are decompiled. Containers found in extracted files are processed too.
+// Original files are kept, files made from them get their modification time, see keepModTime.
+func ExtractRecursive(dir string, dict *dictionary.Manager, workers int) error {
+	queue := []string{dir}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		var files []string
+		err := filepath.WalkDir(current, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("walk %s: %w", current, err)
+		}
+
+		for _, path := range files {
+			format, err := detect.File(path)
+			if err != nil {
+				return fmt.Errorf("detect format of %s: %w", path, err)
+			}
+
+			switch format {
+			case detect.Qar:
+				slog.Info("recursive", "extract", path)
+				if err = ExtractQar(path, dict, "", workers, nil, false); err != nil {
+					return fmt.Errorf("extract %s: %w", path, err)
+				}
+				queue = append(queue, archive.UnpackedDir(path, format))
+			case detect.Fpk, detect.Fpkd:
+				slog.Info("recursive", "extract", path)
+				if err = ExtractFpk(path, "", nil, false); err != nil {
+					return fmt.Errorf("extract %s: %w", path, err)
+				}
+				queue = append(queue, archive.UnpackedDir(path, format))
+			case detect.Fox2:
+				slog.Info("recursive", "decompile", path)
+				if err = DecompileFox2(path, dict.Fox(), ""); err != nil {
+					return fmt.Errorf("decompile %s: %w", path, err)
+				}
+			case detect.Lng:
+				slog.Info("recursive", "decompile", path)
+				if err = DecompileLng(path, dict.Lng(), ""); err != nil {
+					return fmt.Errorf("decompile %s: %w", path, err)
+				}
+			default:
+				continue
+			}
+
+			if err = keepModTime(path, format); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unpackedFiles returns definition of original and, for archives, its unpacked directory
+func unpackedFiles(original string, format detect.Format) []string {
+	switch format {
+	case detect.Qar, detect.Fpk, detect.Fpkd:
+		return []string{original + ".json", archive.UnpackedDir(original, format)}
+	case detect.Lng:
+		return []string{original + ".json"}
+	case detect.Fox2:
+		return []string{original + ".xml"}
+	}
+
+	return nil
+}
+
+// keepModTime sets modification time of files unpacked from original to its own, so unchanged files are not
+// rebuilt by PackRecursive
+func keepModTime(original string, format detect.Format) error {
+	info, err := os.Stat(original)
+	if err != nil {
+		return err
+	}
+
+	for _, root := range unpackedFiles(original, format) {
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			return os.Chtimes(path, info.ModTime(), info.ModTime())
+		})
+		if err != nil {
+			return fmt.Errorf("set modification time: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// changed is true if any file unpacked from original is newer than it, rebuilt nested files are newer too
+func changed(original string, format detect.Format) (bool, error) {
+	info, err := os.Stat(original)
+	if err != nil {
+		return false, err
+	}
+
+	res := false
+	for _, root := range unpackedFiles(original, format) {
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			// directories change when nested files are unpacked
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			if fi.ModTime().After(info.ModTime()) {
+				res = true
+				return fs.SkipAll
+			}
+
+			return nil
+		})
+		if err != nil || res {
+			return res, err
+		}
+	}
+
+	return false, nil
+}
+
+// PackRecursive rebuilds files in dir unpacked by ExtractRecursive, deepest first:
+// fox2 and lng2 files are compiled, dat/qar and fpk/fpkd archives are packed from definitions.
+// Only files with both unpacked and original versions of the same format present are rebuilt, and only if
+// definition or unpacked files are newer than original, see changed.
+func PackRecursive(dir string, fox2Opts archive.Fox2Options, workers int, passthrough bool) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() && (strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".xml")) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk %s: %w", dir, err)
+	}
+
+	depth := func(path string) int {
+		return strings.Count(filepath.ToSlash(path), "/")
+	}
+
+	// contents of unpacked archive are always deeper than its definition
+	slices.SortStableFunc(files, func(a, b string) int {
+		return depth(b) - depth(a)
+	})
+
+	for _, path := range files {
+		original := strings.TrimSuffix(strings.TrimSuffix(path, ".json"), ".xml")
+		if _, err = os.Stat(original); err != nil {
+			continue
+		}
+
+		var format detect.Format
+		if format, err = detect.File(path); err != nil {
+			return fmt.Errorf("detect format of %s: %w", path, err)
+		}
+
+		if !format.IsDefinition() {
+			continue
+		}
+
+		// skip definitions of files which are not going to be replaced
+		if originalFormat, err := detect.File(original); err != nil || originalFormat != format.Binary() {
+			continue
+		}
+
+		var ok bool
+		if ok, err = changed(original, format.Binary()); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if !ok {
+			slog.Debug("recursive", "unchanged", original)
+			continue
+		}
+
+		switch format {
+		case detect.Fox2XML:
+			slog.Info("recursive", "compile", path)
+			err = CompileFox2(path, fox2Opts, "")
+		case detect.FpkDefinition, detect.FpkdDefinition:
+			slog.Info("recursive", "pack", path)
+			err = PackFpk(path, "", "")
+		case detect.QarDefinition:
+			slog.Info("recursive", "pack", path)
+			err = PackQar(path, "", "", workers, passthrough)
+		case detect.LngDefinition:
+			slog.Info("recursive", "compile", path)
+			err = CompileLng(path, "")
+		}
+
+		if err != nil {
+			return fmt.Errorf("pack %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unknown321/datfpk/archive"
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/util"
+)
+
+func TestRecursive(t *testing.T) {
+	fpkData, err := os.ReadFile("../fpk/testdata/wfv_camo_c45.fpk")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	// fox2 compilation is not byte exact, unchanged fox2 must not be rebuilt
+	fpkdData, err := os.ReadFile("../fpk/testdata/o50050_subtitles.fpkd")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	lua, err := os.ReadFile("../fpk/testdata/mission_main.lua")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	q := &qar.Qar{Flags: 3150304, Version: 1}
+	copy(q.Magic[:], "SQAR")
+	q.Entries = []qar.Entry{
+		{Header: qar.EntryHeader{FilePath: "/Assets/tpp/pack/wfv_camo_c45.fpk"}, Data: fpkData},
+		{Header: qar.EntryHeader{FilePath: "/Assets/tpp/pack/o50050_subtitles.fpkd"}, Data: fpkdData},
+		{Header: qar.EntryHeader{FilePath: "/Assets/tpp/script/mission_main.lua", Compressed: true}, Data: lua},
+	}
+
+	out := &util.ByteArrayReaderWriter{}
+	if err = q.Write(out, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dir := t.TempDir()
+	datPath := filepath.Join(dir, "test.dat")
+	if err = os.WriteFile(datPath, out.Bytes(), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dict := dictionary.NewManager()
+	// dictionary paths have no extension
+	names := "/Assets/tpp/pack/wfv_camo_c45\n/Assets/tpp/pack/o50050_subtitles\n/Assets/tpp/script/mission_main"
+	if err = dict.Read(dictionary.PathCode64, strings.NewReader(names), "test"); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if err = ExtractRecursive(dir, dict, 2); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	unpacked := archive.UnpackedDir(datPath, detect.Qar)
+	fpkPath := filepath.Join(unpacked, "Assets/tpp/pack/wfv_camo_c45.fpk")
+	if _, err = os.Stat(archive.UnpackedDir(fpkPath, detect.Fpk)); err != nil {
+		t.Fatalf("nested fpk is not extracted: %s", err.Error())
+	}
+
+	fpkdPath := filepath.Join(unpacked, "Assets/tpp/pack/o50050_subtitles.fpkd")
+	fox2Path := filepath.Join(archive.UnpackedDir(fpkdPath, detect.Fpkd),
+		"Assets/tpp/ui/Subtitles/package/EngVoice/EngText/o50050_subtitles.fox2")
+	if _, err = os.Stat(fox2Path + ".xml"); err != nil {
+		t.Fatalf("nested fox2 is not decompiled: %s", err.Error())
+	}
+
+	fox2Data, err := os.ReadFile(fox2Path)
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	want := map[string][]byte{datPath: out.Bytes(), fpkPath: fpkData, fpkdPath: fpkdData, fox2Path: fox2Data}
+	check := func() {
+		t.Helper()
+		for path, data := range want {
+			have, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !bytes.Equal(have, data) {
+				t.Errorf("%s: repacked data differs", filepath.Base(path))
+			}
+		}
+	}
+
+	// nothing is changed, nothing is rebuilt
+	if err = PackRecursive(dir, archive.Fox2Options{}, 2, false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	check()
+
+	// files older than unpacked ones are rebuilt from them, not copied; header is kept for format detection.
+	// Rebuilt fpk is newer than dat, dat is rebuilt too; fpkd is not changed.
+	old := time.Now().Add(-time.Hour)
+	for _, path := range []string{fpkPath, datPath} {
+		if err = os.WriteFile(path, want[path][:32], 0644); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if err = os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+	}
+
+	if err = PackRecursive(dir, archive.Fox2Options{}, 2, false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	check()
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/qar"
+	"github.com/unknown321/datfpk/resolve"
+)
+
+// ResolveQar looks for paths of dat/qar entries missing from dictionary using templates and strings harvested
+// from files in harvest dirs. Recovered paths are written to delta in dictionary format.
+func ResolveQar(path string, dict *dictionary.Manager, templates []string, harvest []string, delta io.Writer) error {
+	var err error
+	q := qar.Qar{}
+	if err = q.ReadFrom(path); err != nil {
+		return fmt.Errorf("QAR read error: %w", err)
+	}
+	defer q.Close()
+
+	// only unresolved entries are looked for, shared paths do not matter
+	_ = q.Resolve(dict.GetByHash)
+	unresolved := resolve.Unresolved(&q, dict.GetByHash)
+	slog.Info("resolve", "entries", len(q.Entries), "unresolved", len(unresolved))
+	if len(unresolved) == 0 {
+		return nil
+	}
+
+	r := resolve.New(unresolved)
+	for _, t := range templates {
+		if err = r.Template(t); err != nil {
+			return usagef("%s", err.Error())
+		}
+	}
+
+	for _, dir := range harvest {
+		if err = r.Harvest(dir); err != nil {
+			return fmt.Errorf("harvest %s: %w", dir, err)
+		}
+	}
+
+	for hash, name := range r.Found() {
+		slog.Info("resolved", "hash", fmt.Sprintf("%x", hash), "path", name)
+	}
+	slog.Info("resolve", "candidates", r.Tried, "resolved", len(r.Found()), "unresolved", len(unresolved)-len(r.Found()))
+
+	return r.WriteDelta(delta)
+}
+
+func setupResolve(fs *flag.FlagSet, program string) func(args []string) error {
+	dicts := addDictFlags(fs, program)
+	out := fs.String("out", "", "write recovered paths to file instead of stdout")
+	var templates, harvest stringList
+	fs.Var(&templates, "template", "try paths from template, {NN} is 00-99, {a|b} is a or b, repeatable")
+	fs.Var(&harvest, "harvest", "try paths found in fox2, lua, xml and json files of directory, repeatable")
+
+	return func(args []string) error {
+		if len(args) != 1 {
+			return usagef("want 1 dat/qar file, got %d arguments", len(args))
+		}
+
+		if len(templates) == 0 && len(harvest) == 0 {
+			return usagef("want at least one -template or -harvest")
+		}
+
+		dict, err := dicts.load(dictionary.PathCode64)
+		if err != nil {
+			return err
+		}
+
+		if *out == "" {
+			return ResolveQar(args[0], dict, templates, harvest, os.Stdout)
+		}
+
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+
+		if err = ResolveQar(args[0], dict, templates, harvest, f); err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		return f.Close()
+	}
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/util"
+)
+
+// shortFlags are flags of short syntax: `datfpk file.dat` is `datfpk unpack file.dat`,
+// `datfpk file.dat.json` is `datfpk pack file.dat.json`
+type shortFlags struct {
+	datPath     *string
+	dict        *dictFlags
+	out         *string
+	jsonPath    *string
+	inputDir    *string
+	printVer    *bool
+	jobs        *int
+	recursive   *bool
+	subset      *bool
+	passthrough *bool
+	filter      *filterFlags
+}
+
+func newShortFlags(program string) (*flag.FlagSet, *shortFlags) {
+	fs := flag.NewFlagSet(program, flag.ContinueOnError)
+	f := &shortFlags{}
+
+	f.datPath = fs.String("dat", "", "path to dat/qar file")
+	f.dict = addDictFlags(fs, program)
+	addFoxDictFlag(fs, f.dict)
+	f.out = fs.String("out", "", "output file/directory (default <filename>_<extension>/)")
+	f.jsonPath = fs.String("json", "", "path to qar definition file (.json)")
+	f.inputDir = fs.String("in", "", "input directory path (default <jsonFilename>_<extension>/)")
+	f.printVer = fs.Bool("version", false, "print version")
+	f.jobs = fs.Int("j", runtime.NumCPU(), "number of parallel dat/qar workers")
+	f.recursive = fs.Bool("recursive", false, "unpack nested containers found by magic, pack them back bottom-up")
+	f.subset = fs.Bool("subset", false, "save definition of extracted entries only")
+	f.filter = addFilterFlags(fs)
+	f.passthrough = fs.Bool("passthrough", false, "pack unmodified dat/qar entries from original archive as is")
+
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Printf("Usage of %s:\n", program)
+		fmt.Println("Pack/unpack MGSV:TPP file formats.")
+		fmt.Println()
+		fmt.Println("Commands:")
+		for _, c := range commands {
+			fmt.Printf("\t%-8s %s\n", c.name, c.description)
+		}
+		fmt.Println()
+		fmt.Printf("Run '%s <command> -help' for command flags.\n", program)
+		fmt.Println()
+		fmt.Println("Unpack (short syntax):")
+		fmt.Printf("\t%s file.dat [dictionary.txt]\n", program)
+		fmt.Printf("\t%s file.dat [output dir] [dictionary.txt]\n", program)
+		fmt.Printf("\t%s file.fpk [output dir]\n", program)
+		fmt.Printf("\t%s file.fox2 [output file]\n", program)
+		fmt.Printf("\t%s file.lng2 [output file] [dictionary.txt]\n", program)
+		fmt.Println()
+		fmt.Println("Pack (short syntax):")
+		fmt.Printf("\t%s definition.json [output file] [input dir]\n", program)
+		fmt.Printf("\t%s file.fox2.xml [output file]\n", program)
+		fmt.Printf("\t%s file.lng2.json [output file]\n", program)
+		fmt.Println()
+		fmt.Println("Short syntax options:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Filters:")
+		fmt.Println("\tUnpack and list only entries matching any -include glob or -hash and no -exclude glob,")
+		fmt.Println("\tfor example -include '/Assets/tpp/pack/**/*.fpkd' -exclude '*.ftexs' -hash 0x38dd243657e7f.")
+		fmt.Println("\tFlags may be repeated. Globs without slash match file name, ** matches any number of directories.")
+		fmt.Println()
+		fmt.Println("Exit codes:")
+		fmt.Printf("\t%d success\n", ExitOK)
+		fmt.Printf("\t%d error\n", ExitError)
+		fmt.Printf("\t%d bad command line\n", ExitUsage)
+		fmt.Printf("\t%d verify found corrupt entries\n", ExitCorrupt)
+		fmt.Printf("\t%d diff found differences\n", ExitDifferent)
+		fmt.Printf("\t%d validate found invalid entities or references\n", ExitInvalid)
+		fmt.Println()
+		fmt.Println("Tips:")
+		fmt.Printf("  - Get dictionary.txt from %s\n", dictUrl)
+		fmt.Printf("  - Create empty dictionary.txt to skip filename resolution.\n")
+		fmt.Printf("  - Combine dictionaries with repeated -dict, for example -dict mine.txt -dict qar:dictionary.txt.gz -dict lng:lng/\n")
+		fmt.Printf("  - File format is detected by content, extension is used only if content is not recognized.\n")
+	}
+
+	return fs, f
+}
+
+// runShort handles short syntax: format of the first argument decides whether it is packed or unpacked
+func runShort(program string, args []string) int {
+	fs, f := newShortFlags(program)
+	positional, err := parseSubcommand(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	if err != nil {
+		return ExitUsage
+	}
+
+	if *f.printVer {
+		v, err := util.GetVersion()
+		if err != nil {
+			slog.Error(err.Error())
+			return ExitError
+		}
+
+		slog.Info("version", "commit", v.Commit, "build date", v.Date, "dirty", fmt.Sprintf("%t", v.Dirty))
+		return ExitOK
+	}
+
+	if len(positional) == 0 && fs.NFlag() == 0 {
+		fs.Usage()
+		return ExitOK
+	}
+
+	return exitCode(filepath.Base(program), f.run(positional), fs.Usage)
+}
+
+func (f *shortFlags) run(args []string) error {
+	filter, err := f.filter.filter()
+	if err != nil {
+		return usagef("bad filter: %s", err.Error())
+	}
+
+	unpackOpts := unpackOptions{
+		out:       *f.out,
+		dict:      f.dict,
+		workers:   *f.jobs,
+		recursive: *f.recursive,
+		subset:    *f.subset,
+		filter:    filter,
+	}
+
+	packOpts := packOptions{
+		out:         *f.out,
+		inputDir:    *f.inputDir,
+		workers:     *f.jobs,
+		recursive:   *f.recursive,
+		passthrough: *f.passthrough,
+		schema:      &schemaFlags{},
+	}
+
+	if len(args) == 0 {
+		switch {
+		case *f.datPath != "":
+			unpackOpts.path = *f.datPath
+			unpackOpts.out = ""
+			return unpack(unpackOpts)
+		case *f.jsonPath != "":
+			packOpts.path = *f.jsonPath
+			return pack(packOpts)
+		}
+
+		return usagef("no input file")
+	}
+
+	format, err := detect.Guess(args[0])
+	if err != nil {
+		return fmt.Errorf("cannot detect file format of %s: %w", args[0], err)
+	}
+
+	// optional positional output path and dictionary or input directory
+	positional := func(i int) string {
+		if len(args) > i {
+			return args[i]
+		}
+		return ""
+	}
+
+	switch format {
+	case detect.Qar:
+		unpackOpts.path = args[0]
+		if len(args) > 1 {
+			if strings.HasSuffix(args[1], ".txt") {
+				f.dict.prepend(dictionary.PathCode64, args[1])
+			} else {
+				unpackOpts.out = args[1]
+			}
+		}
+		if len(args) > 2 && strings.HasSuffix(args[2], ".txt") {
+			f.dict.prepend(dictionary.PathCode64, args[2])
+		}
+		return unpack(unpackOpts)
+	case detect.Lng:
+		unpackOpts.path = args[0]
+		if v := positional(1); v != "" {
+			unpackOpts.out = v
+		}
+		if len(args) > 2 {
+			f.dict.prepend(dictionary.StrCode32, args[2])
+		}
+		return unpack(unpackOpts)
+	case detect.Fpk, detect.Fpkd, detect.Fox2:
+		unpackOpts.path = args[0]
+		if v := positional(1); v != "" {
+			unpackOpts.out = v
+		}
+		return unpack(unpackOpts)
+	case detect.QarDefinition, detect.FpkDefinition, detect.FpkdDefinition:
+		packOpts.path = args[0]
+		if v := positional(1); v != "" {
+			packOpts.out = v
+		}
+		if v := positional(2); v != "" {
+			packOpts.inputDir = v
+		}
+		return pack(packOpts)
+	case detect.LngDefinition, detect.Fox2XML:
+		packOpts.path = args[0]
+		if v := positional(1); v != "" {
+			packOpts.out = v
+		}
+		return pack(packOpts)
+	}
+
+	return fmt.Errorf("unknown file format: %s", args[0])
+}
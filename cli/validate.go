@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
+)
+
+// schemaFlags is a repeatable -schema flag, files override embedded class definitions
+type schemaFlags struct {
+	sources  stringList
+	validate bool
+	strict   bool
+}
+
+// addSchemaFlags registers -schema and -strict, and -validate if validation is optional
+func addSchemaFlags(fs *flag.FlagSet, optional bool) *schemaFlags {
+	s := &schemaFlags{validate: !optional}
+	fs.Var(&s.sources, "schema", "fox2 class definitions xml overriding embedded ones, repeatable, last has priority")
+	if optional {
+		fs.BoolVar(&s.validate, "validate", false, "validate fox2 entities against class definitions")
+	}
+	fs.BoolVar(&s.strict, "strict", false, "fail validation on fox2 classes missing from class definitions")
+
+	return s
+}
+
+// load returns embedded class definitions overridden by -schema files
+func (s *schemaFlags) load() (*schema.Registry, error) {
+	reg, err := schema.Default()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range s.sources {
+		if err = reg.Load(path); err != nil {
+			return nil, fmt.Errorf("cannot read schema: %w", err)
+		}
+	}
+
+	slog.Debug("schema", "classes", reg.Len(), "sources", s.sources.String())
+
+	return reg, nil
+}
+
+// readFox2 reads fox2 or fox2 xml file
+func readFox2(path string, names fox.Names) (*fox2.Fox2, error) {
+	format, err := detect.Guess(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect file format of %s: %w", path, err)
+	}
+
+	if format != detect.Fox2 && format != detect.Fox2XML {
+		return nil, usagef("%s is a %s file, want fox2 or fox2 xml", path, format)
+	}
+
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	f := &fox2.Fox2{}
+	if format == detect.Fox2 {
+		if err = f.Read(input, names); err != nil {
+			return nil, fmt.Errorf("read fox2: %w", err)
+		}
+	} else if err = f.FromXML(input); err != nil {
+		return nil, fmt.Errorf("read fox2 xml: %w", err)
+	}
+
+	return f, nil
+}
+
+// ValidateFox2 checks static properties of every entity of fox2 or fox2 xml file against reg and references
+// between entities. Classes missing from reg are reported and skipped, unreachable entities are reported.
+// ErrInvalid is returned if any entity does not match, reference is broken or, if strict is set, class is
+// missing from reg.
+func ValidateFox2(path string, names fox.Names, reg *schema.Registry, strict bool) error {
+	f, err := readFox2(path, names)
+	if err != nil {
+		return err
+	}
+
+	unknown := map[string]bool{}
+	invalid := 0
+	for _, e := range f.Entities {
+		if len(reg.Versions(e.ClassNameString)) == 0 {
+			if !unknown[e.ClassNameString] {
+				log := slog.Warn
+				if strict {
+					log = slog.Error
+				}
+				log("class not in schema", "path", path, "class", e.ClassNameString)
+			}
+			unknown[e.ClassNameString] = true
+			continue
+		}
+
+		var ve *fox2.ValidationError
+		if err = e.Validate(reg); !errors.As(err, &ve) {
+			continue
+		}
+
+		invalid++
+		for _, m := range ve.Mismatches {
+			slog.Error("mismatch",
+				"path", path,
+				"entity", fmt.Sprintf("0x%X", ve.Entity),
+				"class", ve.Class,
+				"version", ve.Version,
+				"property", m.Property,
+				"problem", string(m.Problem),
+				"want", m.Want,
+				"have", m.Have,
+			)
+		}
+	}
+
+	g := fox2.NewGraph(f)
+	broken := 0
+	for _, e := range g.Dangling() {
+		broken++
+		slog.Error("dangling reference",
+			"path", path,
+			"entity", fmt.Sprintf("0x%X", e.From),
+			"property", e.Property,
+			"key", e.Key,
+			"kind", string(e.Kind),
+			"to", fmt.Sprintf("0x%X", e.To),
+		)
+	}
+
+	for _, a := range g.Duplicates() {
+		broken++
+		slog.Error("duplicate address", "path", path, "entity", fmt.Sprintf("0x%X", a))
+	}
+
+	for _, n := range g.Unreachable() {
+		slog.Warn("unreachable entity", "path", path, "entity", fmt.Sprintf("0x%X", n.Address), "class", n.Class, "name", n.Name)
+	}
+
+	slog.Info("validate", "path", path, "entities", len(f.Entities), "invalid", invalid, "brokenReferences", broken,
+		"unknownClasses", len(unknown))
+
+	if invalid > 0 || broken > 0 {
+		return fmt.Errorf("%s: %d invalid entities, %d broken references: %w", path, invalid, broken, ErrInvalid)
+	}
+
+	if strict && len(unknown) > 0 {
+		return fmt.Errorf("%s: %d classes not in schema: %w", path, len(unknown), ErrInvalid)
+	}
+
+	return nil
+}
+
+func setupValidate(fs *flag.FlagSet, program string) func(args []string) error {
+	schemas := addSchemaFlags(fs, false)
+	dicts := addDictFlags(fs, program)
+	addFoxDictFlag(fs, dicts)
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return usagef("want at least 1 fox2 file")
+		}
+
+		reg, err := schemas.load()
+		if err != nil {
+			return err
+		}
+
+		dict, err := dicts.load(dictionary.StrCode64)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+		for _, path := range args {
+			if err = ValidateFox2(path, dict.Fox(), reg, schemas.strict); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+}
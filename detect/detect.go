@@ -0,0 +1,224 @@
+// Package detect recognizes supported file formats by content.
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fpk"
+	"github.com/unknown321/datfpk/lng"
+	"github.com/unknown321/datfpk/qar"
+)
+
+type Format string
+
+const (
+	Unknown Format = ""
+
+	Qar  Format = "qar"
+	Fpk  Format = "fpk"
+	Fpkd Format = "fpkd"
+	Fox2 Format = "fox2"
+	Lng  Format = "lng"
+
+	// definitions produced by unpacking
+	QarDefinition  Format = "qar definition"
+	FpkDefinition  Format = "fpk definition"
+	FpkdDefinition Format = "fpkd definition"
+	LngDefinition  Format = "lng definition"
+	Fox2XML        Format = "fox2 xml"
+)
+
+// IsDefinition is true for formats produced by unpacking, they are packed back to binary formats.
+func (f Format) IsDefinition() bool {
+	switch f {
+	case QarDefinition, FpkDefinition, FpkdDefinition, LngDefinition, Fox2XML:
+		return true
+	}
+
+	return false
+}
+
+// Binary returns format definition is packed to, binary formats are returned as is.
+func (f Format) Binary() Format {
+	switch f {
+	case QarDefinition:
+		return Qar
+	case FpkDefinition:
+		return Fpk
+	case FpkdDefinition:
+		return Fpkd
+	case LngDefinition:
+		return Lng
+	case Fox2XML:
+		return Fox2
+	}
+
+	return f
+}
+
+const peekSize = 512
+
+// Reader detects format by content: binary magic, `type` field of JSON definition or fox2 XML root element.
+func Reader(reader io.Reader) (Format, error) {
+	r := bufio.NewReaderSize(reader, peekSize)
+	head, err := r.Peek(peekSize)
+	if err != nil && err != io.EOF {
+		return Unknown, err
+	}
+
+	if f := Magic(head); f != Unknown {
+		return f, nil
+	}
+
+	bom := []byte("\xef\xbb\xbf")
+	if bytes.HasPrefix(head, bom) {
+		_, _ = r.Discard(len(bom))
+		head = head[len(bom):]
+	}
+
+	text := bytes.TrimLeft(head, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(text, []byte("{")):
+		return jsonType(r), nil
+	case bytes.HasPrefix(text, []byte("<")):
+		if isFox2XML(text) {
+			return Fox2XML, nil
+		}
+	}
+
+	return Unknown, nil
+}
+
+// Magic detects binary format by first bytes of file.
+func Magic(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, []byte("SQAR")):
+		return Qar
+	case bytes.HasPrefix(head, fpk.MagicFpk[:]):
+		return Fpk
+	case bytes.HasPrefix(head, fpk.MagicFpkd[:]):
+		return Fpkd
+	case len(head) >= 4 && binary.LittleEndian.Uint32(head) == fox2.Magic1:
+		return Fox2
+	case len(head) >= 4 && binary.LittleEndian.Uint32(head) == lng.Magic:
+		return Lng
+	}
+
+	return Unknown
+}
+
+// File detects format of file by content, see Reader.
+func File(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Unknown, err
+	}
+	defer f.Close()
+
+	return Reader(f)
+}
+
+// Guess detects format of file by content, falling back to file extension.
+func Guess(path string) (Format, error) {
+	f, err := File(path)
+	if err != nil || f != Unknown {
+		return f, err
+	}
+
+	return Extension(path), nil
+}
+
+// Extension detects format by file name.
+// JSON definitions cannot be told apart by name, Unknown is returned for them.
+func Extension(path string) Format {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".fox2.xml"):
+		return Fox2XML
+	case strings.HasSuffix(name, ".dat"):
+		return Qar
+	case strings.HasSuffix(name, ".fpk"):
+		return Fpk
+	case strings.HasSuffix(name, ".fpkd"):
+		return Fpkd
+	case strings.HasSuffix(name, ".fox2"):
+		return Fox2
+	case strings.HasSuffix(name, ".lng2"), strings.HasSuffix(name, ".lng"):
+		return Lng
+	}
+
+	return Unknown
+}
+
+// jsonType reads top level `type` field without decoding the whole document
+func jsonType(reader io.Reader) Format {
+	dec := json.NewDecoder(reader)
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return Unknown
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return Unknown
+		}
+
+		if key, _ := t.(string); key != "type" {
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return Unknown
+			}
+			continue
+		}
+
+		var v string
+		if err = dec.Decode(&v); err != nil {
+			return Unknown
+		}
+
+		switch v {
+		case qar.QarID:
+			return QarDefinition
+		case fpk.FpkID:
+			return FpkDefinition
+		case fpk.FpkdID:
+			return FpkdDefinition
+		case lng.LngID:
+			return LngDefinition
+		}
+
+		return Unknown
+	}
+
+	return Unknown
+}
+
+// isFox2XML checks that root element is <fox>, xml declaration and comments are skipped
+func isFox2XML(text []byte) bool {
+	for {
+		text = bytes.TrimLeft(text, " \t\r\n")
+		switch {
+		case bytes.HasPrefix(text, []byte("<?")):
+			end := bytes.Index(text, []byte("?>"))
+			if end < 0 {
+				return false
+			}
+			text = text[end+2:]
+		case bytes.HasPrefix(text, []byte("<!--")):
+			end := bytes.Index(text, []byte("-->"))
+			if end < 0 {
+				return false
+			}
+			text = text[end+3:]
+		default:
+			return bytes.HasPrefix(text, []byte("<fox ")) || bytes.HasPrefix(text, []byte("<fox>"))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package detect
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		path string
+		want Format
+	}{
+		{path: "../qar/testdata/plain.dat", want: Qar},
+		{path: "../fpk/testdata/wfv_camo_c45.fpk", want: Fpk},
+		{path: "../fpk/testdata/title.fpkd", want: Fpkd},
+		{path: "../fox2/testdata/game/title_sequence.fox2", want: Fox2},
+		{path: "../fox2/testdata/game/title_sequence.fox2.xml", want: Fox2XML},
+		{path: "../lng/testdata/tpp_tutorial.eng.lng2", want: Lng},
+		{path: "../lng/testdata/tpp_tutorial.eng.lng2.json", want: LngDefinition},
+		{path: "../fpk/testdata/mission_main.lua", want: Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			got, err := File(tt.path)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if got != tt.want {
+				t.Errorf("File() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Format
+	}{
+		{name: "qar definition", data: `{"type": "qar", "flags": 3150304, "entries": []}`, want: QarDefinition},
+		{name: "type after entries", data: `{"entries": [{"filePath": "/a.lua"}], "type": "fpkd"}`, want: FpkdDefinition},
+		{name: "fpk definition with bom", data: "\xef\xbb\xbf\n {\"type\": \"fpk\"}", want: FpkDefinition},
+		{name: "unknown type", data: `{"type": "zip"}`, want: Unknown},
+		{name: "no type", data: `{"entries": []}`, want: Unknown},
+		{name: "json array", data: `[{"type": "qar"}]`, want: Unknown},
+		{name: "fox2 xml", data: "<?xml version=\"1.0\"?>\n<!-- comment -->\n<fox formatVersion=\"2\">", want: Fox2XML},
+		{name: "other xml", data: `<ArchiveFile Name="a.dat">`, want: Unknown},
+		{name: "empty", data: "", want: Unknown},
+		{name: "short", data: "SQ", want: Unknown},
+		{name: "sqar", data: "SQAR\x00\x00", want: Qar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Reader(bytes.NewReader([]byte(tt.data)))
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if got != tt.want {
+				t.Errorf("Reader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuess(t *testing.T) {
+	dir := t.TempDir()
+
+	// hash-named file detected by content
+	data, err := os.ReadFile("../fpk/testdata/title.fpkd")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	renamed := filepath.Join(dir, "38dd243657e7f")
+	if err = os.WriteFile(renamed, data, 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	// empty file detected by extension
+	empty := filepath.Join(dir, "empty.fox2")
+	if err = os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for path, want := range map[string]Format{renamed: Fpkd, empty: Fox2} {
+		got, err := Guess(path)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if got != want {
+			t.Errorf("Guess(%s) = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+}
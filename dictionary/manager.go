@@ -0,0 +1,279 @@
+package dictionary
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unknown321/hashing"
+)
+
+// Namespace is a hash function dictionary strings are matched with
+type Namespace int
+
+const (
+	// PathCode64 hashes dat/qar entry paths without extension, see hashing.HashFileName
+	PathCode64 Namespace = iota
+	// StrCode32 hashes lng2 keys, it is lower 32 bits of StrCode64
+	StrCode32
+	// StrCode64 hashes fox2 names and string literals
+	StrCode64
+)
+
+// Namespaces in order of declaration
+var Namespaces = []Namespace{PathCode64, StrCode32, StrCode64}
+
+// namespaceNames are prefixes of sources, see ParseSource
+var namespaceNames = map[Namespace]string{
+	PathCode64: "qar",
+	StrCode32:  "lng",
+	StrCode64:  "fox",
+}
+
+func (n Namespace) String() string {
+	if name, ok := namespaceNames[n]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Namespace(%d)", int(n))
+}
+
+// MaxHashLength is the longest input of hashing library, cityhash panics on longer data.
+// It applies to hashed bytes: StrCode64 appends terminating zero, HashFileName trims path first.
+const MaxHashLength = 128
+
+// HashedPath returns part of path hashed by hashing.HashFileName with extension removal:
+// path without /Assets/ prefix, leading slash and extension.
+func HashedPath(path string) string {
+	trimmed := strings.TrimPrefix(path, "/Assets/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	if i := strings.LastIndex(trimmed, "."); i > 0 {
+		trimmed = trimmed[:i]
+	}
+
+	return trimmed
+}
+
+// CanHash is true if s is not empty and fits MaxHashLength after preparation done by namespace hash function
+func (n Namespace) CanHash(s string) bool {
+	switch n {
+	case PathCode64:
+		return s != "" && len(HashedPath(s)) <= MaxHashLength
+	case StrCode32, StrCode64:
+		return s != "" && len(s)+1 <= MaxHashLength
+	}
+
+	return false
+}
+
+// Hash returns hash of s in namespace, false if s cannot be hashed
+func (n Namespace) Hash(s string) (uint64, bool) {
+	if !n.CanHash(s) {
+		return 0, false
+	}
+
+	switch n {
+	case PathCode64:
+		// same as hashing.Dictionary.Read
+		return hashing.HashFileName(s, true) & 0x3FFFFFFFFFFFF, true
+	case StrCode32:
+		return hashing.StrCode64([]byte(s)) & 0xffffffff, true
+	case StrCode64:
+		return hashing.StrCode64([]byte(s)), true
+	}
+
+	return 0, false
+}
+
+// ParseSource splits [qar:|lng:|fox:]path, path without prefix belongs to PathCode64.
+func ParseSource(source string) (Namespace, string) {
+	if prefix, path, ok := strings.Cut(source, ":"); ok {
+		for ns, name := range namespaceNames {
+			if prefix == name {
+				return ns, path
+			}
+		}
+	}
+
+	return PathCode64, source
+}
+
+// Collision is a string with the same hash as already loaded one
+type Collision struct {
+	Namespace Namespace
+	Hash      uint64
+	Kept      string
+	Dropped   string
+	Source    string // where Dropped came from
+}
+
+// Manager holds dictionaries of every namespace loaded from several sources.
+// Sources loaded first have priority: on collision string loaded earlier is kept.
+type Manager struct {
+	names   map[Namespace]map[uint64]string
+	sources map[Namespace][]string
+
+	Collisions []Collision
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		names:   make(map[Namespace]map[uint64]string),
+		sources: make(map[Namespace][]string),
+	}
+}
+
+// Add adds s to namespace, returns false if s is already present, collides with other string or cannot be hashed.
+func (m *Manager) Add(ns Namespace, s string, source string) bool {
+	h, ok := ns.Hash(s)
+	if !ok {
+		return false
+	}
+
+	names := m.names[ns]
+	if names == nil {
+		names = make(map[uint64]string)
+		m.names[ns] = names
+	}
+
+	if existing, ok := names[h]; ok {
+		if existing != s {
+			m.Collisions = append(m.Collisions, Collision{Namespace: ns, Hash: h, Kept: existing, Dropped: s, Source: source})
+		}
+		return false
+	}
+
+	names[h] = s
+	return true
+}
+
+// Read adds every line of reader to namespace, gzip-compressed input is decompressed.
+func (m *Manager) Read(ns Namespace, reader io.Reader, source string) error {
+	br := bufio.NewReader(reader)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("dictionary %s: %w", source, err)
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		m.Add(ns, strings.TrimSuffix(scanner.Text(), "\r"), source)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("dictionary %s: %w", source, err)
+	}
+
+	m.sources[ns] = append(m.sources[ns], source)
+
+	return nil
+}
+
+// Load reads dictionary file or every file in directory in lexical order.
+func (m *Manager) Load(ns Namespace, path string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return m.Read(ns, f, p)
+	})
+}
+
+//go:embed defaults
+var defaults embed.FS
+
+// LoadDefaults reads dictionaries embedded into executable, defaults/<namespace>.txt.
+// Load them last to give other sources priority.
+func (m *Manager) LoadDefaults() error {
+	for _, ns := range Namespaces {
+		name := "defaults/" + ns.String() + ".txt"
+		f, err := defaults.Open(name)
+		if err != nil {
+			continue
+		}
+
+		err = m.Read(ns, f, "embedded:"+name)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sources returns loaded sources of namespace in order of priority.
+func (m *Manager) Sources(ns Namespace) []string {
+	return m.sources[ns]
+}
+
+// Len returns number of strings in namespace.
+func (m *Manager) Len(ns Namespace) int {
+	return len(m.names[ns])
+}
+
+// Get returns string of namespace by hash.
+func (m *Manager) Get(ns Namespace, hash uint64) (string, bool) {
+	s, ok := m.names[ns][hash]
+	return s, ok
+}
+
+// GetByHash returns dat/qar entry path by hash, behaves like hashing.Dictionary.GetByHash:
+// unresolved path and extension are formatted as hex.
+func (m *Manager) GetByHash(hash uint64) (string, bool) {
+	ext := hashing.ExtHashFromHash(hash)
+	resolved := true
+	path, ok := m.names[PathCode64][hashing.PathHashFromHash(hash)]
+	if !ok {
+		path = fmt.Sprintf("%x", hashing.PathHashFromHash(hash))
+		resolved = false
+	}
+
+	extension, ok := hashing.ExtensionsByHash[ext]
+	if !ok {
+		extension = fmt.Sprintf("%x.unknown", ext)
+		resolved = false
+	}
+
+	return fmt.Sprintf("%s.%s", path, extension), resolved
+}
+
+// Fox returns lookup of StrCode64 namespace, it is a fox.Names.
+func (m *Manager) Fox() func(hash uint64) (string, bool) {
+	return func(hash uint64) (string, bool) {
+		return m.Get(StrCode64, hash)
+	}
+}
+
+// Lng returns StrCode32 namespace as lng2 dictionary.
+func (m *Manager) Lng() DictStrCode64 {
+	d := DictStrCode64{data: make(map[uint32]string, len(m.names[StrCode32]))}
+	for h, s := range m.names[StrCode32] {
+		d.data[uint32(h)] = s
+	}
+
+	return d
+}
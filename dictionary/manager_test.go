@@ -0,0 +1,142 @@
+package dictionary
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/unknown321/hashing"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source string
+		ns     Namespace
+		path   string
+	}{
+		{source: "dictionary.txt", ns: PathCode64, path: "dictionary.txt"},
+		{source: "qar:a/dictionary.txt", ns: PathCode64, path: "a/dictionary.txt"},
+		{source: "lng:lng.txt.gz", ns: StrCode32, path: "lng.txt.gz"},
+		{source: "fox:dir", ns: StrCode64, path: "dir"},
+		{source: `C:\dictionary.txt`, ns: PathCode64, path: `C:\dictionary.txt`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			ns, path := ParseSource(tt.source)
+			if ns != tt.ns || path != tt.path {
+				t.Errorf("want %s %s, have %s %s", tt.ns, tt.path, ns, path)
+			}
+		})
+	}
+}
+
+func TestNamespace_Hash(t *testing.T) {
+	dir := "/Assets/" + strings.Repeat("a", MaxHashLength-4) + "/"
+	tests := []struct {
+		name string
+		ns   Namespace
+		s    string
+		want bool
+	}{
+		{name: "path fits without prefix and extension", ns: PathCode64, s: dir + "bcd.fox2", want: true},
+		{name: "path too long", ns: PathCode64, s: dir + "bcde.fox2"},
+		{name: "path without prefix", ns: PathCode64, s: "/" + strings.Repeat("a", MaxHashLength) + ".lua", want: true},
+		{name: "string fits with terminating zero", ns: StrCode64, s: strings.Repeat("a", MaxHashLength-1), want: true},
+		{name: "string too long", ns: StrCode64, s: strings.Repeat("a", MaxHashLength)},
+		{name: "lng key too long", ns: StrCode32, s: strings.Repeat("a", MaxHashLength)},
+		{name: "empty", ns: StrCode64, s: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.ns.Hash(tt.s); ok != tt.want {
+				t.Errorf("want %t, have %t", tt.want, ok)
+			}
+		})
+	}
+
+	if h, _ := PathCode64.Hash(dir + "bcd.fox2"); h != hashing.HashFileName(dir+"bcd", true)&0x3FFFFFFFFFFFF {
+		t.Errorf("bad hash %x", h)
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	gz := &bytes.Buffer{}
+	w := gzip.NewWriter(gz)
+	if _, err := w.Write([]byte("/Assets/tpp/pack/b\r\n/Assets/tpp/pack/a.lua\r\n")); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	files := map[string][]byte{
+		"1.txt.gz": gz.Bytes(),
+		"2.txt":    []byte("/Assets/tpp/pack/a.txt\n/Assets/tpp/pack/c\n\n" + strings.Repeat("a", 200) + "\n"),
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+	}
+
+	m := NewManager()
+	if err := m.Load(PathCode64, dir); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if err := m.Read(StrCode32, strings.NewReader("lang_key\n"), "lng"); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if err := m.LoadDefaults(); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if m.Len(PathCode64) != 3 {
+		t.Errorf("want 3 paths, have %d", m.Len(PathCode64))
+	}
+
+	if len(m.Collisions) != 1 {
+		t.Fatalf("want 1 collision, have %v", m.Collisions)
+	}
+
+	c := m.Collisions[0]
+	if c.Kept != "/Assets/tpp/pack/a.lua" || c.Dropped != "/Assets/tpp/pack/a.txt" || c.Source != filepath.Join(dir, "2.txt") {
+		t.Errorf("unexpected collision %+v", c)
+	}
+
+	reference := hashing.Dictionary{}
+	if err := reference.Read(strings.NewReader("/Assets/tpp/pack/b\n/Assets/tpp/pack/c")); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, path := range []string{"/Assets/tpp/pack/b.fpk", "/Assets/tpp/pack/c.lua", "/Assets/tpp/pack/missing.fpkd"} {
+		h := hashing.HashFileNameWithExtension(path)
+		wantName, wantOk := reference.GetByHash(h)
+		name, ok := m.GetByHash(h)
+		if name != wantName || ok != wantOk {
+			t.Errorf("want %s %t, have %s %t", wantName, wantOk, name, ok)
+		}
+	}
+
+	lng := m.Lng()
+	if key := lng.Get(uint32(hashing.StrCode64([]byte("lang_key")))); key != "lang_key" {
+		t.Errorf("want lang_key, have %q", key)
+	}
+
+	if name, ok := m.Get(StrCode64, hashing.StrCode64([]byte("DataSet"))); !ok || name != "DataSet" {
+		t.Errorf("want embedded DataSet, have %q", name)
+	}
+
+	if len(m.Sources(PathCode64)) != 2 {
+		t.Errorf("want 2 sources, have %v", m.Sources(PathCode64))
+	}
+}
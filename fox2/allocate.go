@@ -0,0 +1,177 @@
+package fox2
+
+import (
+	"fmt"
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+)
+
+const (
+	// AddressStep is a distance between allocated entity addresses
+	AddressStep uint64 = 0x10
+	// FirstAddress is an address of the first entity after Renumber or in file without addresses
+	FirstAddress uint64 = 0x10000000
+)
+
+// Symbol returns name EntityPtr and EntityHandle refs are matched with: Ref if set, value of name property otherwise.
+func (e *Entity) Symbol() string {
+	if e.Ref != "" {
+		return e.Ref
+	}
+
+	for _, p := range e.StaticProperties {
+		if p.NameValue != "name" || p.Header.DataType != fox.FString {
+			continue
+		}
+
+		if sa, ok := p.Value.(*containers.FoxStaticArray); ok && len(sa.Data) > 0 {
+			if s, ok := sa.Data[0].(*fox.String); ok {
+				return s.Value
+			}
+		}
+	}
+
+	return ""
+}
+
+// Allocate assigns unique addresses to entities with zero address and IDs to entities read from xml without id,
+// new values follow the largest ones used in file. EntityPtr and EntityHandle values with Ref are set to address
+// of entity with that symbol, see Entity.Symbol.
+func (f *Fox2) Allocate() error {
+	var maxAddress, maxID uint64
+	for _, e := range f.Entities {
+		maxAddress = max(maxAddress, e.Header.Address)
+		maxID = max(maxID, e.Header.ID)
+	}
+
+	next := FirstAddress
+	if maxAddress >= FirstAddress {
+		next = (maxAddress/AddressStep + 1) * AddressStep
+	}
+
+	for i := range f.Entities {
+		e := &f.Entities[i]
+		if e.Header.Address == 0 {
+			e.Header.Address = next
+			next += AddressStep
+		}
+
+		if e.idMissing {
+			maxID++
+			e.Header.ID = maxID
+			e.idMissing = false
+		}
+	}
+
+	return f.resolveRefs()
+}
+
+// resolveRefs sets values of references with symbolic names
+func (f *Fox2) resolveRefs() error {
+	symbols := make(map[string]uint64, len(f.Entities))
+	ambiguous := map[string]bool{}
+	for _, e := range f.Entities {
+		s := e.Symbol()
+		if s == "" {
+			continue
+		}
+
+		if a, ok := symbols[s]; ok && a != e.Header.Address {
+			ambiguous[s] = true
+		}
+		symbols[s] = e.Header.Address
+	}
+
+	var err error
+	for _, e := range f.Entities {
+		for _, props := range [][]Property{e.StaticProperties, e.DynamicProperties} {
+			for _, p := range props {
+				p.each(func(key string, v fox.DataType) {
+					var ref string
+					var value *uint64
+					switch t := v.(type) {
+					case *fox.EntityPtr:
+						ref, value = t.Ref, &t.Value
+					case *fox.EntityHandle:
+						ref, value = t.Ref, &t.Value
+					}
+
+					if ref == "" || err != nil {
+						return
+					}
+
+					a, ok := symbols[ref]
+					switch {
+					case !ok:
+						err = &PropertyError{Entity: e.Header.Address, Property: p.NameValue, Err: fmt.Errorf("unknown ref %q", ref)}
+					case ambiguous[ref]:
+						err = &PropertyError{Entity: e.Header.Address, Property: p.NameValue, Err: fmt.Errorf("ref %q matches several entities", ref)}
+					default:
+						*value = a
+					}
+				})
+			}
+		}
+	}
+
+	return err
+}
+
+// Renumber assigns sequential addresses starting at FirstAddress and sequential non-zero IDs to entities in order,
+// references to entities of file are rewritten. References to missing addresses are kept.
+func (f *Fox2) Renumber() error {
+	if err := f.Allocate(); err != nil {
+		return err
+	}
+
+	addresses := make(map[uint64]uint64, len(f.Entities))
+	var id uint64
+	for i := range f.Entities {
+		e := &f.Entities[i]
+		if _, ok := addresses[e.Header.Address]; !ok {
+			addresses[e.Header.Address] = FirstAddress + uint64(i)*AddressStep
+		}
+
+		e.Header.Address = FirstAddress + uint64(i)*AddressStep
+		if e.Header.ID != 0 {
+			id++
+			e.Header.ID = id
+		}
+	}
+
+	remapReferences(f.Entities, addresses)
+
+	return nil
+}
+
+// remapReferences replaces addresses in EntityPtr, EntityHandle and EntityLink to entities of the same file
+// using addresses map, other values are kept.
+func remapReferences(entities []Entity, addresses map[uint64]uint64) {
+	for _, e := range entities {
+		for _, props := range [][]Property{e.StaticProperties, e.DynamicProperties} {
+			for _, p := range props {
+				p.each(func(key string, v fox.DataType) {
+					var value *uint64
+					switch t := v.(type) {
+					case *fox.EntityPtr:
+						value = &t.Value
+					case *fox.EntityHandle:
+						value = &t.Value
+					case *fox.EntityLink:
+						// links to other packages keep their handles
+						if isExternal(t) {
+							return
+						}
+						value = &t.EntityHandle
+					default:
+						return
+					}
+
+					if a, ok := addresses[*value]; ok {
+						*value = a
+					}
+				})
+			}
+		}
+	}
+}
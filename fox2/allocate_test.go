@@ -0,0 +1,156 @@
+package fox2
+
+import (
+	"bytes"
+	"errors"
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/util"
+	"os"
+	"strings"
+	"testing"
+)
+
+const sparseXML = `<fox formatVersion="2" fileVersion="0">
+  <entities>
+    <entity class="DataSet" classVersion="0" ref="set">
+      <staticProperties>
+        <property name="dataList" type="EntityPtr" container="StringMap" arraySize="2">
+          <containerEntry key="tex"><data ref="tex"></data></containerEntry>
+          <containerEntry key="old"><data>0x1FFFFFFFF0</data></containerEntry>
+        </property>
+      </staticProperties>
+      <dynamicProperties></dynamicProperties>
+    </entity>
+    <entity class="TexturePackLoadConditioner" classVersion="0" classID="0xFFFFFFFF">
+      <staticProperties>
+        <property name="name" type="String" container="StaticArray" arraySize="1">
+          <containerEntry>tex</containerEntry>
+        </property>
+        <property name="dataSet" type="EntityHandle" container="StaticArray" arraySize="1">
+          <containerEntry ref="set"></containerEntry>
+        </property>
+      </staticProperties>
+      <dynamicProperties></dynamicProperties>
+    </entity>
+    <entity class="TexturePackLoadConditioner" classVersion="0" addr="0x1FFFFFFFF0" id="0x0">
+      <staticProperties></staticProperties>
+      <dynamicProperties></dynamicProperties>
+    </entity>
+  </entities>
+</fox>`
+
+func TestFox2_Allocate(t *testing.T) {
+	f := &Fox2{}
+	if err := f.FromXML(strings.NewReader(sparseXML)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if f.Entities[1].Header.ClassID != 0xFFFFFFFF || f.Entities[2].Header.Address != 0x1FFFFFFFF0 {
+		t.Fatalf("bad 64-bit values %+v", f.Entities[2].Header)
+	}
+
+	out := util.NewByteArrayReaderWriter([]byte{})
+	if err := f.Write(out); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f = &Fox2{}
+	if err := f.Read(bytes.NewReader(out.Bytes())); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	set, tex, old := f.Entities[0].Header, f.Entities[1].Header, f.Entities[2].Header
+	if set.Address != 0x2000000000 || tex.Address != 0x2000000010 || old.Address != 0x1FFFFFFFF0 {
+		t.Errorf("unexpected addresses %X %X %X", set.Address, tex.Address, old.Address)
+	}
+
+	if set.ID != 1 || tex.ID != 2 || old.ID != 0 {
+		t.Errorf("unexpected ids %X %X %X", set.ID, tex.ID, old.ID)
+	}
+
+	dataList := f.Entities[0].StaticProperties[0].Value.(*containers.FoxStringMap)
+	if v := dataList.Data[0].Value.(*fox.EntityPtr).Value; v != tex.Address {
+		t.Errorf("want tex address, have %X", v)
+	}
+
+	handle := f.Entities[1].StaticProperties[1].Value.(*containers.FoxStaticArray).Data[0].(*fox.EntityHandle)
+	if handle.Value != set.Address {
+		t.Errorf("want set address, have %X", handle.Value)
+	}
+
+	f = &Fox2{}
+	if err := f.FromXML(strings.NewReader(strings.Replace(sparseXML, `ref="set"></containerEntry>`, `ref="missing"></containerEntry>`, 1))); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	var pe *PropertyError
+	if err := f.Write(util.NewByteArrayReaderWriter([]byte{})); !errors.As(err, &pe) || pe.Property != "dataSet" {
+		t.Errorf("want *PropertyError for unknown ref, have %v", err)
+	}
+}
+
+func TestFox2_FoxToolIDs(t *testing.T) {
+	data, err := os.ReadFile("testdata/game/o50050_sequence.fox2.xml")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.FromXML(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if h := f.Entities[0].Header; h.ClassID != 232 || h.ID != 757080 {
+		t.Errorf("want classID 232 and id 757080, have %d %d", h.ClassID, h.ID)
+	}
+
+	// FoxTool omits zero id
+	if e := f.Entities[5]; e.Header.ID != 0 || e.idMissing {
+		t.Errorf("want zero id, have %d", e.Header.ID)
+	}
+}
+
+func TestFox2_Renumber(t *testing.T) {
+	data, err := os.ReadFile("testdata/game/title_sequence.fox2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	before := NewGraph(f)
+	if err = f.Renumber(); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	after := NewGraph(f)
+	if len(after.Dangling()) > 0 || len(after.Edges) != len(before.Edges) {
+		t.Fatalf("broken references after renumber %+v", after.Edges)
+	}
+
+	index := func(g *Graph, a uint64) int {
+		return g.index[a]
+	}
+
+	for i, e := range after.Edges {
+		b := before.Edges[i]
+		if index(after, e.From) != index(before, b.From) || index(after, e.To) != index(before, b.To) {
+			t.Errorf("edge %d points elsewhere: %+v, was %+v", i, e, b)
+		}
+	}
+
+	for i, e := range f.Entities {
+		if e.Header.Address != FirstAddress+uint64(i)*AddressStep {
+			t.Errorf("entity %d: unexpected address %X", i, e.Header.Address)
+		}
+	}
+
+	// zero id is kept
+	if ids := []uint64{f.Entities[0].Header.ID, f.Entities[1].Header.ID, f.Entities[2].Header.ID}; ids[0] != 1 || ids[1] != 2 || ids[2] != 0 {
+		t.Errorf("unexpected ids %v", ids)
+	}
+}
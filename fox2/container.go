@@ -32,20 +32,16 @@ type IFoxContainer interface {
 }
 
 func CreateTypedContainer(dataType fox.FDataType, containerType containers.FoxContainerType, count int) (IFoxContainer, error) {
-	var c IFoxContainer
-
 	switch containerType {
 	case containers.StaticArray:
-		c = containers.NewFoxStaticArray(dataType, count)
+		return containers.NewFoxStaticArray(dataType, count)
 	case containers.StringMap:
-		c = containers.NewFoxStringMap(dataType, count)
+		return containers.NewFoxStringMap(dataType, count)
 	case containers.List:
-		c = containers.NewFoxList(dataType, count)
+		return containers.NewFoxList(dataType, count)
 	case containers.DynamicArray:
-		c = containers.NewFoxDynamicArray(dataType, count)
+		return containers.NewFoxDynamicArray(dataType, count)
 	default:
-		panic(fmt.Sprintf("container not implemented: %s", containerType))
+		return nil, fmt.Errorf("container not implemented: %s", containerType)
 	}
-
-	return c, nil
 }
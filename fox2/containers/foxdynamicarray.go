@@ -12,64 +12,20 @@ type FoxDynamicArray struct {
 	next int
 }
 
-func NewFoxDynamicArray(dataType fox.FDataType, count int) *FoxDynamicArray {
+func NewFoxDynamicArray(dataType fox.FDataType, count int) (*FoxDynamicArray, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("negative value count %d", count)
+	}
+
+	var err error
 	data := make([]fox.DataType, count)
 	for i := 0; i < count; i++ {
-		switch dataType {
-		case fox.FInt8:
-			data[i] = &fox.Int8{}
-		case fox.FString:
-			data[i] = &fox.String{}
-		case fox.FEntityHandle:
-			data[i] = &fox.EntityHandle{}
-		case fox.FEntityPtr:
-			data[i] = &fox.EntityPtr{}
-		case fox.FFilePtr:
-			data[i] = &fox.FilePtr{}
-		case fox.FPath:
-			data[i] = &fox.Path{}
-		case fox.FUInt8:
-			data[i] = &fox.UInt8{}
-		case fox.FInt16:
-			data[i] = &fox.Int16{}
-		case fox.FUInt16:
-			data[i] = &fox.UInt16{}
-		case fox.FUInt32:
-			data[i] = &fox.UInt32{}
-		case fox.FInt32:
-			data[i] = &fox.Int32{}
-		case fox.FUInt64:
-			data[i] = &fox.UInt64{}
-		case fox.FInt64:
-			data[i] = &fox.Int64{}
-		case fox.FDouble:
-			data[i] = &fox.Double{}
-		case fox.FBool:
-			data[i] = &fox.Bool{}
-		case fox.FVector3:
-			data[i] = &fox.Vector3{}
-		case fox.FVector4:
-			data[i] = &fox.Vector4{}
-		case fox.FMatrix3:
-			data[i] = &fox.Matrix3{}
-		case fox.FMatrix4:
-			data[i] = &fox.Matrix4{}
-		case fox.FColor:
-			data[i] = &fox.Color{}
-		case fox.FQuat:
-			data[i] = &fox.Quat{}
-		case fox.FEntityLink:
-			data[i] = &fox.EntityLink{}
-		case fox.FFloat:
-			data[i] = &fox.Float{}
-		case fox.FWideVector3:
-			data[i] = &fox.WideVector3{}
-		default:
-			panic(fmt.Sprintf("not implemented %s", dataType))
+		if data[i], err = fox.New(dataType); err != nil {
+			return nil, err
 		}
 	}
 
-	return &FoxDynamicArray{Data: data}
+	return &FoxDynamicArray{Data: data}, nil
 }
 
 func (f *FoxDynamicArray) Next() func() *fox.DataType {
@@ -80,6 +36,10 @@ func (f *FoxDynamicArray) Next() func() *fox.DataType {
 }
 
 func (f *FoxDynamicArray) DecodeNext(d *xml.Decoder, start *xml.StartElement) error {
+	if len(f.Data) == 0 {
+		return fmt.Errorf("unexpected value <%s>, container is empty", start.Name.Local)
+	}
+
 	err := d.DecodeElement(f.Data[f.next], start)
 	f.next++
 	if f.next == len(f.Data) {
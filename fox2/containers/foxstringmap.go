@@ -8,8 +8,6 @@ import (
 	"github.com/unknown321/datfpk/util"
 	"io"
 	"log/slog"
-
-	"github.com/unknown321/hashing"
 )
 
 type FoxStringMapEntry struct {
@@ -25,58 +23,10 @@ type FoxStringMap struct {
 }
 
 func (f *FoxStringMap) Read(reader io.ReadSeeker) error {
+	var err error
 	for i := 0; i < len(f.Data); i++ {
-		switch f.DataType {
-		case fox.FInt8:
-			f.Data[i].Value = &fox.Int8{}
-		case fox.FString:
-			f.Data[i].Value = &fox.String{}
-		case fox.FEntityHandle:
-			f.Data[i].Value = &fox.EntityHandle{}
-		case fox.FEntityPtr:
-			f.Data[i].Value = &fox.EntityPtr{}
-		case fox.FFilePtr:
-			f.Data[i].Value = &fox.FilePtr{}
-		case fox.FPath:
-			f.Data[i].Value = &fox.Path{}
-		case fox.FUInt8:
-			f.Data[i].Value = &fox.UInt8{}
-		case fox.FInt16:
-			f.Data[i].Value = &fox.Int16{}
-		case fox.FUInt16:
-			f.Data[i].Value = &fox.UInt16{}
-		case fox.FUInt32:
-			f.Data[i].Value = &fox.UInt32{}
-		case fox.FInt32:
-			f.Data[i].Value = &fox.Int32{}
-		case fox.FUInt64:
-			f.Data[i].Value = &fox.UInt64{}
-		case fox.FInt64:
-			f.Data[i].Value = &fox.Int64{}
-		case fox.FDouble:
-			f.Data[i].Value = &fox.Double{}
-		case fox.FBool:
-			f.Data[i].Value = &fox.Bool{}
-		case fox.FVector3:
-			f.Data[i].Value = &fox.Vector3{}
-		case fox.FVector4:
-			f.Data[i].Value = &fox.Vector4{}
-		case fox.FMatrix3:
-			f.Data[i].Value = &fox.Matrix3{}
-		case fox.FMatrix4:
-			f.Data[i].Value = &fox.Matrix4{}
-		case fox.FColor:
-			f.Data[i].Value = &fox.Color{}
-		case fox.FQuat:
-			f.Data[i].Value = &fox.Quat{}
-		case fox.FEntityLink:
-			f.Data[i].Value = &fox.EntityLink{}
-		case fox.FFloat:
-			f.Data[i].Value = &fox.Float{}
-		case fox.FWideVector3:
-			f.Data[i].Value = &fox.WideVector3{}
-		default:
-			panic(fmt.Sprintf("stringMap, not implemented: %s", f.DataType))
+		if f.Data[i].Value, err = fox.New(f.DataType); err != nil {
+			return fmt.Errorf("stringMap: %w", err)
 		}
 
 		if err := binary.Read(reader, binary.LittleEndian, &f.Data[i].Key); err != nil {
@@ -103,7 +53,7 @@ func (f *FoxStringMap) Write(writer io.WriteSeeker) error {
 		if v.Value == nil {
 			return fmt.Errorf("stringMap value is nil, key \"%s\"", v.KeyString)
 		}
-		v.Key = hashing.StrCode64([]byte(v.KeyString))
+		v.Key = fox.NameHash(v.KeyString)
 		if err = binary.Write(writer, binary.LittleEndian, v.Key); err != nil {
 			return fmt.Errorf("stringMap key: %w", err)
 		}
@@ -124,68 +74,24 @@ func (f *FoxStringMap) Next() func() *fox.DataType {
 	}
 }
 
-func NewFoxStringMap(dataType fox.FDataType, count int) *FoxStringMap {
+func NewFoxStringMap(dataType fox.FDataType, count int) (*FoxStringMap, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("negative value count %d", count)
+	}
+
 	f := &FoxStringMap{
 		Data:     make([]FoxStringMapEntry, count),
 		DataType: dataType,
 	}
 
+	var err error
 	for i := range f.Data {
-		switch f.DataType {
-		case fox.FInt8:
-			f.Data[i].Value = &fox.Int8{}
-		case fox.FString:
-			f.Data[i].Value = &fox.String{}
-		case fox.FEntityHandle:
-			f.Data[i].Value = &fox.EntityHandle{}
-		case fox.FEntityPtr:
-			f.Data[i].Value = &fox.EntityPtr{}
-		case fox.FFilePtr:
-			f.Data[i].Value = &fox.FilePtr{}
-		case fox.FPath:
-			f.Data[i].Value = &fox.Path{}
-		case fox.FUInt8:
-			f.Data[i].Value = &fox.UInt8{}
-		case fox.FInt16:
-			f.Data[i].Value = &fox.Int16{}
-		case fox.FUInt16:
-			f.Data[i].Value = &fox.UInt16{}
-		case fox.FUInt32:
-			f.Data[i].Value = &fox.UInt32{}
-		case fox.FInt32:
-			f.Data[i].Value = &fox.Int32{}
-		case fox.FUInt64:
-			f.Data[i].Value = &fox.UInt64{}
-		case fox.FInt64:
-			f.Data[i].Value = &fox.Int64{}
-		case fox.FDouble:
-			f.Data[i].Value = &fox.Double{}
-		case fox.FBool:
-			f.Data[i].Value = &fox.Bool{}
-		case fox.FVector3:
-			f.Data[i].Value = &fox.Vector3{}
-		case fox.FVector4:
-			f.Data[i].Value = &fox.Vector4{}
-		case fox.FMatrix3:
-			f.Data[i].Value = &fox.Matrix3{}
-		case fox.FMatrix4:
-			f.Data[i].Value = &fox.Matrix4{}
-		case fox.FColor:
-			f.Data[i].Value = &fox.Color{}
-		case fox.FQuat:
-			f.Data[i].Value = &fox.Quat{}
-		case fox.FEntityLink:
-			f.Data[i].Value = &fox.EntityLink{}
-		case fox.FFloat:
-			f.Data[i].Value = &fox.Float{}
-		case fox.FWideVector3:
-			f.Data[i].Value = &fox.WideVector3{}
-		default:
-			panic(fmt.Sprintf("stringMap, not implemented: %s", f.DataType))
+		if f.Data[i].Value, err = fox.New(f.DataType); err != nil {
+			return nil, fmt.Errorf("stringMap: %w", err)
 		}
 	}
 
-	return f
+	return f, nil
 }
 
 type fsmEntry struct {
@@ -208,7 +114,7 @@ func (f *fsmEntry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for {
 		t, err := d.Token()
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("stringMap entry %s: %w", f.Key, err)
 		}
 
 		switch tt := t.(type) {
@@ -229,61 +135,12 @@ func (f *fsmEntry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 }
 
 func DecodeFoxData(decoder *xml.Decoder, start *xml.StartElement, dType fox.FDataType) (fox.DataType, error) {
-	var data fox.DataType
-	switch dType {
-	case fox.FInt8:
-		data = &fox.Int8{}
-	case fox.FString:
-		data = &fox.String{}
-	case fox.FEntityHandle:
-		data = &fox.EntityHandle{}
-	case fox.FEntityPtr:
-		data = &fox.EntityPtr{}
-	case fox.FFilePtr:
-		data = &fox.FilePtr{}
-	case fox.FPath:
-		data = &fox.Path{}
-	case fox.FUInt8:
-		data = &fox.UInt8{}
-	case fox.FInt16:
-		data = &fox.Int16{}
-	case fox.FUInt16:
-		data = &fox.UInt16{}
-	case fox.FUInt32:
-		data = &fox.UInt32{}
-	case fox.FInt32:
-		data = &fox.Int32{}
-	case fox.FUInt64:
-		data = &fox.UInt64{}
-	case fox.FInt64:
-		data = &fox.Int64{}
-	case fox.FDouble:
-		data = &fox.Double{}
-	case fox.FBool:
-		data = &fox.Bool{}
-	case fox.FVector3:
-		data = &fox.Vector3{}
-	case fox.FVector4:
-		data = &fox.Vector4{}
-	case fox.FMatrix3:
-		data = &fox.Matrix3{}
-	case fox.FMatrix4:
-		data = &fox.Matrix4{}
-	case fox.FColor:
-		data = &fox.Color{}
-	case fox.FQuat:
-		data = &fox.Quat{}
-	case fox.FEntityLink:
-		data = &fox.EntityLink{}
-	case fox.FFloat:
-		data = &fox.Float{}
-	case fox.FWideVector3:
-		data = &fox.WideVector3{}
-	default:
-		panic(fmt.Sprintf("stringMap, not implemented: %s", dType))
+	data, err := fox.New(dType)
+	if err != nil {
+		return nil, fmt.Errorf("stringMap: %w", err)
 	}
 
-	if err := decoder.DecodeElement(data, start); err != nil {
+	if err = decoder.DecodeElement(data, start); err != nil {
 		return nil, err
 	}
 
@@ -301,6 +158,10 @@ func (f *FoxStringMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error
 }
 
 func (f *FoxStringMap) DecodeNext(d *xml.Decoder, start *xml.StartElement) error {
+	if len(f.Data) == 0 {
+		return fmt.Errorf("unexpected value <%s>, container is empty", start.Name.Local)
+	}
+
 	ee := &fsmEntry{DataType: f.DataType}
 	if err := d.DecodeElement(ee, start); err != nil {
 		return err
@@ -20,7 +20,7 @@ func (b *Bool) Write(writer io.Writer) error {
 	return binary.Write(writer, binary.LittleEndian, b.Value)
 }
 
-func (b *Bool) Resolve(m map[uint64]string) {
+func (b *Bool) Resolve(names Names) {
 	return
 }
 
@@ -31,6 +31,6 @@ func (i *Color) HashString() string {
 	return ""
 }
 
-func (i *Color) Resolve(m map[uint64]string) {
+func (i *Color) Resolve(names Names) {
 	return
 }
@@ -1,6 +1,7 @@
 package fox
 
 import (
+	"errors"
 	"fmt"
 	"io"
 )
@@ -12,9 +13,12 @@ type DataType interface {
 	String() []string
 	//HashString() string // used in stringMap when String() returns ""
 
-	Resolve(map[uint64]string)
+	Resolve(names Names)
 }
 
+// Names returns string by its StrCode64 hash
+type Names func(hash uint64) (string, bool)
+
 //go:generate stringer -type=FDataType
 
 type FDataType byte
@@ -67,3 +71,62 @@ func DataTypeFromString(s string) (FDataType, error) {
 
 	return FFail, fmt.Errorf("unknown type %s", s)
 }
+
+// ErrNotImplemented is returned by New for data types without implementation, such as FPropertyInfo.
+var ErrNotImplemented = errors.New("data type not implemented")
+
+// New returns empty value of data type t.
+func New(t FDataType) (DataType, error) {
+	switch t {
+	case FInt8:
+		return &Int8{}, nil
+	case FString:
+		return &String{}, nil
+	case FEntityHandle:
+		return &EntityHandle{}, nil
+	case FEntityPtr:
+		return &EntityPtr{}, nil
+	case FFilePtr:
+		return &FilePtr{}, nil
+	case FPath:
+		return &Path{}, nil
+	case FUInt8:
+		return &UInt8{}, nil
+	case FInt16:
+		return &Int16{}, nil
+	case FUInt16:
+		return &UInt16{}, nil
+	case FUInt32:
+		return &UInt32{}, nil
+	case FInt32:
+		return &Int32{}, nil
+	case FUInt64:
+		return &UInt64{}, nil
+	case FInt64:
+		return &Int64{}, nil
+	case FDouble:
+		return &Double{}, nil
+	case FBool:
+		return &Bool{}, nil
+	case FVector3:
+		return &Vector3{}, nil
+	case FVector4:
+		return &Vector4{}, nil
+	case FMatrix3:
+		return &Matrix3{}, nil
+	case FMatrix4:
+		return &Matrix4{}, nil
+	case FColor:
+		return &Color{}, nil
+	case FQuat:
+		return &Quat{}, nil
+	case FEntityLink:
+		return &EntityLink{}, nil
+	case FFloat:
+		return &Float{}, nil
+	case FWideVector3:
+		return &WideVector3{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotImplemented, t)
+	}
+}
@@ -30,6 +30,6 @@ func (i *Double) HashString() string {
 	return fmt.Sprintf("%f", i.Value)
 }
 
-func (i *Double) Resolve(m map[uint64]string) {
+func (i *Double) Resolve(names Names) {
 	return
 }
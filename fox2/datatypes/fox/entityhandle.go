@@ -11,6 +11,7 @@ import (
 
 type EntityHandle struct {
 	Value uint64 `xml:",chardata"`
+	Ref   string // symbolic name of entity, Value is set by fox2 on write
 }
 
 func (eh *EntityHandle) Read(reader io.Reader) error {
@@ -29,16 +30,18 @@ func (eh *EntityHandle) HashString() string {
 	return fmt.Sprintf("0x%X", eh.Value)
 }
 
-func (eh *EntityHandle) Resolve(m map[uint64]string) {
+func (eh *EntityHandle) Resolve(names Names) {
 	return
 }
 
 type ehXml struct {
+	Ref   string `xml:"ref,attr,omitempty"`
 	Value string `xml:",chardata"`
 }
 
 func (eh *EntityHandle) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	ss := ehXml{
+		Ref:   eh.Ref,
 		Value: fmt.Sprintf("0x%X", eh.Value),
 	}
 	return e.EncodeElement(ss, start)
@@ -51,6 +54,11 @@ func (eh *EntityHandle) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		return err
 	}
 
+	eh.Ref = ss.Ref
+	if eh.Ref != "" && strings.TrimSpace(ss.Value) == "" {
+		return nil
+	}
+
 	if eh.Value, err = strconv.ParseUint(strings.TrimPrefix(ss.Value, "0x"), 16, 64); err != nil {
 		return fmt.Errorf("entityHandle: %w", err)
 	}
@@ -7,8 +7,6 @@ import (
 	"io"
 	"strconv"
 	"strings"
-
-	"github.com/unknown321/hashing"
 )
 
 type EntityLink struct {
@@ -19,6 +17,11 @@ type EntityLink struct {
 	NameInArchiveHash uint64
 	NameInArchive     string
 	EntityHandle      uint64
+
+	// hashes with unknown strings are written as is
+	PackagePathUnresolved   bool
+	ArchivePathUnresolved   bool
+	NameInArchiveUnresolved bool
 }
 
 func (el *EntityLink) Read(reader io.Reader) error {
@@ -36,15 +39,20 @@ func (el *EntityLink) Read(reader io.Reader) error {
 		return err
 	}
 
+	// values are unknown until resolved
+	el.PackagePathUnresolved = true
+	el.ArchivePathUnresolved = true
+	el.NameInArchiveUnresolved = true
+
 	return nil
 }
 
 func (el *EntityLink) Write(writer io.Writer) error {
 	var err error
 
-	el.PackagePathHash = hashing.StrCode64([]byte(el.PackagePath))
-	el.NameInArchiveHash = hashing.StrCode64([]byte(el.NameInArchive))
-	el.ArchivePathHash = hashing.StrCode64([]byte(el.ArchivePath))
+	el.PackagePathHash = ValueHash(el.PackagePath, el.PackagePathHash, el.PackagePathUnresolved)
+	el.NameInArchiveHash = ValueHash(el.NameInArchive, el.NameInArchiveHash, el.NameInArchiveUnresolved)
+	el.ArchivePathHash = ValueHash(el.ArchivePath, el.ArchivePathHash, el.ArchivePathUnresolved)
 
 	if err = binary.Write(writer, binary.LittleEndian, el.PackagePathHash); err != nil {
 		return err
@@ -81,10 +89,14 @@ func (el *EntityLink) HashString() string {
 	return fmt.Sprintf("0x%X", el.EntityHandle)
 }
 
-func (el *EntityLink) Resolve(m map[uint64]string) {
-	el.PackagePath = m[el.PackagePathHash]
-	el.NameInArchive = m[el.NameInArchiveHash]
-	el.ArchivePath = m[el.ArchivePathHash]
+func (el *EntityLink) Resolve(names Names) {
+	var ok bool
+	el.PackagePath, ok = names(el.PackagePathHash)
+	el.PackagePathUnresolved = !ok
+	el.NameInArchive, ok = names(el.NameInArchiveHash)
+	el.NameInArchiveUnresolved = !ok
+	el.ArchivePath, ok = names(el.ArchivePathHash)
+	el.ArchivePathUnresolved = !ok
 }
 
 type elXml struct {
@@ -99,11 +111,11 @@ type elXml struct {
 
 func (el *EntityLink) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	ex := elXml{
-		PackagePathHash:   fmt.Sprintf("0x%X", el.PackagePathHash),
+		PackagePathHash:   fmt.Sprintf("0x%X", ValueHash(el.PackagePath, el.PackagePathHash, el.PackagePathUnresolved)),
 		PackagePath:       el.PackagePath,
-		ArchivePathHash:   fmt.Sprintf("0x%X", el.ArchivePathHash),
+		ArchivePathHash:   fmt.Sprintf("0x%X", ValueHash(el.ArchivePath, el.ArchivePathHash, el.ArchivePathUnresolved)),
 		ArchivePath:       el.ArchivePath,
-		NameInArchiveHash: fmt.Sprintf("0x%X", el.NameInArchiveHash),
+		NameInArchiveHash: fmt.Sprintf("0x%X", ValueHash(el.NameInArchive, el.NameInArchiveHash, el.NameInArchiveUnresolved)),
 		NameInArchive:     el.NameInArchive,
 		EntityHandle:      fmt.Sprintf("0x%X", el.EntityHandle),
 	}
@@ -149,6 +161,9 @@ func (el *EntityLink) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 	el.ArchivePath = pp.ArchivePath
 	el.PackagePath = pp.PackagePath
 	el.NameInArchive = pp.NameInArchive
+	el.ArchivePathUnresolved = pp.ArchivePath == "" && pp.ArchivePathHash != ""
+	el.PackagePathUnresolved = pp.PackagePath == "" && pp.PackagePathHash != ""
+	el.NameInArchiveUnresolved = pp.NameInArchive == "" && pp.NameInArchiveHash != ""
 
 	if pp.EntityHandle != "" {
 		if el.EntityHandle, err = strconv.ParseUint(strings.TrimPrefix(pp.EntityHandle, "0x"), 16, 64); err != nil {
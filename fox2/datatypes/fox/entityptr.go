@@ -11,6 +11,7 @@ import (
 
 type EntityPtr struct {
 	Value uint64
+	Ref   string // symbolic name of entity, Value is set by fox2 on write
 }
 
 func (ep *EntityPtr) Read(reader io.Reader) error {
@@ -22,11 +23,13 @@ func (ep *EntityPtr) Write(writer io.Writer) error {
 }
 
 type epXml struct {
+	Ref   string `xml:"ref,attr,omitempty"`
 	Value string `xml:",chardata"`
 }
 
 func (ep *EntityPtr) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	ss := epXml{
+		Ref:   ep.Ref,
 		Value: fmt.Sprintf("0x%X", ep.Value),
 	}
 	return e.EncodeElement(ss, start)
@@ -39,6 +42,11 @@ func (ep *EntityPtr) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		return err
 	}
 
+	ep.Ref = ss.Ref
+	if ep.Ref != "" && strings.TrimSpace(ss.Value) == "" {
+		return nil
+	}
+
 	if ep.Value, err = strconv.ParseUint(strings.TrimPrefix(ss.Value, "0x"), 16, 64); err != nil {
 		return fmt.Errorf("entityPtr: %w", err)
 	}
@@ -46,7 +54,7 @@ func (ep *EntityPtr) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 	return nil
 }
 
-func (ep *EntityPtr) Resolve(m map[uint64]string) {
+func (ep *EntityPtr) Resolve(names Names) {
 	return
 }
 
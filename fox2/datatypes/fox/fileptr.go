@@ -5,26 +5,26 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
-
-	"github.com/unknown321/hashing"
 )
 
 type FilePtr struct {
 	Hash  uint64
 	Value string
+	// Unresolved is set when Value of Hash is unknown, Hash is written as is
+	Unresolved bool
 }
 
 func (f *FilePtr) Read(reader io.Reader) error {
 	if err := binary.Read(reader, binary.LittleEndian, &f.Hash); err != nil {
 		return err
 	}
+	// value is unknown until resolved
+	f.Unresolved = true
 	return nil
 }
 
 func (f *FilePtr) Write(writer io.Writer) error {
-	f.Hash = hashing.StrCode64([]byte(f.Value))
+	f.Hash = ValueHash(f.Value, f.Hash, f.Unresolved)
 	return binary.Write(writer, binary.LittleEndian, f.Hash)
 }
 
@@ -36,11 +36,12 @@ func (f *FilePtr) HashString() string {
 	return fmt.Sprintf("0x%X", f.Hash)
 }
 
-func (f *FilePtr) Resolve(m map[uint64]string) {
+func (f *FilePtr) Resolve(names Names) {
 	var ok bool
-	if f.Value, ok = m[f.Hash]; !ok {
+	if f.Value, ok = names(f.Hash); !ok {
 		f.Value = ""
 	}
+	f.Unresolved = !ok
 }
 
 type fptr struct {
@@ -50,7 +51,7 @@ type fptr struct {
 
 func (f *FilePtr) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	ff := fptr{
-		Hash:  fmt.Sprintf("0x%X", f.Hash),
+		Hash:  fmt.Sprintf("0x%X", ValueHash(f.Value, f.Hash, f.Unresolved)),
 		Value: f.Value,
 	}
 
@@ -69,11 +70,12 @@ func (f *FilePtr) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	}
 
 	if pp.Hash != "" {
-		if f.Hash, err = strconv.ParseUint(strings.TrimPrefix(pp.Hash, "0x"), 16, 64); err != nil {
+		if f.Hash, err = parseHash(pp.Hash); err != nil {
 			return fmt.Errorf("filePtr: %w", err)
 		}
 	}
 
 	f.Value = pp.Value
+	f.Unresolved = pp.Value == "" && pp.Hash != ""
 	return nil
 }
@@ -30,6 +30,6 @@ func (i *Float) HashString() string {
 	return fmt.Sprintf("%f", i.Value)
 }
 
-func (i *Float) Resolve(m map[uint64]string) {
+func (i *Float) Resolve(names Names) {
 	return
 }
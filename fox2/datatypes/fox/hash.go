@@ -0,0 +1,48 @@
+package fox
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unknown321/hashing"
+)
+
+// unresolvedPattern matches names made by UnresolvedName
+var unresolvedPattern = regexp.MustCompile(`^0x[0-9A-F]+$`)
+
+// UnresolvedName is a name of hash missing from dictionaries, used for class and property names and stringMap keys.
+func UnresolvedName(hash uint64) string {
+	return fmt.Sprintf("0x%X", hash)
+}
+
+// IsUnresolvedName is true for names made by UnresolvedName.
+func IsUnresolvedName(name string) bool {
+	return unresolvedPattern.MatchString(name)
+}
+
+// NameHash returns StrCode64 of name, names made by UnresolvedName are parsed back to original hash.
+func NameHash(name string) uint64 {
+	if IsUnresolvedName(name) {
+		if h, err := strconv.ParseUint(name[2:], 16, 64); err == nil {
+			return h
+		}
+	}
+
+	return hashing.StrCode64([]byte(name))
+}
+
+// ValueHash returns hash of string value to write: original hash if value is unresolved, StrCode64 of value otherwise.
+func ValueHash(value string, hash uint64, unresolved bool) uint64 {
+	if unresolved {
+		return hash
+	}
+
+	return hashing.StrCode64([]byte(value))
+}
+
+// parseHash parses hash attribute, 0x prefix is optional
+func parseHash(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+}
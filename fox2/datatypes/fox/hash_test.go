@@ -0,0 +1,80 @@
+package fox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"testing"
+
+	"github.com/unknown321/hashing"
+)
+
+func TestUnresolvedXML(t *testing.T) {
+	none := func(uint64) (string, bool) { return "", false }
+	tests := []struct {
+		name  string
+		value DataType
+		empty func() DataType
+		want  []uint64
+	}{
+		{name: "string, zero hash", value: &String{Hash: 0}, empty: func() DataType { return &String{} }, want: []uint64{0}},
+		{name: "string, high bit", value: &String{Hash: 0x8000000000000001}, empty: func() DataType { return &String{} }, want: []uint64{0x8000000000000001}},
+		{name: "path", value: &Path{Hash: 0x1234}, empty: func() DataType { return &Path{} }, want: []uint64{0x1234}},
+		{name: "filePtr", value: &FilePtr{Hash: 0}, empty: func() DataType { return &FilePtr{} }, want: []uint64{0}},
+		{
+			name:  "entityLink",
+			value: &EntityLink{PackagePathHash: 1, ArchivePathHash: 0, NameInArchiveHash: 3, EntityHandle: 4},
+			empty: func() DataType { return &EntityLink{} },
+			want:  []uint64{1, 0, 3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.value.Resolve(none)
+			data, err := xml.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			v := tt.empty()
+			if err = xml.Unmarshal(data, v); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			out := &bytes.Buffer{}
+			if err = v.Write(out); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			have := make([]uint64, len(tt.want))
+			if err = binary.Read(out, binary.LittleEndian, have); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			for i := range tt.want {
+				if have[i] != tt.want[i] {
+					t.Errorf("%s: want %x, have %x", data, tt.want, have)
+					break
+				}
+			}
+		})
+	}
+
+	// empty string is not unresolved
+	s := &String{}
+	out := &bytes.Buffer{}
+	if err := s.Write(out); err != nil || binary.LittleEndian.Uint64(out.Bytes()) != hashing.StrCode64(nil) {
+		t.Errorf("want hash of empty string, have %x, %v", out.Bytes(), err)
+	}
+}
+
+func TestNameHash(t *testing.T) {
+	if h := NameHash(UnresolvedName(0xABCDEF)); h != 0xABCDEF {
+		t.Errorf("want 0xABCDEF, have %X", h)
+	}
+
+	if h := NameHash("name"); h != hashing.StrCode64([]byte("name")) {
+		t.Errorf("want StrCode64, have %X", h)
+	}
+}
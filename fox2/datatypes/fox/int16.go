@@ -29,6 +29,6 @@ func (i *Int16) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *Int16) Resolve(m map[uint64]string) {
+func (i *Int16) Resolve(names Names) {
 	return
 }
@@ -29,6 +29,6 @@ func (i *Int32) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *Int32) Resolve(m map[uint64]string) {
+func (i *Int32) Resolve(names Names) {
 	return
 }
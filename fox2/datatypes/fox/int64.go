@@ -29,6 +29,6 @@ func (i *Int64) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *Int64) Resolve(m map[uint64]string) {
+func (i *Int64) Resolve(names Names) {
 	return
 }
@@ -29,6 +29,6 @@ func (i *Int8) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *Int8) Resolve(m map[uint64]string) {
+func (i *Int8) Resolve(names Names) {
 	return
 }
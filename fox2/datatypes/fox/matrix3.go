@@ -36,6 +36,6 @@ func (i *Matrix3) HashString() string {
 	return ""
 }
 
-func (i *Matrix3) Resolve(m map[uint64]string) {
+func (i *Matrix3) Resolve(names Names) {
 	return
 }
@@ -43,6 +43,6 @@ func (i *Matrix4) HashString() string {
 	return ""
 }
 
-func (i *Matrix4) Resolve(m map[uint64]string) {
+func (i *Matrix4) Resolve(names Names) {
 	return
 }
@@ -5,26 +5,26 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
-
-	"github.com/unknown321/hashing"
 )
 
 type Path struct {
 	Hash  uint64
 	Value string
+	// Unresolved is set when Value of Hash is unknown, Hash is written as is
+	Unresolved bool
 }
 
 func (p *Path) Read(reader io.Reader) error {
 	if err := binary.Read(reader, binary.LittleEndian, &p.Hash); err != nil {
 		return err
 	}
+	// value is unknown until resolved
+	p.Unresolved = true
 	return nil
 }
 
 func (p *Path) Write(writer io.Writer) error {
-	p.Hash = hashing.StrCode64([]byte(p.Value))
+	p.Hash = ValueHash(p.Value, p.Hash, p.Unresolved)
 	return binary.Write(writer, binary.LittleEndian, p.Hash)
 }
 
@@ -36,11 +36,12 @@ func (p *Path) HashString() string {
 	return fmt.Sprintf("0x%X", p.Hash)
 }
 
-func (p *Path) Resolve(m map[uint64]string) {
+func (p *Path) Resolve(names Names) {
 	var ok bool
-	if p.Value, ok = m[p.Hash]; !ok {
+	if p.Value, ok = names(p.Hash); !ok {
 		p.Value = ""
 	}
+	p.Unresolved = !ok
 }
 
 type pXml struct {
@@ -50,7 +51,7 @@ type pXml struct {
 
 func (p *Path) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	pp := pXml{
-		Hash:  fmt.Sprintf("0x%X", p.Hash),
+		Hash:  fmt.Sprintf("0x%X", ValueHash(p.Value, p.Hash, p.Unresolved)),
 		Value: p.Value,
 	}
 
@@ -69,11 +70,12 @@ func (p *Path) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	}
 
 	if pp.Hash != "" {
-		if p.Hash, err = strconv.ParseUint(strings.TrimPrefix(pp.Hash, "0x"), 16, 64); err != nil {
+		if p.Hash, err = parseHash(pp.Hash); err != nil {
 			return fmt.Errorf("Path: %w", err)
 		}
 	}
 
 	p.Value = pp.Value
+	p.Unresolved = pp.Value == "" && pp.Hash != ""
 	return nil
 }
@@ -31,6 +31,6 @@ func (q *Quat) HashString() string {
 	return ""
 }
 
-func (q *Quat) Resolve(m map[uint64]string) {
+func (q *Quat) Resolve(names Names) {
 	return
 }
@@ -5,26 +5,26 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
-
-	"github.com/unknown321/hashing"
 )
 
 type String struct {
 	Hash  uint64 `xml:"hash,attr,omitempty"`
 	Value string `xml:",chardata"`
+	// Unresolved is set when Value of Hash is unknown, Hash is written as is
+	Unresolved bool `xml:"-"`
 }
 
 func (s *String) Read(reader io.Reader) error {
 	if err := binary.Read(reader, binary.LittleEndian, &s.Hash); err != nil {
 		return err
 	}
+	// value is unknown until resolved
+	s.Unresolved = true
 	return nil
 }
 
 func (s *String) Write(writer io.Writer) error {
-	s.Hash = hashing.StrCode64([]byte(s.Value))
+	s.Hash = ValueHash(s.Value, s.Hash, s.Unresolved)
 	return binary.Write(writer, binary.LittleEndian, s.Hash)
 }
 
@@ -36,7 +36,7 @@ type sXml struct {
 func (s *String) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	ss := sXml{
 		Value: s.Value,
-		Hash:  fmt.Sprintf("0x%X", s.Hash),
+		Hash:  fmt.Sprintf("0x%X", ValueHash(s.Value, s.Hash, s.Unresolved)),
 	}
 	if s.Value != "" {
 		ss.Hash = ""
@@ -53,17 +53,19 @@ func (s *String) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	if ss.Value != "" {
 		s.Value = ss.Value
 		s.Hash = 0
+		s.Unresolved = false
 		return nil
 	}
 
 	if ss.Hash != "" {
-		v, err := strconv.ParseInt(strings.TrimPrefix(ss.Hash, "0x"), 16, 64)
+		v, err := parseHash(ss.Hash)
 		if err != nil {
-			return err
+			return fmt.Errorf("string: %w", err)
 		}
 
-		s.Hash = uint64(v)
+		s.Hash = v
 		s.Value = ""
+		s.Unresolved = true
 		return nil
 	}
 
@@ -78,9 +80,10 @@ func (s *String) HashString() string {
 	return fmt.Sprintf("0x%X", s.Hash)
 }
 
-func (s *String) Resolve(m map[uint64]string) {
+func (s *String) Resolve(names Names) {
 	var ok bool
-	if s.Value, ok = m[s.Hash]; !ok {
+	if s.Value, ok = names(s.Hash); !ok {
 		s.Value = ""
 	}
+	s.Unresolved = !ok
 }
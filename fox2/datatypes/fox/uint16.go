@@ -29,6 +29,6 @@ func (i *UInt16) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *UInt16) Resolve(m map[uint64]string) {
+func (i *UInt16) Resolve(names Names) {
 	return
 }
@@ -29,6 +29,6 @@ func (i *UInt32) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *UInt32) Resolve(m map[uint64]string) {
+func (i *UInt32) Resolve(names Names) {
 	return
 }
@@ -29,6 +29,6 @@ func (i *UInt64) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *UInt64) Resolve(m map[uint64]string) {
+func (i *UInt64) Resolve(names Names) {
 	return
 }
@@ -29,6 +29,6 @@ func (i *UInt8) HashString() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
-func (i *UInt8) Resolve(m map[uint64]string) {
+func (i *UInt8) Resolve(names Names) {
 	return
 }
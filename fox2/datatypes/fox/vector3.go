@@ -31,6 +31,6 @@ func (i *Vector3) HashString() string {
 	return ""
 }
 
-func (i *Vector3) Resolve(m map[uint64]string) {
+func (i *Vector3) Resolve(names Names) {
 	return
 }
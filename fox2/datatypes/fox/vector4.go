@@ -31,6 +31,6 @@ func (i *Vector4) HashString() string {
 	return ""
 }
 
-func (i *Vector4) Resolve(m map[uint64]string) {
+func (i *Vector4) Resolve(names Names) {
 	return
 }
@@ -32,6 +32,6 @@ func (i *WideVector3) HashString() string {
 	return ""
 }
 
-func (i *WideVector3) Resolve(m map[uint64]string) {
+func (i *WideVector3) Resolve(names Names) {
 	return
 }
@@ -3,14 +3,15 @@ package fox2
 import (
 	"encoding/binary"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
 	"github.com/unknown321/datfpk/util"
 	"io"
 	"strconv"
 	"strings"
-
-	"github.com/unknown321/hashing"
 )
 
 type EntityHeader struct {
@@ -44,16 +45,19 @@ type Entity struct {
 	StaticProperties  []Property
 	DynamicProperties []Property
 	ClassNameString   string
+	Ref               string // symbolic name EntityPtr and EntityHandle refer to, see Fox2.Allocate
+
+	idMissing bool // xml without id, allocated on write
 }
 
-func (e *Entity) Resolve(dict map[uint64]string) {
+func (e *Entity) Resolve(names fox.Names) {
 	var ok bool
-	e.ClassNameString, ok = dict[e.Header.ClassNameHash]
+	e.ClassNameString, ok = names(e.Header.ClassNameHash)
 	if !ok {
-		e.ClassNameString = fmt.Sprintf("0x%X", e.Header.ClassNameHash)
+		e.ClassNameString = fox.UnresolvedName(e.Header.ClassNameHash)
 	}
 
-	e.ResolveProps(dict)
+	e.ResolveProps(names)
 }
 
 type entityXml struct {
@@ -62,6 +66,9 @@ type entityXml struct {
 	ClassID           string     `xml:"classID,omitempty,attr"`
 	Addr              string     `xml:"addr,attr"`
 	ID                string     `xml:"id,attr"`
+	Ref               string     `xml:"ref,attr,omitempty"`
+	Unknown1          string     `xml:"unknown1,attr,omitempty"` // FoxTool classID, decimal
+	Unknown2          string     `xml:"unknown2,attr,omitempty"` // FoxTool id, decimal, omitted if zero
 	StaticProperties  []Property `xml:"staticProperties>property"`
 	DynamicProperties []Property `xml:"dynamicProperties>property"`
 }
@@ -74,6 +81,7 @@ func (e *Entity) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error
 		Addr:              fmt.Sprintf("0x%X", e.Header.Address),
 		ClassID:           fmt.Sprintf("0x%X", e.Header.ClassID),
 		ID:                fmt.Sprintf("0x%X", e.Header.ID),
+		Ref:               e.Ref,
 		StaticProperties:  e.StaticProperties,
 		DynamicProperties: e.DynamicProperties,
 	}
@@ -85,83 +93,219 @@ func (e *Entity) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var err error
 	xx := entityXml{}
 	if err = d.DecodeElement(&xx, &start); err != nil {
+		var pe *PropertyError
+		if errors.As(err, &pe) {
+			for _, a := range start.Attr {
+				if a.Name.Local == "addr" {
+					pe.Entity, _ = strconv.ParseUint(strings.TrimPrefix(a.Value, "0x"), 16, 64)
+				}
+			}
+		}
 		return err
 	}
 
 	e.ClassNameString = xx.Class
 	e.Header.Version = xx.ClassVersion
-	addr, err := strconv.ParseInt(strings.TrimPrefix(xx.Addr, "0x"), 16, 32)
-	if err != nil {
-		return err
+	e.Ref = xx.Ref
+	// missing addr and id are allocated on write, see Fox2.Allocate
+	if e.Header.Address, err = parseHex(xx.Addr, 64); err != nil {
+		return fmt.Errorf("addr: %w", err)
 	}
-	e.Header.Address = uint64(addr)
 
-	cid, err := strconv.ParseInt(strings.TrimPrefix(xx.ClassID, "0x"), 16, 32)
+	var cid uint64
+	if xx.ClassID == "" && xx.Unknown1 != "" {
+		cid, err = strconv.ParseUint(xx.Unknown1, 10, 32)
+	} else {
+		cid, err = parseHex(xx.ClassID, 32)
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("classID: %w", err)
 	}
 	e.Header.ClassID = uint32(cid)
-	id, err := strconv.ParseInt(strings.TrimPrefix(xx.ID, "0x"), 16, 64)
+
+	switch {
+	case xx.ID != "":
+		e.Header.ID, err = parseHex(xx.ID, 64)
+	case xx.Unknown2 != "":
+		e.Header.ID, err = strconv.ParseUint(xx.Unknown2, 10, 64)
+	case xx.Unknown1 == "":
+		e.idMissing = true
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("id: %w", err)
 	}
-	e.Header.ID = uint64(id)
+
 	e.StaticProperties = xx.StaticProperties
 	e.DynamicProperties = xx.DynamicProperties
 
 	return nil
 }
 
-func (e *Entity) ResolveProps(dict map[uint64]string) {
-	var ok bool
-	for i := range e.StaticProperties {
-		e.StaticProperties[i].NameValue, ok = dict[e.StaticProperties[i].Header.NameHash]
-		if !ok {
-			e.StaticProperties[i].NameValue = fmt.Sprintf("0x%X", e.StaticProperties[i].Header.NameHash)
+// parseHex parses optional hex attribute, empty value is 0
+func parseHex(s string, bitSize int) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, bitSize)
+}
+
+// Validate checks static properties against class definition, classes missing from registry are not checked.
+func (e *Entity) Validate(reg *schema.Registry) error {
+	class, ok := reg.Class(e.ClassNameString, e.Header.Version)
+	if !ok {
+		versions := reg.Versions(e.ClassNameString)
+		if len(versions) == 0 {
+			return nil
 		}
 
-		switch e.StaticProperties[i].Header.ContainerType {
-		case containers.StringMap:
-			sm := (e.StaticProperties[i].Value).(*containers.FoxStringMap)
-			for n := range sm.Data {
-				sm.Data[n].KeyString, ok = dict[sm.Data[n].Key]
-				if !ok {
-					sm.Data[n].KeyString = fmt.Sprintf("0x%X", sm.Data[n].Key)
-				}
+		ss := make([]string, len(versions))
+		for i, v := range versions {
+			ss[i] = strconv.Itoa(int(v))
+		}
 
-				//slog.Info("resolving", "value", fmt.Sprintf("%+v", sm.Data[n].Value))
-				sm.Data[n].Value.Resolve(dict)
-			}
-		case containers.StaticArray:
-			sm := (e.StaticProperties[i].Value).(*containers.FoxStaticArray)
-			for e := range sm.Data {
-				sm.Data[e].Resolve(dict)
-			}
-		case containers.DynamicArray:
-			sm := (e.StaticProperties[i].Value).(*containers.FoxDynamicArray)
-			for e := range sm.Data {
-				sm.Data[e].Resolve(dict)
-			}
-		case containers.List:
-			sm := (e.StaticProperties[i].Value).(*containers.FoxList)
-			for e := range sm.Data {
-				sm.Data[e].Resolve(dict)
-			}
+		return &ValidationError{
+			Entity:  e.Header.Address,
+			Class:   e.ClassNameString,
+			Version: e.Header.Version,
+			Mismatches: []schema.Mismatch{{
+				Problem: schema.ProblemVersion,
+				Want:    strings.Join(ss, ", "),
+				Have:    strconv.Itoa(int(e.Header.Version)),
+			}},
+		}
+	}
+
+	props := make([]schema.Property, len(e.StaticProperties))
+	for i, p := range e.StaticProperties {
+		props[i] = schema.Property{
+			Name:      p.NameValue,
+			Type:      p.Header.DataType,
+			Container: p.Header.ContainerType,
+			ArraySize: p.Header.ValueCount,
+		}
+	}
+
+	if mm := class.Check(props); len(mm) > 0 {
+		return &ValidationError{Entity: e.Header.Address, Class: e.ClassNameString, Version: e.Header.Version, Mismatches: mm}
+	}
+
+	return nil
+}
+
+// FillDefaults adds static properties missing from entity with default values, properties are reordered as in
+// class definition, properties unknown to class are kept after known ones. Classes missing from registry
+// and entities without missing properties are not changed.
+func (e *Entity) FillDefaults(reg *schema.Registry) error {
+	class, ok := reg.Class(e.ClassNameString, e.Header.Version)
+	if !ok {
+		return nil
+	}
+
+	have := make(map[string]int, len(e.StaticProperties))
+	for i, p := range e.StaticProperties {
+		have[p.NameValue] = i
+	}
+
+	missing := false
+	for _, cp := range class.Properties {
+		if _, ok = have[cp.Name]; !ok {
+			missing = true
+			break
 		}
 	}
 
+	if !missing {
+		return nil
+	}
+
+	props := make([]Property, 0, len(class.Properties)+len(e.StaticProperties))
+	used := make([]bool, len(e.StaticProperties))
+	for _, cp := range class.Properties {
+		if i, ok := have[cp.Name]; ok {
+			props = append(props, e.StaticProperties[i])
+			used[i] = true
+			continue
+		}
+
+		p, err := NewProperty(cp)
+		if err != nil {
+			return &PropertyError{Entity: e.Header.Address, Property: cp.Name, Err: err}
+		}
+
+		props = append(props, p)
+	}
+
+	for i, p := range e.StaticProperties {
+		if !used[i] {
+			props = append(props, p)
+		}
+	}
+
+	e.StaticProperties = props
+
+	return nil
+}
+
+// ResolveProps sets names of static and dynamic properties, their stringMap keys and string values
+func (e *Entity) ResolveProps(names fox.Names) {
+	for i := range e.StaticProperties {
+		e.StaticProperties[i].resolve(names)
+	}
+
 	for i := range e.DynamicProperties {
-		e.DynamicProperties[i].NameValue, ok = dict[e.DynamicProperties[i].Header.NameHash]
-		if !ok {
-			e.DynamicProperties[i].NameValue = fmt.Sprintf("0x%X", e.DynamicProperties[i].Header.NameHash)
+		e.DynamicProperties[i].resolve(names)
+	}
+}
+
+func (p *Property) resolve(names fox.Names) {
+	var ok bool
+	if p.NameValue, ok = names(p.Header.NameHash); !ok {
+		p.NameValue = fox.UnresolvedName(p.Header.NameHash)
+	}
+
+	switch p.Header.ContainerType {
+	case containers.StringMap:
+		sm := (p.Value).(*containers.FoxStringMap)
+		for n := range sm.Data {
+			sm.Data[n].KeyString, ok = names(sm.Data[n].Key)
+			if !ok {
+				sm.Data[n].KeyString = fox.UnresolvedName(sm.Data[n].Key)
+			}
+
+			//slog.Info("resolving", "value", fmt.Sprintf("%+v", sm.Data[n].Value))
+			sm.Data[n].Value.Resolve(names)
+		}
+	case containers.StaticArray:
+		sm := (p.Value).(*containers.FoxStaticArray)
+		for e := range sm.Data {
+			sm.Data[e].Resolve(names)
+		}
+	case containers.DynamicArray:
+		sm := (p.Value).(*containers.FoxDynamicArray)
+		for e := range sm.Data {
+			sm.Data[e].Resolve(names)
+		}
+	case containers.List:
+		sm := (p.Value).(*containers.FoxList)
+		for e := range sm.Data {
+			sm.Data[e].Resolve(names)
 		}
 	}
 }
 
 func (e *Entity) Read(reader io.ReadSeeker) error {
-	var err error
+	o, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
 	if err = e.Header.Read(reader); err != nil {
-		return fmt.Errorf("header: %w", err)
+		return &CorruptEntryError{Offset: o, Reason: "cannot read entity header", Err: err}
+	}
+
+	if e.Header.Magic1 != EntityHeaderMagic {
+		return &CorruptEntryError{Offset: o, Reason: fmt.Sprintf("bad entity magic %x", e.Header.Magic1)}
 	}
 
 	if _, err = util.AlignRead(reader, 16); err != nil {
@@ -181,8 +325,8 @@ func (e *Entity) Read(reader io.ReadSeeker) error {
 		//o, _ = reader.Seek(0, io.SeekCurrent)
 		//slog.Info("read prop at", "v", o)
 		p := Property{}
-		if err = p.Read(reader); err != nil {
-			return fmt.Errorf("static prop: %w", err)
+		if err = e.readProperty(&p, reader); err != nil {
+			return &CorruptEntryError{Offset: o, Reason: "cannot read static property", Err: err}
 		}
 
 		e.StaticProperties = append(e.StaticProperties, p)
@@ -190,8 +334,8 @@ func (e *Entity) Read(reader io.ReadSeeker) error {
 
 	for i := 0; i < int(e.Header.DynamicPropertyCount); i++ {
 		p := Property{}
-		if err = p.Read(reader); err != nil {
-			return fmt.Errorf("dynamic prop: %w", err)
+		if err = e.readProperty(&p, reader); err != nil {
+			return &CorruptEntryError{Offset: o, Reason: "cannot read dynamic property", Err: err}
 		}
 
 		e.DynamicProperties = append(e.DynamicProperties, p)
@@ -200,6 +344,25 @@ func (e *Entity) Read(reader io.ReadSeeker) error {
 	return nil
 }
 
+// readProperty reads property, errors are returned as *PropertyError
+func (e *Entity) readProperty(p *Property, reader io.ReadSeeker) error {
+	o, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if err = p.Read(reader); err != nil {
+		return &PropertyError{
+			Entity:   e.Header.Address,
+			Property: fmt.Sprintf("0x%X", p.Header.NameHash),
+			Offset:   o,
+			Err:      err,
+		}
+	}
+
+	return nil
+}
+
 func (e *Entity) GetStrings() []string {
 	res := []string{e.ClassNameString}
 	for _, v := range e.StaticProperties {
@@ -219,7 +382,7 @@ func (e *Entity) Write(writer io.WriteSeeker) error {
 	e.Header.DynamicPropertyCount = uint16(len(e.DynamicProperties))
 	e.Header.StaticPropertyCount = uint16(len(e.StaticProperties))
 	e.Header.HeaderSize = EntityHeaderSize
-	e.Header.ClassNameHash = hashing.StrCode64([]byte(e.ClassNameString))
+	e.Header.ClassNameHash = fox.NameHash(e.ClassNameString)
 	e.Header.Magic1 = EntityHeaderMagic
 	e.Header.Offset = int32(EntityHeaderSize)
 
@@ -0,0 +1,80 @@
+package fox2
+
+import (
+	"fmt"
+	"github.com/unknown321/datfpk/fox2/schema"
+	"github.com/unknown321/datfpk/util"
+	"strings"
+)
+
+// ErrBadMagic is returned for data without fox2 header magic
+var ErrBadMagic = fmt.Errorf("fox2: %w", util.ErrBadMagic)
+
+// CorruptEntryError is returned when entity at Offset cannot be read.
+type CorruptEntryError struct {
+	Offset int64
+	Reason string
+	Err    error
+}
+
+func (e *CorruptEntryError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("corrupt fox2 entity at offset %d: %s", e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("corrupt fox2 entity at offset %d: %s: %s", e.Offset, e.Reason, e.Err.Error())
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
+
+// PropertyError is returned when property cannot be read or decoded.
+// Offset is set when reading fox2, Line is set when reading xml.
+type PropertyError struct {
+	Entity   uint64 // entity address
+	Property string
+	Offset   int64
+	Line     int
+	Err      error
+}
+
+func (e *PropertyError) Error() string {
+	where := fmt.Sprintf("offset %d", e.Offset)
+	if e.Line > 0 {
+		where = fmt.Sprintf("line %d", e.Line)
+	}
+
+	return fmt.Sprintf("entity 0x%X, property %s, %s: %s", e.Entity, e.Property, where, e.Err.Error())
+}
+
+func (e *PropertyError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists static properties of entity which do not match class definition, see schema.
+type ValidationError struct {
+	Entity     uint64 // entity address
+	Class      string
+	Version    int16
+	Mismatches []schema.Mismatch
+}
+
+func (e *ValidationError) Error() string {
+	ss := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		ss[i] = m.String()
+	}
+
+	return fmt.Sprintf("entity 0x%X, class %s version %d: %s", e.Entity, e.Class, e.Version, strings.Join(ss, "; "))
+}
+
+// UnknownClassError is returned for entity of class missing from schema when unknown classes are not allowed.
+type UnknownClassError struct {
+	Entity uint64 // address of the first entity of class
+	Class  string
+}
+
+func (e *UnknownClassError) Error() string {
+	return fmt.Sprintf("entity 0x%X, class %s is not in schema", e.Entity, e.Class)
+}
@@ -3,7 +3,10 @@ package fox2
 import (
 	"encoding/binary"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
 	"github.com/unknown321/datfpk/util"
 	"io"
 
@@ -16,13 +19,17 @@ type Fox2 struct {
 	Header               Header                `xml:"-"`
 	Entities             []Entity              `xml:"entities>entity"`
 	StringLookupLiterals []StringLookupLiteral `xml:"-"`
+	Schema               *schema.Registry      `xml:"-"` // entities are filled with defaults on Write if set
+	CheckSchema          bool                  `xml:"-"` // entities are validated against Schema on Write
+	StrictSchema         bool                  `xml:"-"` // classes missing from Schema are an error on validation
 }
 
 var Trailer = []byte{0x00, 0x00, 0x65, 0x6E, 0x64} // 0 0 end
 
 var fox2dict = make(map[uint64]string)
 
-func (f *Fox2) Read(reader io.ReadSeeker) error {
+// Read reads fox2 file. Hashes missing from the file's string table are looked up in names, in order.
+func (f *Fox2) Read(reader io.ReadSeeker, names ...fox.Names) error {
 	var err error
 	if err = f.Header.Read(reader); err != nil {
 		return fmt.Errorf("header: %w", err)
@@ -57,8 +64,26 @@ func (f *Fox2) Read(reader io.ReadSeeker) error {
 		resolveMap[ss.Hash] = ss.Literal
 	}
 
+	resolve := func(hash uint64) (string, bool) {
+		if s, ok := resolveMap[hash]; ok {
+			return s, true
+		}
+
+		for _, n := range names {
+			if n == nil {
+				continue
+			}
+
+			if s, ok := n(hash); ok {
+				return s, true
+			}
+		}
+
+		return "", false
+	}
+
 	for i := range f.Entities {
-		f.Entities[i].Resolve(resolveMap)
+		f.Entities[i].Resolve(resolve)
 	}
 
 	return nil
@@ -102,6 +127,11 @@ func (f *Fox2) CollectLiterals() {
 	ls := []string{}
 Outer:
 	for _, s := range ss {
+		// unresolved hashes are written as is, they have no literal
+		if fox.IsUnresolvedName(s) {
+			continue
+		}
+
 		for _, v := range ls {
 			if v == s {
 				continue Outer
@@ -121,9 +151,70 @@ Outer:
 	}
 }
 
+// Validate checks every entity against registry, errors are ValidationError.
+func (f *Fox2) Validate(reg *schema.Registry) error {
+	var errs []error
+	for i := range f.Entities {
+		if err := f.Entities[i].Validate(reg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckClasses returns UnknownClassError for every class of entities missing from registry.
+func (f *Fox2) CheckClasses(reg *schema.Registry) error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, e := range f.Entities {
+		if seen[e.ClassNameString] || len(reg.Versions(e.ClassNameString)) > 0 {
+			continue
+		}
+
+		seen[e.ClassNameString] = true
+		errs = append(errs, &UnknownClassError{Entity: e.Header.Address, Class: e.ClassNameString})
+	}
+
+	return errors.Join(errs...)
+}
+
+// FillDefaults adds missing static properties to every entity, see Entity.FillDefaults.
+func (f *Fox2) FillDefaults(reg *schema.Registry) error {
+	for i := range f.Entities {
+		if err := f.Entities[i].FillDefaults(reg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (f *Fox2) Write(writer io.WriteSeeker) error {
 	var err error
 
+	if err = f.Allocate(); err != nil {
+		return fmt.Errorf("allocate: %w", err)
+	}
+
+	if f.Schema != nil {
+		if err = f.FillDefaults(f.Schema); err != nil {
+			return fmt.Errorf("fill defaults: %w", err)
+		}
+
+		if f.CheckSchema {
+			if err = f.Validate(f.Schema); err != nil {
+				return fmt.Errorf("validate: %w", err)
+			}
+
+			if f.StrictSchema {
+				if err = f.CheckClasses(f.Schema); err != nil {
+					return fmt.Errorf("validate: %w", err)
+				}
+			}
+		}
+	}
+
 	if _, err = writer.Seek(FoxHeaderSize, io.SeekCurrent); err != nil {
 		return err
 	}
@@ -3,9 +3,14 @@ package fox2
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
 	"github.com/unknown321/datfpk/util"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -40,6 +45,59 @@ func TestFox2_Read(t *testing.T) {
 	}
 }
 
+func TestFox2_ReadNames(t *testing.T) {
+	data, err := os.ReadFile("testdata/game/title_sequence.fox2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	want := &bytes.Buffer{}
+	if err = f.ToXML(want); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	names := map[uint64]string{}
+	for _, s := range f.StringLookupLiterals {
+		names[s.Hash] = s.Literal
+	}
+
+	// file without string table
+	stripped := data[:f.Header.StringTableOffset]
+
+	f = &Fox2{}
+	if err = f.Read(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if f.Entities[0].ClassNameString != fmt.Sprintf("0x%X", f.Entities[0].Header.ClassNameHash) {
+		t.Errorf("want unresolved class name, have %s", f.Entities[0].ClassNameString)
+	}
+
+	lookup := func(hash uint64) (string, bool) {
+		s, ok := names[hash]
+		return s, ok
+	}
+
+	f = &Fox2{}
+	if err = f.Read(bytes.NewReader(stripped), nil, lookup); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	have := &bytes.Buffer{}
+	if err = f.ToXML(have); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !bytes.Equal(want.Bytes(), have.Bytes()) {
+		t.Errorf("xml differs from xml of file with string table")
+	}
+}
+
 func TestFox2_Marshal(t *testing.T) {
 	type args struct {
 		filename string
@@ -482,6 +540,140 @@ func TestFox2_Write(t *testing.T) {
 	}
 }
 
+func TestFox2_WriteUnresolved(t *testing.T) {
+	tests := []string{
+		"testdata/game/title_sequence.fox2",
+		"testdata/types/entitylink.foxtool.fox2",
+		"testdata/types/fileptr.foxtool.fox2",
+		"testdata/types/path.foxtool.fox2",
+		"testdata/types/string.foxtool.fox2",
+		"testdata/containers/stringmapWithString.foxtool.fox2",
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt), func(t *testing.T) {
+			data, err := os.ReadFile(tt)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f := &Fox2{}
+			if err = f.Read(bytes.NewReader(data)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			// without string table every string is unresolved
+			entities := data[:f.Header.StringTableOffset]
+			f = &Fox2{}
+			if err = f.Read(bytes.NewReader(entities)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			x := &bytes.Buffer{}
+			if err = f.ToXML(x); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f = &Fox2{}
+			if err = f.FromXML(x); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			outB := util.NewByteArrayReaderWriter([]byte{})
+			if err = f.Write(outB); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			out := outB.Bytes()
+			if len(out) < len(entities) || !bytes.Equal(out[:len(entities)], entities) {
+				t.Fatalf("entities differ")
+			}
+
+			for _, s := range f.StringLookupLiterals {
+				if fox.IsUnresolvedName(s.Literal) {
+					t.Errorf("unresolved name %s in string table", s.Literal)
+				}
+			}
+		})
+	}
+}
+
+func TestFox2_DynamicUnresolved(t *testing.T) {
+	in := `<fox formatVersion="2" fileVersion="0">
+  <entities>
+    <entity class="DataSet" classVersion="0" classID="0xE8" addr="0x2D752C0" id="0x50EE0">
+      <staticProperties></staticProperties>
+      <dynamicProperties>
+        <property name="str" type="String" container="StaticArray" arraySize="1">
+          <containerEntry hash="0x1234"></containerEntry>
+        </property>
+        <property name="file" type="FilePtr" container="StaticArray" arraySize="1">
+          <containerEntry hash="0x5678"></containerEntry>
+        </property>
+        <property name="map" type="String" container="StringMap" arraySize="1">
+          <containerEntry key="dynamic_key">
+            <data>dynamic_value</data>
+          </containerEntry>
+        </property>
+      </dynamicProperties>
+    </entity>
+  </entities>
+</fox>`
+
+	f := &Fox2{}
+	if err := f.FromXML(bytes.NewReader([]byte(in))); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	outB := util.NewByteArrayReaderWriter([]byte{})
+	if err := f.Write(outB); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	want := outB.Bytes()
+
+	// strings of dynamic properties are looked up in names without string table
+	entities := want[:f.Header.StringTableOffset]
+	known := map[uint64]string{}
+	for _, s := range []string{"dynamic_key", "dynamic_value"} {
+		known[fox.NameHash(s)] = s
+	}
+	names := func(hash uint64) (string, bool) {
+		s, ok := known[hash]
+		return s, ok
+	}
+
+	f = &Fox2{}
+	if err := f.Read(bytes.NewReader(entities), names); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	x := &bytes.Buffer{}
+	if err := f.ToXML(x); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, s := range []string{`hash="0x1234"`, `hash="0x5678"`, `key="dynamic_key"`, "dynamic_value"} {
+		if !bytes.Contains(x.Bytes(), []byte(s)) {
+			t.Fatalf("no %s in %s", s, x.String())
+		}
+	}
+
+	// hashes of unresolved dynamic values are kept
+	f = &Fox2{}
+	if err := f.FromXML(x); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	outB = util.NewByteArrayReaderWriter([]byte{})
+	if err := f.Write(outB); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !bytes.Equal(outB.Bytes()[:len(entities)], entities) {
+		t.Fatalf("entities differ")
+	}
+}
+
 func TestFox2_WriteFromXML(t *testing.T) {
 	type args struct {
 		in       string
@@ -737,3 +929,229 @@ func TestFox2_WriteFromXML(t *testing.T) {
 		})
 	}
 }
+
+func TestFox2_Validate(t *testing.T) {
+	reg, err := schema.Default()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	files := []string{
+		"testdata/game/title_sequence.fox2.xml",
+		"testdata/game/player2_add_parts_prqst_x1.fox2.xml",
+	}
+
+	for _, name := range files {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f := &Fox2{}
+			if err = f.FromXML(bytes.NewReader(data)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if err = f.Validate(reg); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			// classes missing from schema are not checked
+			f.Entities[0].ClassNameString = "NoSuchClass"
+			f.Entities[0].StaticProperties = nil
+			if err = f.Validate(reg); err != nil {
+				t.Errorf("want no error for unknown class, have %s", err.Error())
+			}
+
+			var ue *UnknownClassError
+			if err = f.CheckClasses(reg); !errors.As(err, &ue) || ue.Class != "NoSuchClass" {
+				t.Errorf("want *UnknownClassError, have %v", err)
+			}
+
+			f = &Fox2{Schema: reg, CheckSchema: true}
+			if err = f.FromXML(bytes.NewReader(data)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f.Entities[1].StaticProperties[0].Header.DataType = fox.FPath
+			f.Entities[1].Header.Version++
+
+			var ve *ValidationError
+			if err = f.Write(util.NewByteArrayReaderWriter([]byte{})); !errors.As(err, &ve) {
+				t.Fatalf("want *ValidationError, have %v", err)
+			}
+
+			if ve.Entity != f.Entities[1].Header.Address || ve.Mismatches[0].Problem != schema.ProblemVersion {
+				t.Errorf("unexpected error %s", ve.Error())
+			}
+
+			f.Entities[1].Header.Version--
+			ve = nil
+			if err = f.Write(util.NewByteArrayReaderWriter([]byte{})); !errors.As(err, &ve) {
+				t.Fatalf("want *ValidationError, have %v", err)
+			}
+
+			want := schema.Mismatch{Property: f.Entities[1].StaticProperties[0].NameValue, Problem: schema.ProblemType, Want: "String", Have: "Path"}
+			if len(ve.Mismatches) != 1 || ve.Mismatches[0] != want {
+				t.Errorf("want %v, have %v", want, ve.Mismatches)
+			}
+		})
+	}
+}
+
+func TestFox2_FillDefaults(t *testing.T) {
+	reg, err := schema.Default()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	files := []string{
+		"testdata/game/title_sequence.fox2.xml",
+		"testdata/game/player2_add_parts_prqst_x1.fox2.xml",
+	}
+
+	for _, name := range files {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f := &Fox2{}
+			if err = f.FromXML(bytes.NewReader(data)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			full := util.NewByteArrayReaderWriter([]byte{})
+			if err = f.Write(full); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			// drop properties with default values, reverse the rest,
+			// order of entities without missing properties is kept
+			removed := 0
+			for i, e := range f.Entities {
+				class, ok := reg.Class(e.ClassNameString, e.Header.Version)
+				if !ok {
+					t.Fatalf("no class %s", e.ClassNameString)
+				}
+
+				var sparse []Property
+				before := removed
+				for _, p := range e.StaticProperties {
+					def := class.Properties[slices.IndexFunc(class.Properties, func(cp schema.Property) bool { return cp.Name == p.NameValue })]
+					dp, err := NewProperty(def)
+					if err != nil {
+						t.Fatalf("%s", err.Error())
+					}
+
+					have, _ := xml.Marshal(&p)
+					want, _ := xml.Marshal(&dp)
+					if bytes.Equal(have, want) {
+						removed++
+						continue
+					}
+
+					sparse = append([]Property{p}, sparse...)
+				}
+
+				if removed > before {
+					f.Entities[i].StaticProperties = sparse
+				}
+			}
+
+			if removed == 0 {
+				t.Fatalf("no default properties")
+			}
+
+			x := &bytes.Buffer{}
+			if err = f.ToXML(x); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f = &Fox2{Schema: reg}
+			if err = f.FromXML(x); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			out := util.NewByteArrayReaderWriter([]byte{})
+			if err = f.Write(out); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !bytes.Equal(out.Bytes(), full.Bytes()) {
+				t.Errorf("filled file differs from original")
+			}
+		})
+	}
+}
+
+func TestFox2_ReadErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/game/title_sequence.fox2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	badMagic := bytes.Clone(data)
+	badMagic[0] ^= 0xFF
+
+	f := Fox2{}
+	if err = f.Read(bytes.NewReader(badMagic)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("want ErrBadMagic, have %v", err)
+	}
+
+	// first entity starts right after header
+	badEntity := bytes.Clone(data)
+	badEntity[FoxHeaderSize+6] ^= 0xFF
+
+	var corrupt *CorruptEntryError
+	f = Fox2{}
+	if err = f.Read(bytes.NewReader(badEntity)); !errors.As(err, &corrupt) || corrupt.Offset != FoxHeaderSize {
+		t.Errorf("want *CorruptEntryError at offset %d, have %v", FoxHeaderSize, err)
+	}
+
+	// data type of first property
+	propOffset := int64(FoxHeaderSize) + int64(EntityHeaderSize)
+	badType := bytes.Clone(data)
+	badType[propOffset+8] = byte(fox.FPropertyInfo)
+
+	var pe *PropertyError
+	f = Fox2{}
+	if err = f.Read(bytes.NewReader(badType)); !errors.As(err, &pe) || pe.Offset != propOffset || !errors.Is(err, fox.ErrNotImplemented) {
+		t.Errorf("want *PropertyError at offset %d, have %v", propOffset, err)
+	}
+}
+
+func addSeeds(f *testing.F, pattern string) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		f.Fatalf("%s", err.Error())
+	}
+
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatalf("%s", err.Error())
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzFox2_Read(f *testing.F) {
+	addSeeds(f, "testdata/*/*.fox2")
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ff := Fox2{}
+		_ = ff.Read(bytes.NewReader(data))
+	})
+}
+
+func FuzzFox2_FromXML(f *testing.F) {
+	addSeeds(f, "testdata/*/*.fox2.xml")
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ff := Fox2{}
+		_ = ff.FromXML(bytes.NewReader(data))
+	})
+}
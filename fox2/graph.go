@@ -0,0 +1,348 @@
+package fox2
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"io"
+	"sort"
+	"strings"
+)
+
+// EdgeKind is a data type of reference
+type EdgeKind string
+
+const (
+	EdgePtr    EdgeKind = "EntityPtr"
+	EdgeHandle EdgeKind = "EntityHandle"
+	EdgeLink   EdgeKind = "EntityLink"
+)
+
+// GraphNode is an entity
+type GraphNode struct {
+	Address uint64
+	Class   string
+	Name    string // see Entity.Symbol
+}
+
+// GraphEdge is a reference from entity property to entity address, null references are not included.
+type GraphEdge struct {
+	From     uint64
+	To       uint64
+	Kind     EdgeKind
+	Property string
+	Key      string // stringMap key or array index
+
+	// EntityLink target package and archive, links to other packages are not checked
+	PackagePath string
+	ArchivePath string
+	External    bool
+}
+
+func (e GraphEdge) label() string {
+	if e.Key == "" {
+		return e.Property
+	}
+
+	return fmt.Sprintf("%s[%s]", e.Property, e.Key)
+}
+
+// Graph is an entity reference graph of fox2 file
+type Graph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+
+	index map[uint64]int // first node with address
+}
+
+// NewGraph collects references from static and dynamic properties of every entity.
+func NewGraph(f *Fox2) *Graph {
+	g := &Graph{index: make(map[uint64]int)}
+	for _, e := range f.Entities {
+		node := GraphNode{Address: e.Header.Address, Class: e.ClassNameString, Name: e.Symbol()}
+
+		if _, ok := g.index[node.Address]; !ok {
+			g.index[node.Address] = len(g.Nodes)
+		}
+		g.Nodes = append(g.Nodes, node)
+
+		for _, props := range [][]Property{e.StaticProperties, e.DynamicProperties} {
+			for _, p := range props {
+				g.addEdges(e.Header.Address, p)
+			}
+		}
+	}
+
+	return g
+}
+
+// isExternal is true for links with package or archive path, they point to entities of other files
+func isExternal(l *fox.EntityLink) bool {
+	empty := fox.NameHash("")
+	return fox.ValueHash(l.PackagePath, l.PackagePathHash, l.PackagePathUnresolved) != empty ||
+		fox.ValueHash(l.ArchivePath, l.ArchivePathHash, l.ArchivePathUnresolved) != empty
+}
+
+func (g *Graph) addEdges(from uint64, p Property) {
+	p.each(func(key string, v fox.DataType) {
+		edge := GraphEdge{From: from, Property: p.NameValue, Key: key}
+		switch t := v.(type) {
+		case *fox.EntityPtr:
+			edge.Kind, edge.To = EdgePtr, t.Value
+		case *fox.EntityHandle:
+			edge.Kind, edge.To = EdgeHandle, t.Value
+		case *fox.EntityLink:
+			edge.Kind, edge.To = EdgeLink, t.EntityHandle
+			edge.PackagePath, edge.ArchivePath = t.PackagePath, t.ArchivePath
+			edge.External = isExternal(t)
+		default:
+			return
+		}
+
+		if edge.To == 0 {
+			return
+		}
+
+		g.Edges = append(g.Edges, edge)
+	})
+}
+
+// Node returns entity by address
+func (g *Graph) Node(address uint64) (GraphNode, bool) {
+	i, ok := g.index[address]
+	if !ok {
+		return GraphNode{}, false
+	}
+
+	return g.Nodes[i], true
+}
+
+// Dangling returns references to addresses missing from file, external links are not included.
+func (g *Graph) Dangling() []GraphEdge {
+	var res []GraphEdge
+	for _, e := range g.Edges {
+		if e.External {
+			continue
+		}
+
+		if _, ok := g.index[e.To]; !ok {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// Duplicates returns sorted addresses shared by several entities
+func (g *Graph) Duplicates() []uint64 {
+	count := make(map[uint64]int, len(g.Nodes))
+	var res []uint64
+	for _, n := range g.Nodes {
+		count[n.Address]++
+		if count[n.Address] == 2 {
+			res = append(res, n.Address)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res
+}
+
+// Roots returns addresses references are followed from: DataSet entities,
+// or entities without incoming references if there is no DataSet.
+func (g *Graph) Roots() []uint64 {
+	var res []uint64
+	for _, n := range g.Nodes {
+		if n.Class == DataSetClass {
+			res = append(res, n.Address)
+		}
+	}
+
+	if len(res) > 0 {
+		return res
+	}
+
+	referenced := make(map[uint64]bool, len(g.Edges))
+	for _, e := range g.Edges {
+		if e.From != e.To {
+			referenced[e.To] = true
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if !referenced[n.Address] {
+			res = append(res, n.Address)
+		}
+	}
+
+	return res
+}
+
+// Unreachable returns entities which cannot be reached from roots, see Roots.
+func (g *Graph) Unreachable() []GraphNode {
+	out := make(map[uint64][]uint64)
+	for _, e := range g.Edges {
+		if !e.External {
+			out[e.From] = append(out[e.From], e.To)
+		}
+	}
+
+	seen := make(map[uint64]bool, len(g.Nodes))
+	queue := g.Roots()
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		if seen[a] {
+			continue
+		}
+
+		seen[a] = true
+		queue = append(queue, out[a]...)
+	}
+
+	var res []GraphNode
+	for _, n := range g.Nodes {
+		if !seen[n.Address] {
+			res = append(res, n)
+		}
+	}
+
+	return res
+}
+
+func hexAddress(a uint64) string {
+	return fmt.Sprintf("0x%X", a)
+}
+
+// dotQuote quotes DOT id, newlines become line breaks of label
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteDOT writes graph in Graphviz format, dangling references point to red nodes.
+func (g *Graph) WriteDOT(writer io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph fox2 {\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for i, n := range g.Nodes {
+		if g.index[n.Address] != i {
+			continue
+		}
+
+		label := n.Class + "\n" + hexAddress(n.Address)
+		if n.Name != "" {
+			label = n.Class + "\n" + n.Name + "\n" + hexAddress(n.Address)
+		}
+		fmt.Fprintf(b, "  %s [label=%s];\n", dotQuote(hexAddress(n.Address)), dotQuote(label))
+	}
+
+	for _, a := range g.Duplicates() {
+		fmt.Fprintf(b, "  %s [color=red];\n", dotQuote(hexAddress(a)))
+	}
+
+	extra := map[string]bool{} // nodes of dangling and external references
+	for _, e := range g.Dangling() {
+		if extra[hexAddress(e.To)] {
+			continue
+		}
+		extra[hexAddress(e.To)] = true
+		fmt.Fprintf(b, "  %s [label=%s, color=red, style=dashed];\n", dotQuote(hexAddress(e.To)), dotQuote("missing\n"+hexAddress(e.To)))
+	}
+
+	for _, e := range g.Edges {
+		to := hexAddress(e.To)
+		if e.External {
+			to = e.PackagePath + ":" + e.ArchivePath + ":" + to
+			if !extra[to] {
+				extra[to] = true
+				fmt.Fprintf(b, "  %s [shape=ellipse, style=dashed];\n", dotQuote(to))
+			}
+		}
+
+		style := ""
+		if e.Kind == EdgeHandle {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(b, "  %s -> %s [label=%s%s];\n", dotQuote(hexAddress(e.From)), dotQuote(to), dotQuote(e.label()), style)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(writer, b.String())
+	return err
+}
+
+type graphNodeJson struct {
+	Address string `json:"address"`
+	Class   string `json:"class"`
+	Name    string `json:"name,omitempty"`
+}
+
+type graphEdgeJson struct {
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Kind        EdgeKind `json:"kind"`
+	Property    string   `json:"property"`
+	Key         string   `json:"key,omitempty"`
+	PackagePath string   `json:"packagePath,omitempty"`
+	ArchivePath string   `json:"archivePath,omitempty"`
+	External    bool     `json:"external,omitempty"`
+}
+
+type graphJson struct {
+	Nodes       []graphNodeJson `json:"nodes"`
+	Edges       []graphEdgeJson `json:"edges"`
+	Dangling    []graphEdgeJson `json:"dangling"`
+	Duplicates  []string        `json:"duplicates"`
+	Unreachable []string        `json:"unreachable"`
+}
+
+// WriteJSON writes nodes, edges and found problems, addresses are hex strings as in xml.
+func (g *Graph) WriteJSON(writer io.Writer) error {
+	edge := func(e GraphEdge) graphEdgeJson {
+		return graphEdgeJson{
+			From:        hexAddress(e.From),
+			To:          hexAddress(e.To),
+			Kind:        e.Kind,
+			Property:    e.Property,
+			Key:         e.Key,
+			PackagePath: e.PackagePath,
+			ArchivePath: e.ArchivePath,
+			External:    e.External,
+		}
+	}
+
+	gj := graphJson{
+		Nodes:       []graphNodeJson{},
+		Edges:       []graphEdgeJson{},
+		Dangling:    []graphEdgeJson{},
+		Duplicates:  []string{},
+		Unreachable: []string{},
+	}
+
+	for _, n := range g.Nodes {
+		gj.Nodes = append(gj.Nodes, graphNodeJson{Address: hexAddress(n.Address), Class: n.Class, Name: n.Name})
+	}
+
+	for _, e := range g.Edges {
+		gj.Edges = append(gj.Edges, edge(e))
+	}
+
+	for _, e := range g.Dangling() {
+		gj.Dangling = append(gj.Dangling, edge(e))
+	}
+
+	for _, a := range g.Duplicates() {
+		gj.Duplicates = append(gj.Duplicates, hexAddress(a))
+	}
+
+	for _, n := range g.Unreachable() {
+		gj.Unreachable = append(gj.Unreachable, hexAddress(n.Address))
+	}
+
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	return enc.Encode(gj)
+}
@@ -0,0 +1,114 @@
+package fox2
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	data, err := os.ReadFile("testdata/game/title_sequence.fox2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	g := NewGraph(f)
+	if len(g.Nodes) != 3 || len(g.Edges) != 4 {
+		t.Fatalf("want 3 nodes and 4 edges, have %d and %d", len(g.Nodes), len(g.Edges))
+	}
+
+	if len(g.Dangling()) > 0 || len(g.Duplicates()) > 0 || len(g.Unreachable()) > 0 {
+		t.Fatalf("unexpected problems %v %v %v", g.Dangling(), g.Duplicates(), g.Unreachable())
+	}
+
+	// dataList of DataSet points to missing entity, orphan shares address with DataSet,
+	// loose entity is not referenced by anyone
+	dataList := f.Entities[0].StaticProperties[2].Value.(*containers.FoxStringMap)
+	dataList.Data[0].Value.(*fox.EntityPtr).Value = 0xDEAD
+
+	orphan := f.Entities[1]
+	loose := f.Entities[2]
+	loose.Header.Address = 0x1234
+	f.Entities = append(f.Entities, orphan, loose)
+	f.Entities[3].Header.Address = f.Entities[0].Header.Address
+
+	g = NewGraph(f)
+	dangling := g.Dangling()
+	if len(dangling) != 1 || dangling[0].To != 0xDEAD || dangling[0].Property != "dataList" || dangling[0].Key != "init_mission_data" {
+		t.Errorf("unexpected dangling %+v", dangling)
+	}
+
+	if d := g.Duplicates(); len(d) != 1 || d[0] != f.Entities[0].Header.Address {
+		t.Errorf("unexpected duplicates %v", d)
+	}
+
+	unreachable := g.Unreachable()
+	if len(unreachable) != 2 || unreachable[0].Address != f.Entities[1].Header.Address || unreachable[1].Address != 0x1234 {
+		t.Errorf("unexpected unreachable %+v", unreachable)
+	}
+
+	dot := &bytes.Buffer{}
+	if err = g.WriteDOT(dot); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !strings.Contains(dot.String(), `"0x2D752C0" -> "0xDEAD" [label="dataList[init_mission_data]"];`) {
+		t.Errorf("no dangling edge in\n%s", dot.String())
+	}
+
+	out := &bytes.Buffer{}
+	if err = g.WriteJSON(out); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	gj := graphJson{}
+	if err = json.Unmarshal(out.Bytes(), &gj); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if len(gj.Nodes) != 5 || len(gj.Dangling) != 1 || gj.Dangling[0].To != "0xDEAD" || len(gj.Unreachable) != 2 {
+		t.Errorf("unexpected json %s", out.String())
+	}
+}
+
+func TestGraph_EntityLink(t *testing.T) {
+	data, err := os.ReadFile("testdata/types/entitylink.foxtool.fox2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	g := NewGraph(f)
+	if len(g.Edges) != 2 || !g.Edges[0].External || g.Edges[0].Kind != EdgeLink || g.Edges[0].Key != "0" {
+		t.Fatalf("unexpected edges %+v", g.Edges)
+	}
+
+	if len(g.Dangling()) > 0 {
+		t.Errorf("links to other files are not dangling, have %+v", g.Dangling())
+	}
+
+	// link without package and archive points into the same file
+	f.Entities[0].StaticProperties[0].Value.(*containers.FoxStaticArray).Data[1] = &fox.EntityLink{
+		PackagePath:   "",
+		ArchivePath:   "",
+		NameInArchive: "local",
+		EntityHandle:  0xBEEF,
+	}
+
+	if d := NewGraph(f).Dangling(); len(d) != 1 || d[0].To != 0xBEEF || d[0].External {
+		t.Errorf("unexpected dangling %+v", d)
+	}
+}
@@ -2,6 +2,7 @@ package fox2
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -20,7 +21,15 @@ type Header struct {
 const FoxHeaderSize = 32
 
 func (h *Header) Read(reader io.Reader) error {
-	return binary.Read(reader, binary.LittleEndian, h)
+	if err := binary.Read(reader, binary.LittleEndian, h); err != nil {
+		return err
+	}
+
+	if h.Magic1 != Magic1 {
+		return fmt.Errorf("%w: %x", ErrBadMagic, h.Magic1)
+	}
+
+	return nil
 }
 
 func (h *Header) Write(writer io.Writer) error {
@@ -0,0 +1,337 @@
+package fox2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"strconv"
+	"strings"
+)
+
+// DataSetClass owns other entities of file, its dataList property is a stringMap of EntityPtr.
+const DataSetClass = "DataSet"
+
+// MergeOptions control Merge
+type MergeOptions struct {
+	// Select chooses entities of source to copy with their dependencies, nil copies every entity.
+	Select func(e *Entity) bool
+	// Renumber renumbers result, see Fox2.Renumber.
+	Renumber bool
+}
+
+// clone returns deep copy of entity
+func (e *Entity) clone() (Entity, error) {
+	res := Entity{}
+	data, err := xml.Marshal(e)
+	if err != nil {
+		return res, fmt.Errorf("entity 0x%X: %w", e.Header.Address, err)
+	}
+
+	if err = xml.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("entity 0x%X: %w", e.Header.Address, err)
+	}
+
+	res.idMissing = e.idMissing
+
+	return res, nil
+}
+
+// clone returns deep copy of file, string literals are collected again
+func (f *Fox2) clone() (*Fox2, error) {
+	res := &Fox2{FormatVersion: f.FormatVersion, FileVersion: f.FileVersion, Schema: f.Schema}
+	for i := range f.Entities {
+		e, err := f.Entities[i].clone()
+		if err != nil {
+			return nil, err
+		}
+		res.Entities = append(res.Entities, e)
+	}
+
+	res.CollectLiterals()
+
+	return res, nil
+}
+
+// dataList returns dataList property of DataSet, nil for other entities
+func (e *Entity) dataList() *Property {
+	if e.ClassNameString != DataSetClass {
+		return nil
+	}
+
+	for i, p := range e.StaticProperties {
+		if _, ok := p.Value.(*containers.FoxStringMap); ok && p.NameValue == "dataList" {
+			return &e.StaticProperties[i]
+		}
+	}
+
+	return nil
+}
+
+// dependencies returns indexes of selected entities and entities they refer to by EntityPtr and EntityHandle,
+// transitively, in file order. DataSet entities are not followed, they own everything.
+func (f *Fox2) dependencies(selected []int) []int {
+	index := make(map[uint64]int, len(f.Entities))
+	for i := len(f.Entities) - 1; i >= 0; i-- {
+		index[f.Entities[i].Header.Address] = i
+	}
+
+	seen := make([]bool, len(f.Entities))
+	queue := selected
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+
+		e := &f.Entities[i]
+		for _, props := range [][]Property{e.StaticProperties, e.DynamicProperties} {
+			for _, p := range props {
+				p.each(func(key string, v fox.DataType) {
+					var a uint64
+					switch t := v.(type) {
+					case *fox.EntityPtr:
+						a = t.Value
+					case *fox.EntityHandle:
+						a = t.Value
+					default:
+						return
+					}
+
+					if j, ok := index[a]; ok && a != 0 && f.Entities[j].ClassNameString != DataSetClass {
+						queue = append(queue, j)
+					}
+				})
+			}
+		}
+	}
+
+	var res []int
+	for i := range seen {
+		if seen[i] {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// Extract returns new file with entities matching predicate and their dependencies, see dependencies.
+// DataSet entities of f are copied with dataList entries of extracted entities only.
+func Extract(f *Fox2, predicate func(e *Entity) bool) (*Fox2, error) {
+	var selected []int
+	for i := range f.Entities {
+		if f.Entities[i].ClassNameString != DataSetClass && predicate(&f.Entities[i]) {
+			selected = append(selected, i)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no entities selected")
+	}
+
+	keep := make(map[int]bool)
+	extracted := make(map[uint64]bool)
+	for _, i := range f.dependencies(selected) {
+		keep[i] = true
+		extracted[f.Entities[i].Header.Address] = true
+	}
+
+	res := &Fox2{FormatVersion: f.FormatVersion, FileVersion: f.FileVersion, Schema: f.Schema}
+	for i := range f.Entities {
+		isDataSet := f.Entities[i].ClassNameString == DataSetClass
+		if !keep[i] && !isDataSet {
+			continue
+		}
+
+		e, err := f.Entities[i].clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if p := e.dataList(); p != nil {
+			sm := p.Value.(*containers.FoxStringMap)
+			var data []containers.FoxStringMapEntry
+			for _, entry := range sm.Data {
+				if ptr, ok := entry.Value.(*fox.EntityPtr); ok && extracted[ptr.Value] {
+					data = append(data, entry)
+				}
+			}
+			sm.Data = data
+			p.Header.ValueCount = int16(len(data))
+		}
+
+		res.Entities = append(res.Entities, e)
+	}
+
+	res.CollectLiterals()
+
+	return res, nil
+}
+
+// Merge returns copy of a with entities of b selected by opts and their dependencies. Copied entities with
+// addresses used in a get new ones, references are rewritten, references to DataSet of b point to the first
+// DataSet of a. DataSet membership is copied to dataList of a, keys already present in a get a new numeric suffix and
+// entities named after them are renamed, see uniqueKey.
+func Merge(a, b *Fox2, opts MergeOptions) (*Fox2, error) {
+	sel := opts.Select
+	if sel == nil {
+		sel = func(*Entity) bool { return true }
+	}
+
+	src, err := Extract(b, sel)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+
+	res, err := a.clone()
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	var target *Entity
+	used := make(map[uint64]bool, len(res.Entities))
+	usedIDs := make(map[uint64]bool, len(res.Entities))
+	var maxAddress, maxID uint64
+	for i := range res.Entities {
+		e := &res.Entities[i]
+		if target == nil && e.dataList() != nil {
+			target = e
+		}
+
+		used[e.Header.Address] = true
+		usedIDs[e.Header.ID] = true
+		maxAddress = max(maxAddress, e.Header.Address)
+		maxID = max(maxID, e.Header.ID)
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("target has no DataSet")
+	}
+
+	for _, e := range src.Entities {
+		maxAddress = max(maxAddress, e.Header.Address)
+		maxID = max(maxID, e.Header.ID)
+	}
+
+	next := FirstAddress
+	if maxAddress >= FirstAddress {
+		next = (maxAddress/AddressStep + 1) * AddressStep
+	}
+
+	addresses := make(map[uint64]uint64)
+	var entities, dataSets []Entity
+	for _, e := range src.Entities {
+		if e.ClassNameString == DataSetClass {
+			addresses[e.Header.Address] = target.Header.Address
+			dataSets = append(dataSets, e)
+			continue
+		}
+
+		if e.Header.Address == 0 || used[e.Header.Address] {
+			// null references stay null
+			if e.Header.Address != 0 {
+				addresses[e.Header.Address] = next
+			}
+			e.Header.Address = next
+			next += AddressStep
+		}
+		used[e.Header.Address] = true
+
+		if e.Header.ID != 0 && usedIDs[e.Header.ID] {
+			maxID++
+			e.Header.ID = maxID
+		}
+		usedIDs[e.Header.ID] = true
+
+		entities = append(entities, e)
+	}
+
+	remapReferences(entities, addresses)
+	remapReferences(dataSets, addresses)
+
+	p := target.dataList()
+	targetList := p.Value.(*containers.FoxStringMap)
+	keys := make(map[string]bool, len(targetList.Data))
+	for _, have := range targetList.Data {
+		keys[have.KeyString] = true
+	}
+
+	for _, ds := range dataSets {
+		dl := ds.dataList()
+		if dl == nil {
+			continue
+		}
+
+		for _, entry := range dl.Value.(*containers.FoxStringMap).Data {
+			if keys[entry.KeyString] {
+				key := uniqueKey(entry.KeyString, keys)
+				if ptr, ok := entry.Value.(*fox.EntityPtr); ok {
+					renameEntity(entities, ptr.Value, entry.KeyString, key)
+				}
+				entry.KeyString = key
+			}
+			keys[entry.KeyString] = true
+
+			targetList.Data = append(targetList.Data, entry)
+		}
+	}
+	p.Header.ValueCount = int16(len(targetList.Data))
+
+	res.Entities = append(res.Entities, entities...)
+
+	if opts.Renumber {
+		if err = res.Renumber(); err != nil {
+			return nil, err
+		}
+	}
+
+	res.CollectLiterals()
+
+	return res, nil
+}
+
+// uniqueKey returns key missing from used: trailing number of key is incremented, keeping its width,
+// TexturePackLoadConditioner0000 becomes TexturePackLoadConditioner0001, Locator12 becomes Locator13.
+// Keys without number get a 4 digit one.
+func uniqueKey(key string, used map[string]bool) string {
+	prefix := strings.TrimRight(key, "0123456789")
+	digits := key[len(prefix):]
+	n, _ := strconv.Atoi(digits)
+	width := len(digits)
+	if width == 0 {
+		width = 4
+	}
+
+	for {
+		n++
+		res := fmt.Sprintf("%s%0*d", prefix, width, n)
+		if !used[res] {
+			return res
+		}
+	}
+}
+
+// renameEntity sets name property of entity at address to name if it was old
+func renameEntity(entities []Entity, address uint64, old, name string) {
+	for i := range entities {
+		if entities[i].Header.Address != address {
+			continue
+		}
+
+		for _, p := range entities[i].StaticProperties {
+			if p.NameValue != "name" || p.Header.DataType != fox.FString {
+				continue
+			}
+
+			if sa, ok := p.Value.(*containers.FoxStaticArray); ok && len(sa.Data) > 0 {
+				if s, ok := sa.Data[0].(*fox.String); ok && s.Value == old {
+					s.Value = name
+					s.Unresolved = false
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package fox2
+
+import (
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readXMLFile(t *testing.T, path string) *Fox2 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	f := &Fox2{}
+	if err = f.FromXML(strings.NewReader(string(data))); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	return f
+}
+
+func bySymbol(name string) func(e *Entity) bool {
+	return func(e *Entity) bool { return e.Symbol() == name }
+}
+
+func dataListKeys(t *testing.T, f *Fox2) []string {
+	t.Helper()
+	var res []string
+	for i := range f.Entities {
+		if p := f.Entities[i].dataList(); p != nil {
+			for _, entry := range p.Value.(*containers.FoxStringMap).Data {
+				res = append(res, entry.KeyString)
+			}
+			if int(p.Header.ValueCount) != len(res) {
+				t.Fatalf("bad dataList count %d, want %d", p.Header.ValueCount, len(res))
+			}
+		}
+	}
+
+	return res
+}
+
+// renameKey renames dataList key and entity named after it
+func renameKey(t *testing.T, f *Fox2, old, name string) {
+	t.Helper()
+	for i := range f.Entities {
+		if p := f.Entities[i].dataList(); p != nil {
+			sm := p.Value.(*containers.FoxStringMap)
+			for j := range sm.Data {
+				if sm.Data[j].KeyString == old {
+					sm.Data[j].KeyString = name
+					renameEntity(f.Entities, sm.Data[j].Value.(*fox.EntityPtr).Value, old, name)
+					return
+				}
+			}
+		}
+	}
+
+	t.Fatalf("no key %s", old)
+}
+
+func checkGraph(t *testing.T, f *Fox2) {
+	t.Helper()
+	g := NewGraph(f)
+	if d := g.Dangling(); len(d) > 0 {
+		t.Fatalf("dangling references %+v", d)
+	}
+
+	if d := g.Duplicates(); len(d) > 0 {
+		t.Fatalf("duplicate addresses %X", d)
+	}
+
+	if u := g.Unreachable(); len(u) > 0 {
+		t.Fatalf("unreachable entities %+v", u)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	f := readXMLFile(t, "testdata/game/o50050_sequence.fox2.xml")
+
+	res, err := Extract(f, bySymbol("player_locator_not_start"))
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	var classes []string
+	for _, e := range res.Entities {
+		classes = append(classes, e.ClassNameString)
+	}
+
+	want := []string{DataSetClass, "GameObjectLocator", "TransformEntity", "TppPlayer2LocatorParameter"}
+	if !slices.Equal(classes, want) {
+		t.Fatalf("bad entities %v, want %v", classes, want)
+	}
+
+	if keys := dataListKeys(t, res); !slices.Equal(keys, []string{"player_locator_not_start"}) {
+		t.Fatalf("bad dataList %v", keys)
+	}
+
+	checkGraph(t, res)
+
+	if len(f.Entities) != 6 || len(dataListKeys(t, f)) != 3 {
+		t.Fatalf("source modified")
+	}
+
+	if _, err = Extract(f, bySymbol("missing")); err == nil {
+		t.Fatalf("want error for empty selection")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	target := readXMLFile(t, "testdata/game/title_sequence.fox2.xml")
+	source := readXMLFile(t, "testdata/game/o50050_sequence.fox2.xml")
+
+	res, err := Merge(target, source, MergeOptions{Select: bySymbol("player_locator_not_start")})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if len(res.Entities) != len(target.Entities)+3 {
+		t.Fatalf("bad entity count %d", len(res.Entities))
+	}
+
+	keys := dataListKeys(t, res)
+	if !slices.Equal(keys, []string{"init_mission_data", "TexturePackLoadConditioner0000", "player_locator_not_start"}) {
+		t.Fatalf("bad dataList %v", keys)
+	}
+
+	checkGraph(t, res)
+
+	literals := map[string]bool{}
+	for _, l := range res.StringLookupLiterals {
+		literals[l.Literal] = true
+	}
+	for _, s := range []string{"player_locator_not_start", "TppPlayer2"} {
+		if !literals[s] {
+			t.Fatalf("no literal %q", s)
+		}
+	}
+
+	// the same entities again under another key, addresses and ids conflict
+	for i := range source.Entities {
+		if p := source.Entities[i].dataList(); p != nil {
+			for j, entry := range p.Value.(*containers.FoxStringMap).Data {
+				if entry.KeyString == "player_locator_not_start" {
+					p.Value.(*containers.FoxStringMap).Data[j].KeyString = "player_locator_copy"
+				}
+			}
+		}
+	}
+
+	twice, err := Merge(res, source, MergeOptions{Select: bySymbol("player_locator_not_start")})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	checkGraph(t, twice)
+
+	ids := map[uint64]bool{}
+	for _, e := range twice.Entities {
+		if e.Header.ID != 0 && ids[e.Header.ID] {
+			t.Fatalf("duplicate id 0x%X", e.Header.ID)
+		}
+		ids[e.Header.ID] = true
+	}
+
+	copied := twice.Entities[len(twice.Entities)-3]
+	if copied.Header.Address == 0x51262F0 {
+		t.Fatalf("conflicting address is not remapped")
+	}
+
+	for _, p := range copied.StaticProperties {
+		if p.NameValue != "dataSet" {
+			continue
+		}
+		if h := p.Value.(*containers.FoxStaticArray).Data[0].(*fox.EntityHandle); h.Value != target.Entities[0].Header.Address {
+			t.Fatalf("dataSet 0x%X, want target DataSet 0x%X", h.Value, target.Entities[0].Header.Address)
+		}
+	}
+
+	// both files have TexturePackLoadConditioner0000
+	parts := readXMLFile(t, "testdata/game/player2_add_parts_prqst_x1.fox2.xml")
+	collision, err := Merge(target, parts, MergeOptions{})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	checkGraph(t, collision)
+
+	keys = dataListKeys(t, collision)
+	want := []string{"init_mission_data", "TexturePackLoadConditioner0000", "TppPlayer2AdditionalPartsBlockData0000",
+		"TexturePackLoadConditioner0001"}
+	if !slices.Equal(keys[:2], want[:2]) || !slices.Equal(keys[len(keys)-2:], want[2:]) {
+		t.Fatalf("bad dataList %v", keys)
+	}
+
+	renamed := 0
+	for i := range collision.Entities {
+		switch collision.Entities[i].Symbol() {
+		case "TexturePackLoadConditioner0000", "TexturePackLoadConditioner0001":
+			renamed++
+		}
+	}
+	if renamed != 2 {
+		t.Fatalf("want both conditioners named after their keys, have %d", renamed)
+	}
+
+	if parts.Entities[2].Symbol() != "TexturePackLoadConditioner0000" {
+		t.Fatalf("source modified")
+	}
+
+	// short number keeps its width
+	short, err := target.clone()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	shortParts, err := parts.clone()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, f := range []*Fox2{short, shortParts} {
+		renameKey(t, f, "TexturePackLoadConditioner0000", "Locator12")
+	}
+
+	if collision, err = Merge(short, shortParts, MergeOptions{}); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if keys = dataListKeys(t, collision); keys[len(keys)-1] != "Locator13" {
+		t.Fatalf("bad dataList %v", keys)
+	}
+
+	if s := collision.Entities[len(collision.Entities)-1].Symbol(); s != "Locator13" {
+		t.Fatalf("entity is not renamed, have %q", s)
+	}
+
+	renumbered, err := Merge(target, source, MergeOptions{Select: bySymbol("player_locator_not_start"), Renumber: true})
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	checkGraph(t, renumbered)
+	if renumbered.Entities[0].Header.Address != FirstAddress {
+		t.Fatalf("not renumbered")
+	}
+}
+
+func TestUniqueKey(t *testing.T) {
+	used := map[string]bool{"Locator0001": true}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "TexturePackLoadConditioner0000", want: "TexturePackLoadConditioner0001"},
+		{key: "Locator12", want: "Locator13"},
+		{key: "Locator9", want: "Locator10"},
+		{key: "Locator", want: "Locator0002"},
+	}
+
+	for _, tt := range tests {
+		if have := uniqueKey(tt.key, used); have != tt.want {
+			t.Errorf("%s: want %s, have %s", tt.key, tt.want, have)
+		}
+	}
+}
@@ -6,12 +6,11 @@ import (
 	"fmt"
 	"github.com/unknown321/datfpk/fox2/containers"
 	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fox2/schema"
 	"github.com/unknown321/datfpk/util"
 	"io"
 	"log/slog"
 	"strconv"
-
-	"github.com/unknown321/hashing"
 )
 
 type PropertyHeader struct {
@@ -44,6 +43,54 @@ type Property struct {
 	NameValue string
 }
 
+// NewProperty returns property of definition with default values
+func NewProperty(def schema.Property) (Property, error) {
+	var err error
+	p := Property{
+		Header: PropertyHeader{
+			DataType:      def.Type,
+			ContainerType: def.Container,
+		},
+		NameValue: def.Name,
+	}
+
+	if def.Container == containers.StaticArray {
+		p.Header.ValueCount = def.ArraySize
+	}
+
+	if p.Value, err = CreateTypedContainer(def.Type, def.Container, int(p.Header.ValueCount)); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// each calls fn for every value of property, key is stringMap key or index in container of several values
+func (p *Property) each(fn func(key string, v fox.DataType)) {
+	var data []fox.DataType
+	switch c := p.Value.(type) {
+	case *containers.FoxStringMap:
+		for _, v := range c.Data {
+			fn(v.KeyString, v.Value)
+		}
+		return
+	case *containers.FoxStaticArray:
+		data = c.Data
+	case *containers.FoxDynamicArray:
+		data = c.Data
+	case *containers.FoxList:
+		data = c.Data
+	}
+
+	for i, v := range data {
+		key := ""
+		if len(data) > 1 {
+			key = strconv.Itoa(i)
+		}
+		fn(key, v)
+	}
+}
+
 func (p *Property) Read(reader io.ReadSeeker) error {
 	var err error
 	//o, _ := reader.Seek(0, io.SeekCurrent)
@@ -65,7 +112,7 @@ func (p *Property) Read(reader io.ReadSeeker) error {
 	}
 
 	if p.Value == nil {
-		panic("property value is nil")
+		return fmt.Errorf("property value is nil")
 	}
 
 	if _, err = util.AlignRead(reader, 16); err != nil {
@@ -99,7 +146,7 @@ func (p *Property) Write(writer io.WriteSeeker) error {
 	if _, err = writer.Seek(headerOffset, io.SeekStart); err != nil {
 		return err
 	}
-	p.Header.NameHash = hashing.StrCode64([]byte(p.NameValue))
+	p.Header.NameHash = fox.NameHash(p.NameValue)
 	p.Header.Offset = PropertyHeaderSize
 	p.Header.Size = uint16(dataEnd - headerOffset)
 	//slog.Info("property header size", "s", p.Header.Size)
@@ -146,6 +193,21 @@ func (p *Property) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 }
 
 func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	line, _ := d.InputPos()
+	if err := p.decodeXML(d, start); err != nil {
+		pe := &PropertyError{Line: line, Err: err}
+		for _, a := range start.Attr {
+			if a.Name.Local == "name" {
+				pe.Property = a.Value
+			}
+		}
+		return pe
+	}
+
+	return nil
+}
+
+func (p *Property) decodeXML(d *xml.Decoder, start xml.StartElement) error {
 	var err error
 
 	for _, a := range start.Attr {
@@ -207,7 +269,7 @@ func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for {
 		t, err := d.Token()
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		switch tt := t.(type) {
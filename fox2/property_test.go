@@ -3,6 +3,7 @@ package fox2
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/unknown321/datfpk/fox2/containers"
 	"github.com/unknown321/datfpk/fox2/datatypes/fox"
@@ -132,3 +133,63 @@ func TestProperty_MarshalXML(t *testing.T) {
 		t.Fatalf("have \n%s\n want \n%s\n", b, want)
 	}
 }
+
+func TestProperty_UnmarshalXMLErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		property string
+		want     error
+	}{
+		{
+			name:     "unknown data type",
+			property: `<property name="prop" type="PropertyInfo" container="StaticArray" arraySize="1"></property>`,
+			want:     fox.ErrNotImplemented,
+		},
+		{
+			name:     "unknown container",
+			property: `<property name="prop" type="Bool" container="Tree" arraySize="1"></property>`,
+		},
+		{
+			name:     "empty container",
+			property: `<property name="prop" type="Bool" container="List"><containerEntry>true</containerEntry></property>`,
+		},
+		{
+			name:     "negative size",
+			property: `<property name="prop" type="Bool" container="StaticArray" arraySize="-1"></property>`,
+		},
+		{
+			name:     "unclosed",
+			property: `<property name="prop" type="Bool" container="StaticArray" arraySize="1"><containerEntry>true</containerEntry>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := fmt.Sprintf(`<fox formatVersion="2" fileVersion="0">
+  <entities>
+    <entity class="DataSet" classVersion="0" classID="0xE8" addr="0x2D752C0" id="0x50EE0">
+      <staticProperties>
+        %s
+      </staticProperties>
+    </entity>
+  </entities>
+</fox>`, tt.property)
+
+			f := Fox2{}
+			err := f.FromXML(bytes.NewReader([]byte(in)))
+
+			var pe *PropertyError
+			if !errors.As(err, &pe) {
+				t.Fatalf("want *PropertyError, have %v", err)
+			}
+
+			if pe.Entity != 0x2D752C0 || pe.Property != "prop" || pe.Line != 5 {
+				t.Errorf("unexpected error context %+v", pe)
+			}
+
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("want %v, have %v", tt.want, err)
+			}
+		})
+	}
+}
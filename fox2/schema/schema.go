@@ -0,0 +1,254 @@
+// Package schema holds fox2 class definitions in FoxTool style: static properties of every class version
+// with data type, container type and array size. Entities are validated against them.
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+)
+
+// Property is a static property definition.
+// ArraySize is checked only for StaticArray, other containers are sized by their data.
+type Property struct {
+	Name      string
+	Type      fox.FDataType
+	Container containers.FoxContainerType
+	ArraySize int16
+}
+
+func (p Property) String() string {
+	s := fmt.Sprintf("%s %s", fox.DataTypeToString(p.Type), p.Container.String())
+	if p.Container == containers.StaticArray {
+		s += fmt.Sprintf("[%d]", p.ArraySize)
+	}
+
+	return s
+}
+
+type Class struct {
+	Name       string
+	Version    int16
+	Properties []Property
+}
+
+// Problem is a kind of Mismatch
+type Problem string
+
+const (
+	ProblemMissing   Problem = "missing"
+	ProblemUnknown   Problem = "unknown"
+	ProblemType      Problem = "type"
+	ProblemContainer Problem = "container"
+	ProblemArraySize Problem = "arraySize"
+	ProblemVersion   Problem = "classVersion" // class is known, but not its version
+)
+
+// Mismatch is a property which does not match class definition, Want and Have are empty if not applicable.
+type Mismatch struct {
+	Property string
+	Problem  Problem
+	Want     string
+	Have     string
+}
+
+func (m Mismatch) String() string {
+	switch m.Problem {
+	case ProblemMissing:
+		return fmt.Sprintf("property %s is missing, want %s", m.Property, m.Want)
+	case ProblemUnknown:
+		return fmt.Sprintf("property %s is not in class, have %s", m.Property, m.Have)
+	case ProblemVersion:
+		return fmt.Sprintf("unknown class version %s, want one of %s", m.Have, m.Want)
+	}
+
+	return fmt.Sprintf("property %s: %s, want %s, have %s", m.Property, m.Problem, m.Want, m.Have)
+}
+
+// Check compares properties with class definition, property order is not checked.
+func (c *Class) Check(props []Property) []Mismatch {
+	var res []Mismatch
+	have := make(map[string]Property, len(props))
+	for _, p := range props {
+		have[p.Name] = p
+	}
+
+	for _, want := range c.Properties {
+		p, ok := have[want.Name]
+		if !ok {
+			res = append(res, Mismatch{Property: want.Name, Problem: ProblemMissing, Want: want.String()})
+			continue
+		}
+
+		switch {
+		case p.Type != want.Type:
+			res = append(res, Mismatch{Property: p.Name, Problem: ProblemType, Want: fox.DataTypeToString(want.Type), Have: fox.DataTypeToString(p.Type)})
+		case p.Container != want.Container:
+			res = append(res, Mismatch{Property: p.Name, Problem: ProblemContainer, Want: want.Container.String(), Have: p.Container.String()})
+		case want.Container == containers.StaticArray && p.ArraySize != want.ArraySize:
+			res = append(res, Mismatch{
+				Property: p.Name,
+				Problem:  ProblemArraySize,
+				Want:     strconv.Itoa(int(want.ArraySize)),
+				Have:     strconv.Itoa(int(p.ArraySize)),
+			})
+		}
+	}
+
+	for _, p := range props {
+		if c.property(p.Name) == nil {
+			res = append(res, Mismatch{Property: p.Name, Problem: ProblemUnknown, Have: p.String()})
+		}
+	}
+
+	return res
+}
+
+func (c *Class) property(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+
+	return nil
+}
+
+// Registry holds class definitions by name and version
+type Registry struct {
+	classes map[string]map[int16]*Class
+}
+
+func New() *Registry {
+	return &Registry{classes: make(map[string]map[int16]*Class)}
+}
+
+//go:embed classes.xml
+var classesXML []byte
+
+// Default returns registry with embedded class definitions
+func Default() (*Registry, error) {
+	r := New()
+	if err := r.Read(bytes.NewReader(classesXML)); err != nil {
+		return nil, fmt.Errorf("embedded classes: %w", err)
+	}
+
+	return r, nil
+}
+
+// Add adds class, class with the same name and version is replaced
+func (r *Registry) Add(c *Class) {
+	versions := r.classes[c.Name]
+	if versions == nil {
+		versions = make(map[int16]*Class)
+		r.classes[c.Name] = versions
+	}
+
+	versions[c.Version] = c
+}
+
+// Class returns definition of class version
+func (r *Registry) Class(name string, version int16) (*Class, bool) {
+	c, ok := r.classes[name][version]
+	return c, ok
+}
+
+// Versions returns sorted versions of class, nil if class is unknown
+func (r *Registry) Versions(name string) []int16 {
+	var res []int16
+	for v := range r.classes[name] {
+		res = append(res, v)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res
+}
+
+// Len returns number of class versions
+func (r *Registry) Len() int {
+	n := 0
+	for _, versions := range r.classes {
+		n += len(versions)
+	}
+
+	return n
+}
+
+type classesXml struct {
+	XMLName xml.Name   `xml:"classes"`
+	Classes []classXml `xml:"class"`
+}
+
+type classXml struct {
+	Name       string        `xml:"name,attr"`
+	Version    int16         `xml:"version,attr"`
+	Properties []propertyXml `xml:"property"`
+}
+
+type propertyXml struct {
+	Name      string `xml:"name,attr"`
+	Type      string `xml:"type,attr"`
+	Container string `xml:"container,attr"`
+	ArraySize int16  `xml:"arraySize,attr"`
+}
+
+// Read adds classes from xml, classes already present are replaced, so later files override earlier ones.
+func (r *Registry) Read(reader io.Reader) error {
+	var err error
+	cx := classesXml{}
+	if err = xml.NewDecoder(reader).Decode(&cx); err != nil {
+		return err
+	}
+
+	for _, c := range cx.Classes {
+		if c.Name == "" {
+			return fmt.Errorf("class without name")
+		}
+
+		class := &Class{Name: c.Name, Version: c.Version}
+		for _, p := range c.Properties {
+			prop := Property{Name: p.Name, ArraySize: p.ArraySize}
+			if prop.Type, err = fox.DataTypeFromString(p.Type); err != nil {
+				return fmt.Errorf("class %s, property %s: %w", c.Name, p.Name, err)
+			}
+
+			if prop.Container, err = containers.ContainerTypeFromString(p.Container); err != nil {
+				return fmt.Errorf("class %s, property %s: %w", c.Name, p.Name, err)
+			}
+
+			if prop.Container == containers.StaticArray && prop.ArraySize == 0 {
+				prop.ArraySize = 1
+			}
+
+			class.Properties = append(class.Properties, prop)
+		}
+
+		r.Add(class)
+	}
+
+	return nil
+}
+
+// Load reads classes from file
+func (r *Registry) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = r.Read(f); err != nil {
+		return fmt.Errorf("schema %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unknown321/datfpk/fox2/containers"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+)
+
+func TestRegistry_Read(t *testing.T) {
+	r, err := Default()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if _, ok := r.Class("DataSet", 0); !ok {
+		t.Fatalf("no embedded DataSet")
+	}
+
+	override := `<classes>
+  <class name="DataSet" version="0">
+    <property name="name" type="String" container="StaticArray"/>
+  </class>
+  <class name="DataSet" version="1"></class>
+</classes>`
+	if err = r.Read(strings.NewReader(override)); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	c, _ := r.Class("DataSet", 0)
+	if len(c.Properties) != 1 || c.Properties[0].ArraySize != 1 {
+		t.Errorf("want overridden DataSet with 1 property of size 1, have %+v", c.Properties)
+	}
+
+	if v := r.Versions("DataSet"); len(v) != 2 || v[0] != 0 || v[1] != 1 {
+		t.Errorf("want versions 0, 1, have %v", v)
+	}
+
+	bad := `<classes><class name="A"><property name="a" type="NoSuchType" container="StaticArray"/></class></classes>`
+	if err = r.Read(strings.NewReader(bad)); err == nil {
+		t.Errorf("want error on unknown type")
+	}
+}
+
+func TestClass_Check(t *testing.T) {
+	c := &Class{Name: "A", Properties: []Property{
+		{Name: "name", Type: fox.FString, Container: containers.StaticArray, ArraySize: 1},
+		{Name: "list", Type: fox.FEntityPtr, Container: containers.StringMap},
+		{Name: "flags", Type: fox.FUInt32, Container: containers.StaticArray, ArraySize: 1},
+		{Name: "vector", Type: fox.FVector3, Container: containers.StaticArray, ArraySize: 4},
+	}}
+
+	tests := []struct {
+		name  string
+		props []Property
+		want  []Mismatch
+	}{
+		{
+			name: "valid, any order, any map size",
+			props: []Property{
+				{Name: "vector", Type: fox.FVector3, Container: containers.StaticArray, ArraySize: 4},
+				{Name: "list", Type: fox.FEntityPtr, Container: containers.StringMap, ArraySize: 3},
+				{Name: "name", Type: fox.FString, Container: containers.StaticArray, ArraySize: 1},
+				{Name: "flags", Type: fox.FUInt32, Container: containers.StaticArray, ArraySize: 1},
+			},
+		},
+		{
+			name: "mismatches",
+			props: []Property{
+				{Name: "name", Type: fox.FPath, Container: containers.StaticArray, ArraySize: 1},
+				{Name: "list", Type: fox.FEntityPtr, Container: containers.List, ArraySize: 3},
+				{Name: "vector", Type: fox.FVector3, Container: containers.StaticArray, ArraySize: 3},
+				{Name: "extra", Type: fox.FBool, Container: containers.StaticArray, ArraySize: 1},
+			},
+			want: []Mismatch{
+				{Property: "name", Problem: ProblemType, Want: "String", Have: "Path"},
+				{Property: "list", Problem: ProblemContainer, Want: "StringMap", Have: "List"},
+				{Property: "flags", Problem: ProblemMissing, Want: "UInt32 StaticArray[1]"},
+				{Property: "vector", Problem: ProblemArraySize, Want: "4", Have: "3"},
+				{Property: "extra", Problem: ProblemUnknown, Have: "Bool StaticArray[1]"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have := c.Check(tt.props)
+			if len(have) != len(tt.want) {
+				t.Fatalf("want %v, have %v", tt.want, have)
+			}
+
+			for i := range tt.want {
+				if have[i] != tt.want[i] {
+					t.Errorf("want %v, have %v", tt.want[i], have[i])
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package fox2
 
 import (
 	"encoding/binary"
+	"github.com/unknown321/datfpk/util"
 	"io"
 
 	"github.com/unknown321/hashing"
@@ -28,8 +29,12 @@ func (s *StringLookupLiteral) Read(reader io.ReadSeeker) bool {
 		return false
 	}
 
+	if err = util.CheckSize(reader, int64(s.Length)); err != nil {
+		return false
+	}
+
 	l := make([]byte, s.Length)
-	if _, err = reader.Read(l); err != nil {
+	if _, err = io.ReadFull(reader, l); err != nil {
 		return false
 	}
 
@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/util"
 	"io"
 	"path/filepath"
 	"strings"
@@ -52,17 +54,20 @@ func (e *Entry) UnmarshalJSON(i []byte) error {
 const EntrySize = 4*4 + 4*4 + 16 // dataInfo, filePath, md5
 
 func (e *Entry) Read(reader io.ReadSeeker) error {
-	//o, _ := reader.Seek(0, io.SeekCurrent)
+	o, _ := reader.Seek(0, io.SeekCurrent)
 	//slog.Info("entry", "offset", o)
 	skip := uint32(0)
-	binary.Read(reader, binary.LittleEndian, &e.DataOffset)
-	binary.Read(reader, binary.LittleEndian, skip)
-	binary.Read(reader, binary.LittleEndian, &e.DataSize)
-	binary.Read(reader, binary.LittleEndian, skip)
+	for _, v := range []any{&e.DataOffset, &skip, &e.DataSize, &skip} {
+		if err := binary.Read(reader, binary.LittleEndian, v); err != nil {
+			return &CorruptEntryError{Offset: o, Reason: "cannot read header", Err: err}
+		}
+	}
 	if err := e.FilePath.Read(reader); err != nil {
-		return fmt.Errorf("%w", err)
+		return &CorruptEntryError{Offset: o, Reason: "cannot read file path", Err: err}
+	}
+	if _, err := io.ReadFull(reader, e.PathMD5[:]); err != nil {
+		return &CorruptEntryError{Offset: o, Reason: "cannot read path md5", Err: err}
 	}
-	reader.Read(e.PathMD5[:])
 
 	//slog.Info("entry",
 	//	"md5", fmt.Sprintf("%x", e.PathMD5),
@@ -93,10 +98,14 @@ func (e *Entry) ReadData(reader io.ReadSeeker) error {
 		return fmt.Errorf("%w", err)
 	}
 
+	if err = util.CheckSize(reader, int64(e.DataSize)); err != nil {
+		return &CorruptEntryError{Offset: int64(e.DataOffset), Reason: "bad data size", Err: err}
+	}
+
 	b := make([]byte, e.DataSize)
-	_, err = reader.Read(b)
+	_, err = io.ReadFull(reader, b)
 	if err != nil {
-		return fmt.Errorf("%w", err)
+		return &CorruptEntryError{Offset: int64(e.DataOffset), Reason: "cannot read data", Err: err}
 	}
 
 	if b[0] == 0x1B || b[0] == 0x1C {
@@ -122,6 +131,15 @@ const blockSize = int(unsafe.Sizeof(uint64(0)))
 
 func Decrypt(data []byte, name string) ([]byte, error) {
 	fName := filepath.Base(strings.ToLower(name))
+	// hashed with terminating zero
+	if len(fName)+1 > dictionary.MaxHashLength {
+		return data, fmt.Errorf("name too long: %d", len(fName))
+	}
+
+	if len(data) < 2 {
+		return data, fmt.Errorf("too few bytes: %d", len(data))
+	}
+
 	h := hashing.HashFileNameLegacy([]byte(fName), false)
 	key := make([]byte, blockSize)
 	binary.LittleEndian.PutUint64(key, ^h)
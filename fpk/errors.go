@@ -0,0 +1,38 @@
+package fpk
+
+import (
+	"fmt"
+
+	"github.com/unknown321/datfpk/util"
+)
+
+// ErrBadMagic is returned for data which is neither fpk nor fpkd
+var ErrBadMagic = fmt.Errorf("fpk: %w", util.ErrBadMagic)
+
+// EntryNotFoundError is returned when archive has no entry with requested path.
+type EntryNotFoundError struct {
+	Path string
+}
+
+func (e *EntryNotFoundError) Error() string {
+	return fmt.Sprintf("fpk entry not found, path: %s", e.Path)
+}
+
+// CorruptEntryError is returned when entry header at Offset or entry data at data offset cannot be read.
+type CorruptEntryError struct {
+	Offset int64
+	Reason string
+	Err    error
+}
+
+func (e *CorruptEntryError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("corrupt fpk entry at offset %d: %s", e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("corrupt fpk entry at offset %d: %s: %s", e.Offset, e.Reason, e.Err.Error())
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/unknown321/hashing"
 )
 
 var MagicFpk = [10]byte{0x66, 0x6f, 0x78, 0x66, 0x70, 0x6b, 0x00, 0x77, 0x69, 0x6e}  // "foxfpk\000win"
@@ -21,6 +23,8 @@ type Fpk struct {
 	Entries    []Entry
 	References []Reference
 	FilePath   string `json:"-"`
+	// OnWrite is called after each entry is written by Write.
+	OnWrite func(e *Entry) `json:"-"`
 
 	handle io.ReadSeeker
 }
@@ -39,7 +43,7 @@ func (f *Fpk) MarshalJSON() ([]byte, error) {
 	case MagicFpkd:
 		t = FpkdID
 	default:
-		return nil, fmt.Errorf("wrong magic % x (%s)", f.Header.Magic, f.Header.Magic)
+		return nil, fmt.Errorf("%w: % x (%s)", ErrBadMagic, f.Header.Magic, f.Header.Magic)
 	}
 
 	//if f.Header.Magic == MagicFpk {
@@ -89,8 +93,7 @@ func (f *Fpk) ReadFrom(path string, printLog bool) error {
 	}
 	//defer file.Close()
 
-	err = f.Read(file, printLog)
-	return nil
+	return f.Read(file, printLog)
 }
 
 func (f *Fpk) Read(reader io.ReadSeeker, printLog bool) error {
@@ -104,11 +107,23 @@ func (f *Fpk) Read(reader io.ReadSeeker, printLog bool) error {
 	//o, _ := reader.Seek(0, io.SeekCurrent)
 	//slog.Info("entries", "off", o)
 
+	if err = util.CheckSize(reader, int64(f.Header.EntryCount)*EntrySize); err != nil {
+		return fmt.Errorf("entries: %w", err)
+	}
+
+	// entries do not share data, so total data size cannot exceed file size
+	fileSize := util.Remaining(reader) + HeaderSize
+	dataSize := int64(0)
+
 	for i := 0; i < int(f.Header.EntryCount); i++ {
 		e := Entry{}
 		if err = e.Read(reader); err != nil {
 			return fmt.Errorf("entry %d read: %w", i, err)
 		}
+
+		if dataSize += int64(e.DataSize); dataSize > fileSize {
+			return fmt.Errorf("entry %d read: %w: total data size %d, file size %d", i, util.ErrTooLarge, dataSize, fileSize)
+		}
 		f.Entries = append(f.Entries, e)
 		if printLog {
 			slog.Info("entry", "filePath", e.FilePath.Data)
@@ -189,6 +204,10 @@ func (f *Fpk) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 		if printLog {
 			slog.Info("entry", "path", f.Entries[i].FilePath.Data)
 		}
+
+		if f.OnWrite != nil {
+			f.OnWrite(&f.Entries[i])
+		}
 	}
 
 	fSize, _ := file.Seek(0, io.SeekCurrent)
@@ -244,6 +263,9 @@ func (f *Fpk) Extract(path string, outDir string) error {
 		workdir = filepath.Dir(f.FilePath)
 		ext := filepath.Ext(f.FilePath)
 		datDirName = strings.TrimSuffix(filepath.Base(f.FilePath), ext) + strings.ReplaceAll(ext, ".", "_")
+		if ext == "" {
+			datDirName += "_" + f.typeID()
+		}
 	}
 	outP := filepath.Dir(path)
 	outDir = filepath.Join(workdir, datDirName, outP)
@@ -264,12 +286,12 @@ func (f *Fpk) Extract(path string, outDir string) error {
 	return nil
 }
 func (f *Fpk) Close() {
-	if f.handle != nil {
-		f.handle.(*os.File).Close()
+	if c, ok := f.handle.(io.Closer); ok {
+		_ = c.Close()
 	}
 }
 
-func (f *Fpk) ExtractTo(path string, outFile io.WriteSeeker) error {
+func (f *Fpk) ExtractTo(path string, outFile io.Writer) error {
 	var e *Entry = nil
 	for _, v := range f.Entries {
 		if v.FilePath.Data == path {
@@ -279,7 +301,7 @@ func (f *Fpk) ExtractTo(path string, outFile io.WriteSeeker) error {
 	}
 
 	if e == nil {
-		return fmt.Errorf("entry not found, path %s", path)
+		return &EntryNotFoundError{Path: path}
 	}
 
 	if err := e.ReadData(f.handle); err != nil {
@@ -293,6 +315,30 @@ func (f *Fpk) ExtractTo(path string, outFile io.WriteSeeker) error {
 	return nil
 }
 
+// Subset returns fpk with entries selected by filter, hash of entry is calculated from its path.
+// References are kept.
+func (f *Fpk) Subset(filter *util.Filter) *Fpk {
+	res := *f
+	res.Entries = nil
+	for _, e := range f.Entries {
+		if filter.Match(e.FilePath.Data, hashing.HashFileNameWithExtension(e.FilePath.Data)) {
+			res.Entries = append(res.Entries, e)
+		}
+	}
+
+	res.Header.EntryCount = uint32(len(res.Entries))
+
+	return &res
+}
+
+func (f *Fpk) typeID() string {
+	if f.Header.IsFpkd() {
+		return FpkdID
+	}
+
+	return FpkID
+}
+
 func (f *Fpk) SetType(isFpkd bool) {
 	f.Header.SetType(isFpkd)
 }
@@ -3,9 +3,13 @@ package fpk
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/r3labs/diff/v3"
+	"github.com/unknown321/datfpk/qar"
 	"github.com/unknown321/datfpk/util"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -368,3 +372,124 @@ func TestFpk_Write(t *testing.T) {
 	//rr.Write(b.Bytes())
 	//rr.Close()
 }
+
+func TestFpk_Subset(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "include",
+			include: []string{"*.fox2"},
+			exclude: []string{"/Assets/tpp/level/**"},
+			want: []string{
+				"/Assets/tpp/ui/GraphAsset/entry_datas/texture_logo.fox2",
+				"/Assets/tpp/ui/GraphAsset/entry_datas/lang_popup.fox2",
+				"/Assets/tpp/ui/GraphAsset/entry_datas/eula.fox2",
+			},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"*.fox2"},
+			want: []string{
+				"/Assets/tpp/script/mission/mission_main.lua",
+				"/Assets/tpp/level/mission2/init/s00005_sequence.lua",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Fpk{}
+			if err := f.ReadFrom(datadir+"title.fpkd", false); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			defer f.Close()
+
+			filter, err := util.NewFilter(tt.include, tt.exclude, nil)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			subset := f.Subset(filter)
+			var have []string
+			for _, e := range subset.Entries {
+				have = append(have, e.FilePath.Data)
+			}
+
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v, want %v", have, tt.want)
+			}
+
+			if subset.Header.EntryCount != uint32(len(tt.want)) {
+				t.Errorf("entry count: have %d", subset.Header.EntryCount)
+			}
+
+			if len(f.Entries) != 6 {
+				t.Errorf("original fpk modified")
+			}
+		})
+	}
+}
+
+func TestFpk_ReadErrors(t *testing.T) {
+	data, err := os.ReadFile(datadir + "title.fpkd")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	badMagic := bytes.Clone(data)
+	badMagic[0] ^= 0xFF
+
+	f := Fpk{}
+	if err = f.Read(bytes.NewReader(badMagic), false); !errors.Is(err, ErrBadMagic) || !errors.Is(err, util.ErrBadMagic) {
+		t.Errorf("want ErrBadMagic, have %v", err)
+	}
+
+	if errors.Is(err, qar.ErrBadMagic) {
+		t.Errorf("fpk error matches qar error: %v", err)
+	}
+
+	var corrupt *CorruptEntryError
+	f = Fpk{}
+	if err = f.Read(bytes.NewReader(data[:len(data)/2]), false); !errors.As(err, &corrupt) {
+		t.Errorf("want *CorruptEntryError, have %v", err)
+	}
+
+	f = Fpk{}
+	if err = f.Read(bytes.NewReader(data), false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	var notFound *EntryNotFoundError
+	if err = f.ExtractTo("/missing.lua", new(bytes.Buffer)); !errors.As(err, &notFound) || notFound.Path != "/missing.lua" {
+		t.Errorf("want *EntryNotFoundError, have %v", err)
+	}
+}
+
+func FuzzFpk_Read(f *testing.F) {
+	files, err := filepath.Glob(datadir + "*.fpk*")
+	if err != nil {
+		f.Fatalf("%s", err.Error())
+	}
+
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatalf("%s", err.Error())
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ff := Fpk{}
+		if err := ff.Read(bytes.NewReader(data), false); err != nil {
+			return
+		}
+
+		for _, e := range ff.Entries {
+			_ = ff.ExtractTo(e.FilePath.Data, io.Discard)
+		}
+	})
+}
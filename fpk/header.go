@@ -33,7 +33,7 @@ func (h *Header) Read(reader io.Reader) error {
 	}
 
 	if !h.IsValid() {
-		return fmt.Errorf("unknown fpk(d) magic: [% x]", h.Magic)
+		return fmt.Errorf("%w: [% x]", ErrBadMagic, h.Magic)
 	}
 
 	return nil
@@ -3,6 +3,7 @@ package fpk
 import (
 	"encoding/binary"
 	"fmt"
+	"github.com/unknown321/datfpk/util"
 	"io"
 )
 
@@ -43,9 +44,13 @@ func (s *String) Read(reader io.ReadSeeker) error {
 		return fmt.Errorf("seek: %w", err)
 	}
 
+	if err = util.CheckSize(reader, int64(s.Header.Length)); err != nil {
+		return fmt.Errorf("fpkstring: %w", err)
+	}
+
 	d := make([]byte, s.Header.Length)
 
-	if _, err = reader.Read(d); err != nil {
+	if _, err = io.ReadFull(reader, d); err != nil {
 		return fmt.Errorf("read fpkstring: %w", err)
 	}
 
@@ -27,17 +27,17 @@ func (e *Entry) Read(seeker io.ReadSeeker, order binary.ByteOrder) error {
 
 	// TODO is color always le?
 	if err = binary.Read(seeker, binary.LittleEndian, &e.Color); err != nil {
-		return fmt.Errorf("color: %w", err)
+		return &CorruptEntryError{Offset: e.Offset, Reason: "cannot read color", Err: err}
 	}
 
 	for {
 		n, err := seeker.Read(b)
 		if err != nil {
-			return fmt.Errorf("value: %w", err)
+			return &CorruptEntryError{Offset: e.Offset, Reason: "cannot read value", Err: err}
 		}
 
 		if n == 0 {
-			return fmt.Errorf("read: too few bytes")
+			return &CorruptEntryError{Offset: e.Offset, Reason: "too few bytes"}
 		}
 
 		if b[0] == 0 {
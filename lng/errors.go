@@ -0,0 +1,35 @@
+package lng
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unknown321/datfpk/util"
+)
+
+// Header errors of Read
+var (
+	ErrBadMagic           = fmt.Errorf("lng: %w", util.ErrBadMagic)
+	ErrUnsupportedVersion = fmt.Errorf("lng: %w", util.ErrUnsupportedVersion)
+	// ErrBadEndianness is returned when header has neither little nor big endian marker
+	ErrBadEndianness = errors.New("lng: unknown endianness")
+)
+
+// CorruptEntryError is returned when value of entry at Offset cannot be read.
+type CorruptEntryError struct {
+	Offset int64
+	Reason string
+	Err    error
+}
+
+func (e *CorruptEntryError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("corrupt lng entry at offset %d: %s", e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("corrupt lng entry at offset %d: %s: %s", e.Offset, e.Reason, e.Err.Error())
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
@@ -39,6 +39,10 @@ func (h *Header) Read(seeker io.ReadSeeker) error {
 		return fmt.Errorf("magic: %w", err)
 	}
 
+	if h.Magic != Magic {
+		return fmt.Errorf("%w: %x", ErrBadMagic, h.Magic)
+	}
+
 	if _, err = seeker.Seek(8, io.SeekStart); err != nil {
 		return fmt.Errorf("seek to endianness: %w", err)
 	}
@@ -48,7 +52,7 @@ func (h *Header) Read(seeker io.ReadSeeker) error {
 	}
 
 	if h.Endianness != EndiannessLE && h.Endianness != EndiannessBE {
-		return fmt.Errorf("unknown endianness: %x", h.Endianness)
+		return fmt.Errorf("%w: %x", ErrBadEndianness, h.Endianness)
 	}
 
 	var endianness binary.ByteOrder
@@ -67,7 +71,7 @@ func (h *Header) Read(seeker io.ReadSeeker) error {
 	}
 
 	if h.Version != VersionGZ && h.Version != VersionTPP {
-		return fmt.Errorf("header: invalid version (%d)", h.Version)
+		return fmt.Errorf("header: %w (%d)", ErrUnsupportedVersion, h.Version)
 	}
 
 	if _, err = seeker.Seek(12, io.SeekStart); err != nil {
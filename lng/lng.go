@@ -9,6 +9,7 @@ import (
 	"slices"
 
 	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/util"
 	"github.com/unknown321/hashing"
 )
 
@@ -67,6 +68,10 @@ func (l *Lng) Read(seeker io.ReadSeeker, dictionary dictionary.DictStrCode64) er
 		return fmt.Errorf("seek to keys: %w", err)
 	}
 
+	if err = util.CheckSize(seeker, int64(l.Header.EntryCount)*8); err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+
 	for i = 0; i < l.Header.EntryCount; i++ {
 		k := Key{}
 		if err = k.Read(seeker, endianness); err != nil {
@@ -76,14 +81,18 @@ func (l *Lng) Read(seeker io.ReadSeeker, dictionary dictionary.DictStrCode64) er
 		l.Keys = append(l.Keys, k)
 	}
 
-	for i = 0; i < l.Header.EntryCount; i++ {
-		var k uint32
-		for k = 0; k < l.Header.EntryCount; k++ {
-			if l.Entries[i].Offset == int64(l.Keys[k].Offset) {
-				l.Entries[i].LangId = dictionary.Get(l.Keys[k].Key)
-				l.Entries[i].Key = l.Keys[k].Key
-				break
-			}
+	// first key pointing to entry wins
+	keyByOffset := make(map[int64]Key, len(l.Keys))
+	for _, k := range l.Keys {
+		if _, ok := keyByOffset[int64(k.Offset)]; !ok {
+			keyByOffset[int64(k.Offset)] = k
+		}
+	}
+
+	for i := range l.Entries {
+		if k, ok := keyByOffset[l.Entries[i].Offset]; ok {
+			l.Entries[i].LangId = dictionary.Get(k.Key)
+			l.Entries[i].Key = k.Key
 		}
 	}
 
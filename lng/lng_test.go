@@ -3,7 +3,9 @@ package lng
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"testing"
@@ -731,3 +733,80 @@ func TestLng_Write(t *testing.T) {
 		})
 	}
 }
+
+func TestLng_ReadErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/tpp_tutorial.eng.lng2")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	badMagic := bytes.Clone(data)
+	badMagic[0] ^= 0xFF
+
+	badVersion := bytes.Clone(data)
+	badVersion[7] = 9 // big endian
+
+	badEndianness := bytes.Clone(data)
+	badEndianness[8] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    error
+		generic error // util error wrapped by want
+	}{
+		{name: "bad magic", data: badMagic, want: ErrBadMagic, generic: util.ErrBadMagic},
+		{name: "unsupported version", data: badVersion, want: ErrUnsupportedVersion, generic: util.ErrUnsupportedVersion},
+		{name: "bad endianness", data: badEndianness, want: ErrBadEndianness},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Lng{}
+			err := l.Read(bytes.NewReader(tt.data), dictionary.DictStrCode64{})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("want %v, have %v", tt.want, err)
+			}
+
+			if errors.Is(err, util.ErrBadMagic) != (tt.generic == util.ErrBadMagic) {
+				t.Errorf("bad magic: %v", err)
+			}
+
+			if tt.generic != nil && !errors.Is(err, tt.generic) {
+				t.Errorf("want %v, have %v", tt.generic, err)
+			}
+		})
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		var corrupt *CorruptEntryError
+		l := &Lng{}
+		if err := l.Read(bytes.NewReader(data[:HeaderSize+10]), dictionary.DictStrCode64{}); !errors.As(err, &corrupt) {
+			t.Errorf("want *CorruptEntryError, have %v", err)
+		}
+	})
+}
+
+func FuzzLng_Read(f *testing.F) {
+	files, err := filepath.Glob("testdata/*.lng2")
+	if err != nil {
+		f.Fatalf("%s", err.Error())
+	}
+
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatalf("%s", err.Error())
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		l := &Lng{}
+		if err := l.Read(bytes.NewReader(data), dictionary.DictStrCode64{}); err != nil {
+			return
+		}
+
+		_, _ = l.MarshalJSON()
+	})
+}
@@ -142,3 +142,50 @@ func (d *Decrypt1Stream) Decrypt1(data []byte) {
 		data[offset] = b1
 	}
 }
+
+// streamChunkSize is the amount of data decrypted at once by streaming readers, must be a multiple of blockSize
+const streamChunkSize = 64 * 1024
+
+// Decrypt1Reader is a streaming counterpart of Decrypt1Stream.Read, memory usage does not depend on data size.
+type Decrypt1Reader struct {
+	stream Decrypt1Stream
+	reader io.Reader
+	buf    []byte
+	out    []byte
+}
+
+func NewDecrypt1Reader(reader io.Reader, md5sum Md5Sum, pathHash uint64, version uint32, size int) *Decrypt1Reader {
+	d := &Decrypt1Reader{
+		reader: reader,
+		buf:    make([]byte, streamChunkSize),
+	}
+	d.stream.Init(md5sum, pathHash, version, size)
+
+	return d
+}
+
+func (d *Decrypt1Reader) Read(p []byte) (int, error) {
+	if len(d.out) == 0 {
+		remaining := d.stream.Size - d.stream.Position
+		if remaining <= 0 {
+			return 0, io.EOF
+		}
+
+		count := min(remaining, len(d.buf))
+		if _, err := io.ReadFull(d.reader, d.buf[:count]); err != nil {
+			return 0, fmt.Errorf("cannot read decrypt1stream: %w", err)
+		}
+
+		// last chunk is padded to full block, just like Decrypt1Stream.Read does
+		padded := (count + blockSize - 1) / blockSize * blockSize
+		clear(d.buf[count:padded])
+		d.stream.Decrypt1(d.buf[:padded])
+		d.stream.Position += count
+		d.out = d.buf[:count]
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+
+	return n, nil
+}
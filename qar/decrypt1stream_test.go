@@ -3,8 +3,11 @@ package qar
 import (
 	"bytes"
 	"crypto/md5"
+	"fmt"
+	"io"
 	"reflect"
 	"testing"
+	"testing/iotest"
 )
 
 func TestDecrypt1Stream_Read(t *testing.T) {
@@ -56,3 +59,39 @@ func TestDecrypt1Stream_Read(t *testing.T) {
 		})
 	}
 }
+
+func TestDecrypt1Reader_Read(t *testing.T) {
+	for _, version := range []uint32{1, 2} {
+		t.Run(fmt.Sprintf("version %d", version), func(t *testing.T) {
+			// spans several chunks and ends with incomplete block
+			data := bytes.Repeat([]byte("some data for testing"), streamChunkSize/7)
+			m := Md5Sum(md5.Sum(data))
+			pathHash := uint64(0x18e3189a8efa181d)
+
+			d := &Decrypt1Stream{}
+			d.Init(m, pathHash, version, len(data))
+			want, err := d.Read(bytes.NewReader(data), len(data))
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			r := NewDecrypt1Reader(bytes.NewReader(data), m, pathHash, version, len(data))
+			got, err := io.ReadAll(iotest.HalfReader(r))
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("streaming result differs from Decrypt1Stream.Read")
+			}
+		})
+	}
+}
+
+func TestDecrypt1Reader_Truncated(t *testing.T) {
+	data := []byte("some data for testing")
+	r := NewDecrypt1Reader(bytes.NewReader(data), Md5Sum{}, 0, 1, len(data)+10)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("expected error on truncated data")
+	}
+}
@@ -91,3 +91,48 @@ func (d *Decrypt2Stream) Decrypt2(input []byte, size int) ([]byte, error) {
 	// The final 0-3 bytes aren't encrypted, leave them as is
 	return output, nil
 }
+
+// Decrypt2Reader is a streaming counterpart of Decrypt2Stream.Read, memory usage does not depend on data size.
+type Decrypt2Reader struct {
+	stream    Decrypt2Stream
+	reader    io.Reader
+	remaining int
+	buf       []byte
+	out       []byte
+}
+
+func NewDecrypt2Reader(reader io.Reader, key uint32, size int) *Decrypt2Reader {
+	d := &Decrypt2Reader{
+		reader:    reader,
+		remaining: size,
+		buf:       make([]byte, streamChunkSize),
+	}
+	d.stream.Init(key)
+
+	return d
+}
+
+func (d *Decrypt2Reader) Read(p []byte) (int, error) {
+	if len(d.out) == 0 {
+		if d.remaining <= 0 {
+			return 0, io.EOF
+		}
+
+		// chunks are multiples of 4 bytes, so key state carries over exactly like in a single Decrypt2 call
+		count := min(d.remaining, len(d.buf))
+		if _, err := io.ReadFull(d.reader, d.buf[:count]); err != nil {
+			return 0, fmt.Errorf("cannot read decrypt2stream: %w", err)
+		}
+		d.remaining -= count
+
+		var err error
+		if d.out, err = d.stream.Decrypt2(d.buf[:count], count); err != nil {
+			return 0, fmt.Errorf("decrypt2 fail: %w", err)
+		}
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+
+	return n, nil
+}
@@ -2,8 +2,10 @@ package qar
 
 import (
 	"bytes"
+	"io"
 	"reflect"
 	"testing"
+	"testing/iotest"
 )
 
 func TestDecrypt2Stream_Decrypt2(t *testing.T) {
@@ -88,3 +90,26 @@ func TestDecrypt2Stream_Read(t *testing.T) {
 		})
 	}
 }
+
+func TestDecrypt2Reader_Read(t *testing.T) {
+	// spans several chunks and ends with unencrypted tail
+	data := bytes.Repeat([]byte("data1234567890\ndata1234567\n"), streamChunkSize/9)
+	key := uint32(0xcafebabe)
+
+	d := &Decrypt2Stream{}
+	d.Init(key)
+	want, err := d.Read(bytes.NewReader(data), len(data))
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	r := NewDecrypt2Reader(bytes.NewReader(data), key, len(data))
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("streaming result differs from Decrypt2Stream.Read")
+	}
+}
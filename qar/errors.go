@@ -0,0 +1,46 @@
+package qar
+
+import (
+	"fmt"
+
+	"github.com/unknown321/datfpk/util"
+)
+
+// Header errors of Read, util errors they wrap match bad magic and version of any format
+var (
+	ErrBadMagic           = fmt.Errorf("qar: %w", util.ErrBadMagic)
+	ErrUnsupportedVersion = fmt.Errorf("qar: %w", util.ErrUnsupportedVersion)
+)
+
+// EntryNotFoundError is returned when archive has no entry with requested path or hash.
+type EntryNotFoundError struct {
+	Hash uint64
+	Path string
+}
+
+func (e *EntryNotFoundError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("qar entry not found, hash: %x", e.Hash)
+	}
+
+	return fmt.Sprintf("qar entry not found, path: %s", e.Path)
+}
+
+// CorruptEntryError is returned when entry header or data at Offset cannot be read or decoded.
+type CorruptEntryError struct {
+	Offset int64
+	Reason string
+	Err    error
+}
+
+func (e *CorruptEntryError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("corrupt qar entry at offset %d: %s", e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("corrupt qar entry at offset %d: %s: %s", e.Offset, e.Reason, e.Err.Error())
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
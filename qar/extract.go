@@ -0,0 +1,223 @@
+package qar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/unknown321/datfpk/util"
+)
+
+// Resolver returns entry path by its hash, hashing.Dictionary.GetByHash is a Resolver.
+type Resolver func(hash uint64) (string, bool)
+
+// DuplicatePathError is several entries resolved to the same path. The first entry keeps Path, others are
+// extracted under paths with their hash, see Resolve.
+type DuplicatePathError struct {
+	Path   string
+	Hashes []uint64
+}
+
+func (e *DuplicatePathError) Error() string {
+	hashes := make([]string, len(e.Hashes))
+	for i, h := range e.Hashes {
+		hashes[i] = fmt.Sprintf("%x", h)
+	}
+
+	return fmt.Sprintf("entries %s resolve to the same path %s", strings.Join(hashes, ", "), e.Path)
+}
+
+// duplicatePath returns p with hash before extension, /Assets/a.lua becomes /Assets/a_<hash>.lua
+func duplicatePath(p string, hash uint64) string {
+	ext := path.Ext(p)
+	return fmt.Sprintf("%s_%x%s", strings.TrimSuffix(p, ext), hash, ext)
+}
+
+// Resolve sets entry paths using resolver. Unresolved entries keep their hash for packing.
+// If several entries share a path, the first one keeps it, others get paths with their hash (see duplicatePath)
+// and keep their hash for packing; DuplicatePathError for each such path is returned.
+func (q *Qar) Resolve(resolver Resolver) error {
+	hashes := make(map[string][]uint64, len(q.Entries))
+	var paths []string
+	for n, e := range q.Entries {
+		entryName, resolved := resolver(e.Header.PathHash)
+		q.Entries[n].Header.FilePath = entryName
+		q.Entries[n].Header.NameHashForPacking = 0
+		if !resolved {
+			q.Entries[n].Header.NameHashForPacking = e.Header.PathHash
+		}
+
+		if _, ok := hashes[entryName]; !ok {
+			paths = append(paths, entryName)
+		} else {
+			q.Entries[n].Header.FilePath = duplicatePath(entryName, e.Header.PathHash)
+			q.Entries[n].Header.NameHashForPacking = e.Header.PathHash
+		}
+		hashes[entryName] = append(hashes[entryName], e.Header.PathHash)
+	}
+
+	var errs []error
+	for _, p := range paths {
+		if len(hashes[p]) > 1 {
+			errs = append(errs, &DuplicatePathError{Path: p, Hashes: hashes[p]})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ExtractError is an extraction failure of a single entry.
+type ExtractError struct {
+	Path string
+	Hash uint64
+	Err  error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("extract %s (%x): %s", e.Path, e.Hash, e.Err.Error())
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractErrors holds every failed entry in definition order.
+type ExtractErrors []*ExtractError
+
+func (e ExtractErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	return fmt.Sprintf("%d entries failed, first: %s", len(e), e[0].Error())
+}
+
+func (e ExtractErrors) Unwrap() []error {
+	res := make([]error, len(e))
+	for i, v := range e {
+		res[i] = v
+	}
+
+	return res
+}
+
+// ExtractAll extracts every entry, see ExtractFiltered.
+func (q *Qar) ExtractAll(outDir string, workers int) error {
+	return q.ExtractFiltered(outDir, workers, nil)
+}
+
+// ExtractFiltered extracts entries selected by filter to <outDir> (see Extract) using <workers> goroutines,
+// runtime.NumCPU() workers are used if <workers> is less than 1.
+// Each worker reads archive with its own cursor, so underlying reader must implement io.ReaderAt
+// to be read concurrently; otherwise entries are extracted one by one.
+// Entries are written under their FilePath, see Resolve.
+// Failed entries do not stop extraction, returned error is ExtractErrors.
+func (q *Qar) ExtractFiltered(outDir string, workers int, filter *util.Filter) error {
+	return q.ExtractWith(workers, filter, func(e *Entry) (io.WriteCloser, error) {
+		return createFile(q.outputPath(e.Header.FilePath, outDir))
+	}, nil)
+}
+
+// ExtractWith is ExtractFiltered writing entries to writers returned by create, writers are closed
+// after entry is written. If done is not nil, it is called for every selected entry from worker goroutines.
+func (q *Qar) ExtractWith(workers int, filter *util.Filter, create func(e *Entry) (io.WriteCloser, error), done func(e *Entry, err error)) error {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	readerAt, ok := q.handle.(io.ReaderAt)
+	if !ok {
+		workers = 1
+	}
+
+	indices := make(chan int)
+	failed := make([]*ExtractError, len(q.Entries))
+	wg := sync.WaitGroup{}
+
+	for range workers {
+		reader := q.handle
+		if readerAt != nil {
+			reader = io.NewSectionReader(readerAt, 0, math.MaxInt64)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				e := &q.Entries[i]
+				err := extractEntryTo(e, reader, create)
+				if err != nil {
+					failed[i] = &ExtractError{Path: e.Header.FilePath, Hash: e.Header.PathHash, Err: err}
+				}
+
+				if done != nil {
+					done(e, err)
+				}
+			}
+		}()
+	}
+
+	for i, e := range q.Entries {
+		if filter.Match(e.Header.FilePath, e.Header.PathHash) {
+			indices <- i
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	var res ExtractErrors
+	for _, v := range failed {
+		if v != nil {
+			res = append(res, v)
+		}
+	}
+
+	if len(res) > 0 {
+		return res
+	}
+
+	return nil
+}
+
+// Subset returns archive with entries selected by filter, entries are copied.
+func (q *Qar) Subset(filter *util.Filter) *Qar {
+	res := *q
+	res.Entries = nil
+	for _, e := range q.Entries {
+		if filter.Match(e.Header.FilePath, e.Header.PathHash) {
+			res.Entries = append(res.Entries, e)
+		}
+	}
+
+	return &res
+}
+
+func extractEntryTo(e *Entry, reader io.ReadSeeker, create func(e *Entry) (io.WriteCloser, error)) error {
+	outFile, err := create(e)
+	if err != nil {
+		return fmt.Errorf("extract open output file: %w", err)
+	}
+
+	if _, err = extractEntry(e, reader, outFile); err != nil {
+		_ = outFile.Close()
+		return err
+	}
+
+	return outFile.Close()
+}
+
+// createFile creates file with parent directories
+func createFile(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("outdir %s: %w", filepath.Dir(path), err)
+	}
+
+	return os.OpenFile(path, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0644)
+}
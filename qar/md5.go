@@ -46,6 +46,14 @@ func (d *Md5Sum) MarshalJSON() ([]byte, error) {
 	return json.Marshal(hex.EncodeToString(d[:]))
 }
 
+func nonEmptyMd5(d Md5Sum) *Md5Sum {
+	if d.Empty() {
+		return nil
+	}
+
+	return &d
+}
+
 func Md5Decode(in Md5Sum) (Md5Sum, error) {
 	reader := bytes.NewReader(in[:])
 	md51, err := ReadUint32(reader)
@@ -0,0 +1,106 @@
+package qar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type packResult struct {
+	data []byte
+	err  error
+}
+
+// packQueue packs entries in parallel, keeping only a few packed entries in memory at once.
+type packQueue struct {
+	results []chan packResult
+	window  chan struct{}
+	done    chan struct{}
+}
+
+func (q *Qar) packEntries(baseDir string, workers int) *packQueue {
+	p := &packQueue{
+		results: make([]chan packResult, len(q.Entries)),
+		window:  make(chan struct{}, workers*2),
+		done:    make(chan struct{}),
+	}
+
+	for i := range p.results {
+		p.results[i] = make(chan packResult, 1)
+	}
+
+	source := newRawSource(q.Source)
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range q.Entries {
+			select {
+			case p.window <- struct{}{}:
+			case <-p.done:
+				return
+			}
+
+			select {
+			case jobs <- i:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	for range workers {
+		go func() {
+			for i := range jobs {
+				data, err := q.packEntry(i, baseDir, source)
+				p.results[i] <- packResult{data: data, err: err}
+			}
+		}()
+	}
+
+	return p
+}
+
+// get waits for entry <i>, entries must be requested in order
+func (p *packQueue) get(i int) ([]byte, error) {
+	r := <-p.results[i]
+	<-p.window
+
+	return r.data, r.err
+}
+
+func (p *packQueue) stop() {
+	close(p.done)
+}
+
+// packEntry returns compressed and encrypted entry <i> with its header, entry itself is not modified.
+// Unmodified entries are taken from source if possible.
+func (q *Qar) packEntry(i int, baseDir string, source *rawSource) ([]byte, error) {
+	var err error
+	e := q.Entries[i]
+	e.Header.Version = q.Version
+
+	if len(e.Data) == 0 {
+		pl := ""
+		if pl, err = filepath.Localize(strings.TrimPrefix(e.Header.FilePath, "/")); err != nil {
+			return nil, fmt.Errorf("cannot localize entry path %s: %w", pl, err)
+		}
+
+		p := filepath.Join(baseDir, e.Header.FilePath)
+		if e.Data, err = os.ReadFile(p); err != nil {
+			return nil, fmt.Errorf("read entry data: %w", err)
+		}
+	}
+
+	var data []byte
+	var ok bool
+	if data, ok, err = source.get(&e); err != nil || ok {
+		return data, err
+	}
+
+	if data, err = e.Write(); err != nil {
+		return nil, fmt.Errorf("write entry to array %s: %w", e.Header.FilePath, err)
+	}
+
+	return data, nil
+}
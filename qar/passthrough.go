@@ -0,0 +1,83 @@
+package qar
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/unknown321/hashing"
+)
+
+// rawSource provides original bytes of unmodified entries, see Qar.Source.
+type rawSource struct {
+	qar     *Qar
+	entries map[uint64]*Entry
+	lock    sync.Mutex
+}
+
+func newRawSource(source *Qar) *rawSource {
+	if source == nil || source.handle == nil {
+		return nil
+	}
+
+	r := &rawSource{
+		qar:     source,
+		entries: make(map[uint64]*Entry, len(source.Entries)),
+	}
+
+	for i, e := range source.Entries {
+		r.entries[e.Header.PathHash] = &source.Entries[i]
+	}
+
+	return r
+}
+
+// get returns original entry header, data header and payload if entry data and settings
+// did not change since extraction.
+func (r *rawSource) get(e *Entry) ([]byte, bool, error) {
+	if r == nil || e.Header.DataMd5.Empty() || e.Header.SourceMd5.Empty() {
+		return nil, false, nil
+	}
+
+	hash := e.Header.NameHashForPacking
+	if hash == 0 {
+		hash = hashing.HashFileNameWithExtension(e.Header.FilePath)
+	}
+
+	orig, ok := r.entries[hash]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if orig.Header.Md5Sum != e.Header.SourceMd5 ||
+		r.qar.Version != e.Header.Version ||
+		orig.Header.Compressed != e.Header.Compressed ||
+		orig.DataHeader.Key != e.DataHeader.Key {
+		return nil, false, nil
+	}
+
+	if md5.Sum(e.Data) != e.Header.DataMd5 {
+		return nil, false, nil
+	}
+
+	res := make([]byte, HeaderSize+int64(orig.Header.CompressedSize))
+	offset := orig.Header.DataOffset - HeaderSize
+
+	var err error
+	if readerAt, ok := r.qar.handle.(io.ReaderAt); ok {
+		_, err = readerAt.ReadAt(res, offset)
+	} else {
+		r.lock.Lock()
+		if _, err = r.qar.handle.Seek(offset, io.SeekStart); err == nil {
+			_, err = io.ReadFull(r.qar.handle, res)
+		}
+		r.lock.Unlock()
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("read original entry %x: %w", hash, err)
+	}
+
+	return res, true, nil
+}
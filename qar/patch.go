@@ -0,0 +1,260 @@
+package qar
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/unknown321/datfpk/util"
+	"github.com/unknown321/hashing"
+)
+
+// Patch describes changes to an existing archive, see Qar.Patch.
+type Patch struct {
+	// Put replaces entries with the same path hash, entries without a match are added.
+	// Data must be set, FilePath or NameHashForPacking identify the entry.
+	Put []Entry
+	// Remove lists entry paths to remove.
+	Remove []string
+	// Compact moves entries together, reclaiming space left by replaced and removed entries.
+	Compact bool
+}
+
+// Patch applies changes to archive <file> which was read into q.
+// Unchanged entries are not decrypted and stay where they are; new and replaced entries are
+// appended to the end of file, then header and section table are rewritten.
+// Entries overlapped by a grown section table are moved to the end of file as is.
+// If <file> implements Truncate(int64) error, it is truncated after compaction.
+func (q *Qar) Patch(file io.ReadWriteSeeker, patch Patch) error {
+	q.handle = file
+
+	shift := q.blockShift()
+	alignment := int64(1) << shift
+
+	index := map[uint64]int{}
+	for i, e := range q.Entries {
+		index[e.Header.PathHash] = i
+	}
+
+	removed := map[int]bool{}
+	for _, path := range patch.Remove {
+		i, ok := index[hashing.HashFileNameWithExtension(path)]
+		if !ok {
+			e, err := q.Find(path, 0)
+			if err != nil {
+				return fmt.Errorf("remove: %w", err)
+			}
+			i = index[e.Header.PathHash]
+		}
+
+		removed[i] = true
+	}
+
+	entries := make([]Entry, 0, len(q.Entries)+len(patch.Put))
+	changed := map[int]bool{}
+	for i, e := range q.Entries {
+		if !removed[i] {
+			entries = append(entries, e)
+		}
+	}
+
+	for _, e := range patch.Put {
+		if e.Header.NameHashForPacking == 0 {
+			e.Header.PathHash = hashing.HashFileNameWithExtension(e.Header.FilePath)
+		} else {
+			e.Header.PathHash = e.Header.NameHashForPacking
+		}
+
+		n := slices.IndexFunc(entries, func(v Entry) bool { return v.Header.PathHash == e.Header.PathHash })
+		if n < 0 {
+			n = len(entries)
+			entries = append(entries, e)
+		} else {
+			entries[n] = e
+		}
+
+		changed[n] = true
+	}
+
+	tableEnd := alignUp(int64(HeaderSize+blockSize*len(entries)), alignment)
+	end := max(alignUp(int64(q.BlockFileEnd)<<shift, alignment), tableEnd)
+
+	var err error
+	for n := range entries {
+		e := &entries[n]
+		if changed[n] {
+			e.Header.Version = q.Version
+			var data []byte
+			if data, err = e.Write(); err != nil {
+				return fmt.Errorf("pack entry %s: %w", e.Header.FilePath, err)
+			}
+
+			if _, err = file.Seek(end, io.SeekStart); err != nil {
+				return fmt.Errorf("seek entry %s: %w", e.Header.FilePath, err)
+			}
+
+			if _, err = file.Write(data); err != nil {
+				return fmt.Errorf("write entry %s: %w", e.Header.FilePath, err)
+			}
+
+			e.Data = nil
+			e.Header.DataOffset = end + HeaderSize
+			if end, err = util.AlignWrite(file, alignment); err != nil {
+				return fmt.Errorf("entry align fail: %w", err)
+			}
+
+			continue
+		}
+
+		start := e.Header.DataOffset - HeaderSize
+		if start >= tableEnd {
+			continue
+		}
+
+		//slog.Debug("moving entry overlapped by section table", "path", e.Header.FilePath, "offset", start)
+		size := HeaderSize + int64(e.Header.CompressedSize)
+		if err = copyWithin(file, end, start, size); err != nil {
+			return fmt.Errorf("move entry %x: %w", e.Header.PathHash, err)
+		}
+
+		e.Header.DataOffset = end + HeaderSize
+		if end, err = util.AlignWrite(file, alignment); err != nil {
+			return fmt.Errorf("entry align fail: %w", err)
+		}
+	}
+
+	q.Entries = entries
+	q.OffsetFirstFile = uint32(max(int64(q.OffsetFirstFile), tableEnd))
+	q.BlockFileEnd = uint32(end >> shift)
+
+	if err = q.writeSections(file); err != nil {
+		return err
+	}
+
+	if patch.Compact {
+		return q.Compact(file)
+	}
+
+	return nil
+}
+
+// Compact moves entries of archive <file> read into q towards its start in file order,
+// so there are no gaps left by Patch. Entry data is moved as is.
+// If <file> implements Truncate(int64) error, it is truncated to the new size.
+func (q *Qar) Compact(file io.ReadWriteSeeker) error {
+	q.handle = file
+
+	shift := q.blockShift()
+	alignment := int64(1) << shift
+
+	order := make([]int, len(q.Entries))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		return int(q.Entries[a].Header.DataOffset - q.Entries[b].Header.DataOffset)
+	})
+
+	end := alignUp(int64(HeaderSize+blockSize*len(q.Entries)), alignment)
+	q.OffsetFirstFile = uint32(end)
+
+	var err error
+	for _, i := range order {
+		e := &q.Entries[i]
+		start := e.Header.DataOffset - HeaderSize
+		size := HeaderSize + int64(e.Header.CompressedSize)
+		if start != end {
+			if err = copyWithin(file, end, start, size); err != nil {
+				return fmt.Errorf("move entry %x: %w", e.Header.PathHash, err)
+			}
+		} else if _, err = file.Seek(end+size, io.SeekStart); err != nil {
+			return fmt.Errorf("seek entry end: %w", err)
+		}
+
+		e.Header.DataOffset = end + HeaderSize
+		if end, err = util.AlignWrite(file, alignment); err != nil {
+			return fmt.Errorf("entry align fail: %w", err)
+		}
+	}
+
+	q.BlockFileEnd = uint32(end >> shift)
+	if err = q.writeSections(file); err != nil {
+		return err
+	}
+
+	if t, ok := file.(interface{ Truncate(int64) error }); ok {
+		if err = t.Truncate(end); err != nil {
+			return fmt.Errorf("truncate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeSections writes header and section table for current entry offsets.
+// Space between section table and first entry is zeroed.
+func (q *Qar) writeSections(file io.WriteSeeker) error {
+	shift := q.blockShift()
+	q.FileCount = uint32(len(q.Entries))
+
+	sections := make([]uint64, len(q.Entries))
+	for i, e := range q.Entries {
+		sections[i] = encodeSection(e.Header.DataOffset-HeaderSize, shift, e.Header.PathHash)
+	}
+
+	var err error
+	if err = q.writeHeader(file, sections); err != nil {
+		return err
+	}
+
+	var pos int64
+	if pos, err = file.Seek(0, io.SeekCurrent); err != nil {
+		return fmt.Errorf("seek table end: %w", err)
+	}
+
+	if gap := int64(q.OffsetFirstFile) - pos; gap > 0 {
+		if _, err = file.Write(make([]byte, gap)); err != nil {
+			return fmt.Errorf("clear table gap: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// copyWithin copies <size> bytes from <src> to <dst> of the same file.
+// Regions may overlap only if dst < src. File position is set to the end of copied data.
+func copyWithin(file io.ReadWriteSeeker, dst int64, src int64, size int64) error {
+	buf := make([]byte, min(size, streamChunkSize))
+	for done := int64(0); done < size; {
+		chunk := buf[:min(size-done, int64(len(buf)))]
+		if _, err := file.Seek(src+done, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return err
+		}
+
+		if _, err := file.Seek(dst+done, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := file.Write(chunk); err != nil {
+			return err
+		}
+
+		done += int64(len(chunk))
+	}
+
+	_, err := file.Seek(dst+size, io.SeekStart)
+	return err
+}
+
+func alignUp(v int64, alignment int64) int64 {
+	if v%alignment == 0 {
+		return v
+	}
+
+	return v + alignment - v%alignment
+}
@@ -0,0 +1,182 @@
+package qar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/unknown321/datfpk/util"
+	"github.com/unknown321/hashing"
+)
+
+func patchTestQar(t *testing.T, count int) (*Qar, *util.ByteArrayReaderWriter, map[string][]byte) {
+	t.Helper()
+
+	q := &Qar{
+		Magic:   magic,
+		Flags:   3150304,
+		Version: 1,
+	}
+
+	want := map[string][]byte{}
+	for i := 0; i < count; i++ {
+		e := Entry{
+			Header: EntryHeader{
+				FilePath:   fmt.Sprintf("/Assets/test/test%d.lua", i),
+				Compressed: i%2 == 0,
+			},
+			Data: bytes.Repeat([]byte(fmt.Sprintf("some data %d for testing\n", i)), 100),
+		}
+
+		if i%3 == 0 {
+			e.DataHeader.Key = 0xCAFEBABE
+		}
+
+		want[e.Header.FilePath] = e.Data
+		q.Entries = append(q.Entries, e)
+	}
+
+	out := &util.ByteArrayReaderWriter{}
+	if err := q.Write(out, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	file := util.NewByteArrayReaderWriter(out.Bytes())
+	read := &Qar{}
+	if err := read.Read(file); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	return read, file, want
+}
+
+func checkPatched(t *testing.T, file *util.ByteArrayReaderWriter, want map[string][]byte) *Qar {
+	t.Helper()
+
+	q := &Qar{}
+	if err := q.Read(util.NewByteArrayReaderWriter(file.Bytes())); err != nil {
+		t.Fatalf("read patched: %s", err.Error())
+	}
+
+	if len(q.Entries) != len(want) {
+		t.Fatalf("entries: have %d, want %d", len(q.Entries), len(want))
+	}
+
+	for path, data := range want {
+		buf := &bytes.Buffer{}
+		if _, err := q.ExtractTo(path, hashing.HashFileNameWithExtension(path), buf); err != nil {
+			t.Fatalf("extract %s: %s", path, err.Error())
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: data mismatch", path)
+		}
+	}
+
+	report, err := q.Verify()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !report.OK() {
+		t.Errorf("unexpected corrupt entries: %+v", report.Corrupt)
+	}
+
+	return q
+}
+
+func TestQar_Patch(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     int
+		compact bool
+	}{
+		{name: "simple"},
+		{name: "simple compact", compact: true},
+		{name: "grow section table", add: 200},
+		{name: "grow section table compact", add: 200, compact: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, file, want := patchTestQar(t, 8)
+			sizeBefore := q.BlockFileEnd
+			firstOffset := q.Entries[0].Header.DataOffset
+
+			replaced := Entry{
+				Header: EntryHeader{FilePath: "/Assets/test/test3.lua", Compressed: true},
+				Data:   []byte("replaced data"),
+			}
+			replaced.DataHeader.Key = 0x12345678
+
+			patch := Patch{
+				Put:     []Entry{replaced},
+				Remove:  []string{"/Assets/test/test4.lua"},
+				Compact: tt.compact,
+			}
+
+			want[replaced.Header.FilePath] = replaced.Data
+			delete(want, "/Assets/test/test4.lua")
+
+			for i := 0; i < tt.add; i++ {
+				e := Entry{
+					Header: EntryHeader{FilePath: fmt.Sprintf("/Assets/test/added%d.lua", i)},
+					Data:   []byte(fmt.Sprintf("added %d", i)),
+				}
+				want[e.Header.FilePath] = e.Data
+				patch.Put = append(patch.Put, e)
+			}
+
+			if err := q.Patch(file, patch); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			patched := checkPatched(t, file, want)
+			if tt.compact && tt.add == 0 && patched.BlockFileEnd > sizeBefore {
+				t.Errorf("compacted archive grew: %d > %d blocks", patched.BlockFileEnd, sizeBefore)
+			}
+
+			// unchanged entries keep their data in place
+			if tt.add == 0 && !tt.compact && patched.Entries[0].Header.DataOffset != firstOffset {
+				t.Errorf("unchanged entry moved")
+			}
+		})
+	}
+}
+
+func TestQar_PatchRemoveMissing(t *testing.T) {
+	q, file, _ := patchTestQar(t, 2)
+	if err := q.Patch(file, Patch{Remove: []string{"/Assets/missing.lua"}}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// shortWriter fails writes past limit
+type shortWriter struct {
+	*util.ByteArrayReaderWriter
+	limit int64
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	pos, _ := w.Seek(0, io.SeekCurrent)
+	if pos+int64(len(p)) > w.limit {
+		n, _ := w.ByteArrayReaderWriter.Write(p[:max(w.limit-pos, 0)])
+		return n, io.ErrShortWrite
+	}
+
+	return w.ByteArrayReaderWriter.Write(p)
+}
+
+func TestQar_WriteHeaderShortWrite(t *testing.T) {
+	q, _, _ := patchTestQar(t, 2)
+	sections := make([]uint64, len(q.Entries))
+	// magic, header fields, section table
+	for _, limit := range []int64{0, 2, 4, 10, 31, 36} {
+		w := &shortWriter{ByteArrayReaderWriter: util.NewByteArrayReaderWriter([]byte{}), limit: limit}
+		if err := q.writeHeader(w, sections); !errors.Is(err, io.ErrShortWrite) {
+			t.Errorf("limit %d: want short write error, got %v", limit, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package qar
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -30,6 +32,14 @@ type Qar struct {
 	FilePath string  `json:"-"`
 	Entries  []Entry `json:"entries"`
 
+	// Source is the archive this one was extracted from, entries with unchanged data are copied
+	// from it without recompression and encryption.
+	Source *Qar `json:"-"`
+	// SourcePath is file name of the archive definition was saved from.
+	SourcePath string `json:"-"`
+	// OnWrite is called after each entry is written by Write and WriteParallel.
+	OnWrite func(e *Entry) `json:"-"`
+
 	handle io.ReadSeeker
 }
 
@@ -37,18 +47,27 @@ var magic = [4]byte{0x53, 0x51, 0x41, 0x52} // SQAR
 const QarID = "qar"
 
 type qjs struct {
-	Type    string  `json:"type"`
-	Flags   uint32  `json:"flags"`
-	Version uint32  `json:"version"`
-	Entries []Entry `json:"entries"`
+	Type         string  `json:"type"`
+	Flags        uint32  `json:"flags"`
+	Version      uint32  `json:"version"`
+	UnknownCount uint32  `json:"unknownCount,omitempty"`
+	Source       string  `json:"source,omitempty"`
+	Entries      []Entry `json:"entries"`
 }
 
 func (q *Qar) MarshalJSON() ([]byte, error) {
+	source := q.SourcePath
+	if source == "" && q.FilePath != "" {
+		source = filepath.Base(q.FilePath)
+	}
+
 	return json.Marshal(qjs{
-		Type:    QarID,
-		Flags:   q.Flags,
-		Version: q.Version,
-		Entries: q.Entries,
+		Type:         QarID,
+		Flags:        q.Flags,
+		Version:      q.Version,
+		UnknownCount: q.UnknownCount,
+		Source:       source,
+		Entries:      q.Entries,
 	})
 }
 
@@ -65,6 +84,8 @@ func (q *Qar) UnmarshalJSON(i []byte) error {
 	q.Flags = qq.Flags
 	q.Entries = qq.Entries
 	q.Version = qq.Version
+	q.UnknownCount = qq.UnknownCount
+	q.SourcePath = qq.Source
 
 	return nil
 }
@@ -82,8 +103,8 @@ func (q *Qar) ReadFrom(path string) error {
 }
 
 func (q *Qar) Close() {
-	if q.handle != nil {
-		q.handle.(*os.File).Close()
+	if c, ok := q.handle.(io.Closer); ok {
+		_ = c.Close()
 	}
 }
 
@@ -96,7 +117,7 @@ func (q *Qar) Read(f io.ReadSeeker) error {
 	}
 
 	if bytes.Compare(q.Magic[:], magic[:]) != 0 {
-		return fmt.Errorf("magic mismatch")
+		return fmt.Errorf("%w: [% x]", ErrBadMagic, q.Magic)
 	}
 
 	if err = binary.Read(f, binary.LittleEndian, &q.Flags); err != nil {
@@ -135,14 +156,22 @@ func (q *Qar) Read(f io.ReadSeeker) error {
 	q.Version ^= xorMask1  // 1 2
 	q.Unknown2 ^= xorMask2 // 0
 
+	if q.Version != 1 && q.Version != 2 {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, q.Version)
+	}
+
 	// Determines the alignment block size.
 	blockShiftBits := 10
 	if (q.Flags & 0x800) > 0 {
 		blockShiftBits = 12
 	}
 
-	sectionsData := make([]byte, 8*q.FileCount)
-	if _, err = f.Read(sectionsData); err != nil {
+	if err = util.CheckSize(f, 8*int64(q.FileCount)); err != nil {
+		return fmt.Errorf("sections: %w", err)
+	}
+
+	sectionsData := make([]byte, 8*int64(q.FileCount))
+	if _, err = io.ReadFull(f, sectionsData); err != nil {
 		return fmt.Errorf("cannot read sections: %w", err)
 	}
 
@@ -161,7 +190,7 @@ func (q *Qar) Read(f io.ReadSeeker) error {
 
 		e := Entry{}
 		if err = e.Read(f, q.Version); err != nil {
-			return fmt.Errorf("cannot read entry: %w", err)
+			return &CorruptEntryError{Offset: int64(sectionOffset), Reason: "cannot read entry", Err: err}
 		}
 
 		q.Entries = append(q.Entries, e)
@@ -246,28 +275,26 @@ func DecryptSectionList(fileCount uint32, sections []byte, version uint32, encry
 	return result, nil
 }
 
-// ExtractTo qar path to writer. Writer must be closed by user.
-func (q *Qar) ExtractTo(path string, hash uint64, writer io.Writer) (int, error) {
-	//hash := hashing.HashFileNameWithExtension(path)
-	var entry *Entry = nil
-
+// Find returns entry with given hash. Entries extracted without dictionary are named by hash
+// (38dd243657e7f.lua), such names are matched too.
+func (q *Qar) Find(path string, hash uint64) (*Entry, error) {
 	var hashFromName uint64
-	var err error
 	if filepath.Base(path) == path {
 		hash2 := strings.TrimSuffix(path, filepath.Ext(path))
-		hashFromName, err = strconv.ParseUint(hash2, 16, 64)
+		h, err := strconv.ParseUint(hash2, 16, 64)
 		if err != nil {
 			hash2 = strings.TrimSuffix(hash2, filepath.Ext(hash2)) // entry=38dd243657e7f.2.ftexs
-			if hashFromName, err = strconv.ParseUint(hash2, 16, 64); err != nil {
-				return 0, fmt.Errorf("hashFromName: %w", err)
-			}
+			h, err = strconv.ParseUint(hash2, 16, 64)
 		}
 
-		hashFromName = hashing.JustAddExtension(hashFromName, filepath.Ext(path))
+		// not a hash, entry is matched by hash only
+		if err == nil {
+			hashFromName = hashing.JustAddExtension(h, filepath.Ext(path))
+		}
 		//slog.Debug("hashfromname", "value", fmt.Sprintf("0x%x", hashFromName), "ext", filepath.Ext(path)[1:])
 	}
 
-	for _, e := range q.Entries {
+	for i, e := range q.Entries {
 		//slog.Info("q",
 		//	"ph", fmt.Sprintf("%x", e.Header.PathHash),
 		//	"hash", fmt.Sprintf("%x", hash),
@@ -278,43 +305,85 @@ func (q *Qar) ExtractTo(path string, hash uint64, writer io.Writer) (int, error)
 			}
 		}
 
-		entry = &e
-		break
+		return &q.Entries[i], nil
+	}
+
+	return nil, &EntryNotFoundError{Hash: hash, Path: path}
+}
+
+// ExtractTo qar path to writer. Writer must be closed by user.
+func (q *Qar) ExtractTo(path string, hash uint64, writer io.Writer) (int, error) {
+	entry, err := q.Find(path, hash)
+	if err != nil {
+		return 0, err
 	}
 
-	if entry == nil {
-		return 0, fmt.Errorf("entry not found, path: %s", path)
+	return extractEntry(entry, q.handle, writer)
+}
+
+// extractEntry writes entry data to writer, entry DataMd5 and SourceMd5 are set for passthrough packing
+func extractEntry(entry *Entry, reader io.ReadSeeker, writer io.Writer) (int, error) {
+	r, err := entry.Open(reader)
+	if err != nil {
+		return 0, &CorruptEntryError{Offset: entry.Header.DataOffset, Reason: "cannot open data", Err: err}
 	}
+	defer r.Close()
 
-	if err = entry.ReadData(q.handle); err != nil {
-		return 0, fmt.Errorf("qar entry read data: %w", err)
+	sum := md5.New()
+	data := &readErrReader{Reader: r}
+	n, err := io.Copy(io.MultiWriter(writer, sum), data)
+	if data.err != nil {
+		return 0, &CorruptEntryError{Offset: entry.Header.DataOffset, Reason: "cannot decode data", Err: data.err}
 	}
 
-	n, err := writer.Write(entry.Data)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("qar entry write data: %w", err)
 	}
-	return n, nil
+
+	copy(entry.Header.DataMd5[:], sum.Sum(nil))
+	entry.Header.SourceMd5 = entry.Header.Md5Sum
+
+	return int(n), nil
 }
 
-// Extract extracts entry with <hash> to <outDir>, optionally using <path> as output filename
-// example: extract file with hash 0x123456 to /tmp/test with name /assets/test:
-//
-//	q.Extract("/assets/test", 0x123456, "/tmp/test")
-func (q *Qar) Extract(path string, hash uint64, outDir string) (int, error) {
+// readErrReader keeps read error to tell it apart from write error of io.Copy
+type readErrReader struct {
+	io.Reader
+	err error
+}
+
+func (r *readErrReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+// outputPath returns extraction path of entry named <path>, see Extract
+func (q *Qar) outputPath(path string, outDir string) string {
 	datDirName := outDir
 	workdir := ""
 	if outDir == "" {
 		workdir = filepath.Dir(q.FilePath)
 		datDirName = strings.TrimSuffix(filepath.Base(q.FilePath), ".dat") + "_dat"
 	}
-	outp := filepath.Dir(path)
-	outDir = filepath.Join(workdir, datDirName, outp)
+
+	return filepath.Join(workdir, datDirName, path)
+}
+
+// Extract extracts entry with <hash> to <outDir>, optionally using <path> as output filename
+// example: extract file with hash 0x123456 to /tmp/test with name /assets/test:
+//
+//	q.Extract("/assets/test", 0x123456, "/tmp/test")
+func (q *Qar) Extract(path string, hash uint64, outDir string) (int, error) {
+	outfilePath := q.outputPath(path, outDir)
+	outDir = filepath.Dir(outfilePath)
 	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
 		return 0, fmt.Errorf("outdir %s: %w", outDir, err)
 	}
 
-	outfilePath := filepath.Join(outDir, filepath.Base(path))
 	outFile, err := os.OpenFile(outfilePath, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return 0, fmt.Errorf("extract open output file: %w", err)
@@ -343,13 +412,20 @@ func (q *Qar) SaveDefinition(writer io.Writer) error {
 }
 
 func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) error {
+	return q.WriteParallel(file, baseDir, printLog, 1)
+}
+
+// WriteParallel is Write with entries compressed and encrypted by <workers> goroutines,
+// runtime.NumCPU() workers are used if <workers> is less than 1.
+// Entries are written in definition order, output is identical to Write.
+func (q *Qar) WriteParallel(file io.ReadWriteSeeker, baseDir string, printLog bool, workers int) error {
 	q.handle = file
 
-	shift := 10 // 1024
-	if q.Flags&0x800 > 0 {
-		shift = 12 // 4096
+	if workers < 1 {
+		workers = runtime.NumCPU()
 	}
 
+	shift := q.blockShift()
 	alignment := 1 << shift
 
 	_, err := file.Seek(int64(HeaderSize+blockSize*len(q.Entries)), io.SeekStart)
@@ -365,6 +441,9 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 	q.OffsetFirstFile = uint32(dataOffset)
 	//slog.Debug("write qar", "offsetFirstFile", q.OffsetFirstFile)
 
+	results := q.packEntries(baseDir, workers)
+	defer results.stop()
+
 	sections := make([]uint64, len(q.Entries))
 	for i, e := range q.Entries {
 		if printLog {
@@ -374,7 +453,6 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 				"key", fmt.Sprintf("%x", e.DataHeader.Key),
 			)
 		}
-		e.Header.Version = q.Version
 
 		if e.Header.NameHashForPacking == 0 {
 			e.Header.PathHash = hashing.HashFileNameWithExtension(e.Header.FilePath)
@@ -390,24 +468,15 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 		}
 
 		//slog.Debug("qar write section", "pos", pos, "pathHash", fmt.Sprintf("%x", e.Header.PathHash), "filePath", e.Header.FilePath)
-		section := uint64((pos>>shift)<<40) | (e.Header.PathHash&0xFF)<<32 | e.Header.PathHash>>32&0xFFFFFFFFFF
+		section := encodeSection(pos, shift, e.Header.PathHash)
 		//slog.Debug("qar write section", "value", fmt.Sprintf("%x", section))
 		sections[i] = section
-		data := []byte{}
-		if len(e.Data) == 0 {
-			pl := ""
-			if pl, err = filepath.Localize(strings.TrimPrefix(e.Header.FilePath, "/")); err != nil {
-				return fmt.Errorf("cannot localize entry path %s: %w", pl, err)
-			}
 
-			p := filepath.Join(baseDir, e.Header.FilePath)
-			if e.Data, err = os.ReadFile(p); err != nil {
-				return fmt.Errorf("read entry data: %w", err)
-			}
-		}
-		if data, err = e.Write(); err != nil {
-			return fmt.Errorf("write entry to array %s: %w", e.Header.FilePath, err)
+		var data []byte
+		if data, err = results.get(i); err != nil {
+			return err
 		}
+
 		if _, err = file.Write(data); err != nil {
 			return fmt.Errorf("write entry %s to file: %w", e.Header.FilePath, err)
 		}
@@ -415,6 +484,10 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 		if _, err = util.AlignWrite(file, int64(alignment)); err != nil {
 			return fmt.Errorf("entry align fail: %w", err)
 		}
+
+		if q.OnWrite != nil {
+			q.OnWrite(&e)
+		}
 	}
 
 	var endPos int64
@@ -428,26 +501,10 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 	}
 
 	q.FileCount = uint32(len(q.Entries))
-
-	// error handling? nah
-	file.Write(magic[:])
-	binary.Write(file, binary.LittleEndian, q.Flags^xorTable[0])
-	binary.Write(file, binary.LittleEndian, q.FileCount^xorTable[1])
-	binary.Write(file, binary.LittleEndian, q.UnknownCount^xorTable[2]) // not used in GzsTool
-	binary.Write(file, binary.LittleEndian, q.BlockFileEnd^xorTable[3])
-	binary.Write(file, binary.LittleEndian, q.OffsetFirstFile^xorTable[0])
-	binary.Write(file, binary.LittleEndian, q.Version^xorTable[0])
-	binary.Write(file, binary.LittleEndian, 0^xorTable[1])
-
-	s, err := q.EncryptSections(sections)
-	if err != nil {
-		return fmt.Errorf("prepare encrypt sections: %w", err)
+	if err = q.writeHeader(file, sections); err != nil {
+		return err
 	}
 
-	//o, _ := file.Seek(0, io.SeekCurrent)
-	//slog.Debug("writing section info at", "offset", o, "len", len(s), "data", fmt.Sprintf("% x", s))
-	binary.Write(file, binary.LittleEndian, s)
-
 	//slog.Debug("qar write end\n========")
 
 	return nil
@@ -488,6 +545,57 @@ func (q *Qar) Write(file io.ReadWriteSeeker, baseDir string, printLog bool) erro
 //            output.Position = endPosition
 //        }
 
+// blockShift returns block alignment in bits.
+func (q *Qar) blockShift() int {
+	if q.Flags&0x800 > 0 {
+		return 12 // 4096
+	}
+
+	return 10 // 1024
+}
+
+func encodeSection(pos int64, shift int, pathHash uint64) uint64 {
+	return uint64((pos>>shift)<<40) | (pathHash&0xFF)<<32 | pathHash>>32&0xFFFFFFFFFF
+}
+
+// writeHeader writes header and section table at the start of file.
+func (q *Qar) writeHeader(file io.WriteSeeker, sections []uint64) error {
+	var err error
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek start: %w", err)
+	}
+
+	if _, err = file.Write(magic[:]); err != nil {
+		return fmt.Errorf("write magic: %w", err)
+	}
+
+	header := []uint32{
+		q.Flags ^ xorTable[0],
+		q.FileCount ^ xorTable[1],
+		q.UnknownCount ^ xorTable[2], // not used in GzsTool
+		q.BlockFileEnd ^ xorTable[3],
+		q.OffsetFirstFile ^ xorTable[0],
+		q.Version ^ xorTable[0],
+		0 ^ xorTable[1],
+	}
+	if err = binary.Write(file, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	s, err := q.EncryptSections(sections)
+	if err != nil {
+		return fmt.Errorf("prepare encrypt sections: %w", err)
+	}
+
+	//o, _ := file.Seek(0, io.SeekCurrent)
+	//slog.Debug("writing section info at", "offset", o, "len", len(s), "data", fmt.Sprintf("% x", s))
+	if err = binary.Write(file, binary.LittleEndian, s); err != nil {
+		return fmt.Errorf("write sections: %w", err)
+	}
+
+	return nil
+}
+
 func (q *Qar) EncryptSections(sections []uint64) ([]byte, error) {
 	out := make([]byte, len(sections)*blockSize)
 	for i, v := range sections {
@@ -519,5 +627,5 @@ func (q *Qar) ReadFile(path string) ([]byte, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("file not found")
+	return nil, &EntryNotFoundError{Hash: ph, Path: path}
 }
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/unknown321/datfpk/crypto"
+	"github.com/unknown321/datfpk/util"
 	"io"
 
 	"github.com/unknown321/hashing"
@@ -28,6 +29,10 @@ type EntryHeader struct {
 	MetaFlag   bool   `json:"meta_flag,omitempty"`
 
 	NameHashForPacking uint64 `json:"hash,omitempty"` // used for packing when name is not resolved
+
+	// set on extraction, entries with unchanged data are packed as is from Qar.Source
+	DataMd5   Md5Sum `json:"md5"`       // md5sum(data)
+	SourceMd5 Md5Sum `json:"sourceMd5"` // Md5Sum of original entry
 }
 
 func (e *EntryHeader) Read(reader io.ReadSeeker, version uint32) error {
@@ -179,12 +184,14 @@ type Entry struct {
 
 func (e *Entry) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		FilePath   string `json:"filePath"`
-		Compressed bool   `json:"compressed,omitempty"`
-		MetaFlag   bool   `json:"metaFlag,omitempty"`
-		Encryption uint32 `json:"encryption,omitempty"`
-		Key        uint32 `json:"key,omitempty"`
-		Hash       uint64 `json:"hash,omitempty"` // used only for files without resolved names
+		FilePath   string  `json:"filePath"`
+		Compressed bool    `json:"compressed,omitempty"`
+		MetaFlag   bool    `json:"metaFlag,omitempty"`
+		Encryption uint32  `json:"encryption,omitempty"`
+		Key        uint32  `json:"key,omitempty"`
+		Hash       uint64  `json:"hash,omitempty"` // used only for files without resolved names
+		Md5        *Md5Sum `json:"md5,omitempty"`
+		SourceMd5  *Md5Sum `json:"sourceMd5,omitempty"`
 	}{
 		FilePath:   e.Header.FilePath,
 		Compressed: e.Header.Compressed,
@@ -192,6 +199,8 @@ func (e *Entry) MarshalJSON() ([]byte, error) {
 		Encryption: e.DataHeader.EncryptionMagic,
 		Key:        e.DataHeader.Key,
 		Hash:       e.Header.NameHashForPacking,
+		Md5:        nonEmptyMd5(e.Header.DataMd5),
+		SourceMd5:  nonEmptyMd5(e.Header.SourceMd5),
 	})
 }
 
@@ -232,6 +241,10 @@ func (e *Entry) Read(reader io.ReadSeeker, version uint32) error {
 		return fmt.Errorf("read header: %w", err)
 	}
 
+	if err = util.CheckSize(reader, int64(e.Header.CompressedSize)); err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
 	d1 := Decrypt1Stream{}
 	d1.Init(e.Header.Md5Sum, e.Header.PathHash, version, 8)
 	dh, err := d1.Read(reader, 8)
@@ -321,75 +334,72 @@ func (e *Entry) Write() ([]byte, error) {
 	return res, nil
 }
 
-func (e *Entry) ReadData(reader io.ReadSeeker) error {
-	_, err := reader.Seek(e.Header.DataOffset, io.SeekStart)
-	//slog.Debug("read data", "off", e.Header.DataOffset)
-	if err != nil {
-		return err
-	}
-	size := int(e.Header.UncompressedSize)
-	if e.Header.CompressedSize > e.Header.UncompressedSize {
-		size = int(e.Header.CompressedSize)
-	}
+type entryReader struct {
+	io.Reader
+	io.Closer
+}
 
-	//slog.Debug("read data",
-	//	"size", size,
-	//	"offset", e.Header.DataOffset,
-	//	"uncompSize", e.Header.UncompressedSize,
-	//	"compSize", e.Header.CompressedSize,
-	//)
+// Open returns a reader of decrypted and decompressed entry data. Data is read from reader on demand,
+// so reader must not be used elsewhere until returned ReadCloser is closed.
+func (e *Entry) Open(reader io.ReadSeeker) (io.ReadCloser, error) {
+	if _, err := reader.Seek(e.Header.DataOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
 
-	d1 := Decrypt1Stream{}
-	d1.Init(e.Header.Md5Sum, e.Header.PathHash, e.Header.Version, size)
-	//o, _ := reader.Seek(0, io.SeekCurrent)
-	//slog.Debug("reading data from", "offset", o)
-	e.Data, err = d1.Read(reader, size)
+	r, err := e.decode(e.decrypt1(reader))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	//md5sum := md5.Sum(e.Data)
-	//if bytes.Compare(e.Header.Md5Sum[:], md5sum[:]) != 0 {
-	//	return fmt.Errorf("md5 mismatch, got %x, want %x", md5sum, e.Header.Md5Sum)
-	//}
-	//slog.Debug("md5 from", "val", fmt.Sprintf("%x", md5sum), "data", fmt.Sprintf("%x", e.Data))
+	if e.Header.Compressed {
+		return &entryReader{Reader: io.LimitReader(r, int64(e.Header.UncompressedSize)), Closer: r}, nil
+	}
+
+	return r, nil
+}
+
+// decrypt1 returns reader of data header and payload with first encryption layer removed
+func (e *Entry) decrypt1(reader io.Reader) io.Reader {
+	// CompressedSize is the size of data on disk, data header included
+	return NewDecrypt1Reader(reader, e.Header.Md5Sum, e.Header.PathHash, e.Header.Version, int(e.Header.CompressedSize))
+}
 
+// decode skips data header and removes second encryption layer and compression from decrypt1 output
+func (e *Entry) decode(r io.Reader) (io.ReadCloser, error) {
 	if e.DataHeader.EncryptionMagic > 0 {
 		headerSize := crypto.GetHeaderSize(e.DataHeader.EncryptionMagic)
-		if _, err = reader.Seek(int64(headerSize), io.SeekCurrent); err != nil {
-			return fmt.Errorf("seek failed: %w", err)
+		if _, err := io.CopyN(io.Discard, r, int64(headerSize)); err != nil {
+			return nil, fmt.Errorf("skip data header: %w", err)
 		}
-		size -= headerSize
-		//slog.Debug("data header", "size", headerSize, "new size", size)
 
-		d2 := Decrypt2Stream{}
-		d2.Init(e.DataHeader.Key)
-		e.Data, err = d2.Read(bytes.NewBuffer(e.Data[headerSize:]), size)
-		if err != nil {
-			return fmt.Errorf("read decrypt2 data: %w", err)
-		}
-		//slog.Debug("read data post d2", "len", len(e.Data))
+		r = NewDecrypt2Reader(r, e.DataHeader.Key, int(e.Header.CompressedSize)-headerSize)
 	}
 
-	if e.Header.Compressed {
-		//slog.Debug("entry compressed", "z", fmt.Sprintf("% x", e.Data), "c", e.Header.CompressedSize, "len", len(e.Data), "u", e.Header.UncompressedSize)
-		var z io.ReadCloser
-		r := bytes.NewReader(e.Data)
-		z, err = zlib.NewReader(r)
+	if !e.Header.Compressed {
+		return io.NopCloser(r), nil
+	}
+
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("create zlib reader: %w", err)
+	}
 
-		defer z.Close()
+	return z, nil
+}
 
-		if err != nil {
-			return fmt.Errorf("create zlib reader: %w", err)
-		}
-		var b bytes.Buffer
+func (e *Entry) ReadData(reader io.ReadSeeker) error {
+	r, err := e.Open(reader)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
 
-		_, err = io.Copy(&b, z)
-		if err != nil {
-			return fmt.Errorf("decompress zlib: %w", err)
-		}
+	if int64(e.Header.UncompressedSize) > util.MaxAllocSize {
+		return fmt.Errorf("read data: %w: %d", util.ErrTooLarge, e.Header.UncompressedSize)
+	}
 
-		e.Data = b.Bytes()[0:e.Header.UncompressedSize]
+	if e.Data, err = io.ReadAll(r); err != nil {
+		return fmt.Errorf("read data: %w", err)
 	}
 
 	return nil
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/unknown321/datfpk/util"
+	"io"
 	"os"
 	"reflect"
 	"testing"
@@ -139,6 +140,63 @@ func TestEntry_ReadCompressed(t *testing.T) {
 	}
 }
 
+func TestEntry_Open(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		expected string
+	}{
+		{
+			name:     "encrypted",
+			filename: "testdata/foxdat",
+			expected: "testdata/foxpatch.dat",
+		},
+		{
+			name:     "compressed",
+			filename: "testdata/plfova_cmf0_main0_def_v00.fpk.compressed",
+			expected: "testdata/plfova_cmf0_main0_def_v00.fpk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := os.ReadFile(tt.expected)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			f, err := os.Open(tt.filename)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			defer f.Close()
+
+			e := Entry{}
+			if err = e.Read(f, 1); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			r, err := e.Open(f)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			defer r.Close()
+
+			b := new(bytes.Buffer)
+			if _, err = io.Copy(b, r); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if e.Data != nil {
+				t.Errorf("data must not be buffered in entry")
+			}
+
+			if bytes.Compare(b.Bytes(), expected) != 0 {
+				t.Fatalf("not equal")
+			}
+		})
+	}
+}
+
 func TestEntry_Write(t *testing.T) {
 	type fields struct {
 		FilePath   string
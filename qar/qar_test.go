@@ -3,10 +3,13 @@ package qar
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/unknown321/datfpk/util"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/unknown321/hashing"
@@ -214,3 +217,481 @@ func TestDecryptSectionList(t *testing.T) {
 		t.Fatalf("not equal")
 	}
 }
+
+func TestQar_ExtractAll(t *testing.T) {
+	q := &Qar{
+		Magic:   magic,
+		Flags:   3150304,
+		Version: 1,
+	}
+
+	for i := 0; i < 32; i++ {
+		q.Entries = append(q.Entries, Entry{
+			Header: EntryHeader{
+				FilePath:   fmt.Sprintf("/Assets/test/dir%d/test%d.lua", i%4, i),
+				Compressed: i%2 == 0,
+			},
+			Data: bytes.Repeat([]byte(fmt.Sprintf("data%d\n", i)), 100+i),
+		})
+	}
+
+	out := &util.ByteArrayReaderWriter{}
+	if err := q.Write(out, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dir := t.TempDir()
+	datPath := filepath.Join(dir, "test.dat")
+	if err := os.WriteFile(datPath, out.Bytes(), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	parallel := &Qar{}
+	if err := parallel.ReadFrom(datPath); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	defer parallel.Close()
+
+	names := map[uint64]string{}
+	for _, e := range q.Entries {
+		names[hashing.HashFileNameWithExtension(e.Header.FilePath)] = e.Header.FilePath
+	}
+	parallel.Resolve(func(hash uint64) (string, bool) {
+		v, ok := names[hash]
+		return v, ok
+	})
+
+	parallelDir := filepath.Join(dir, "parallel")
+	if err := parallel.ExtractAll(parallelDir, 8); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	serialDir := filepath.Join(dir, "serial")
+	for _, e := range parallel.Entries {
+		if _, err := parallel.Extract(e.Header.FilePath, e.Header.PathHash, serialDir); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+	}
+
+	for _, e := range q.Entries {
+		want, err := os.ReadFile(filepath.Join(serialDir, e.Header.FilePath))
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		have, err := os.ReadFile(filepath.Join(parallelDir, e.Header.FilePath))
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if bytes.Compare(have, want) != 0 {
+			t.Errorf("%s: not equal", e.Header.FilePath)
+		}
+
+		if bytes.Compare(have, e.Data) != 0 {
+			t.Errorf("%s: not equal to source", e.Header.FilePath)
+		}
+	}
+}
+
+func TestQar_WriteGolden(t *testing.T) {
+	want, err := os.ReadFile("testdata/written.dat")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	plain, err := os.ReadFile("testdata/plain_dat/test.lua")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	compressed, err := os.ReadFile("testdata/compressed_dat/test.lua")
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, workers := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			q := &Qar{Magic: magic, Flags: 3150304, Version: 1}
+			q.Entries = []Entry{
+				{Header: EntryHeader{FilePath: "/test.lua", Compressed: true}},
+				{Header: EntryHeader{FilePath: "/plain.lua"}, Data: plain},
+				{Header: EntryHeader{FilePath: "/encrypted.lua"}, DataHeader: DataHeader{Key: 0xCAFEBABE}, Data: plain},
+				{Header: EntryHeader{FilePath: "/both.lua", Compressed: true}, DataHeader: DataHeader{Key: 0xCAFEBABF}, Data: compressed},
+			}
+
+			out := &util.ByteArrayReaderWriter{}
+			if err := q.WriteParallel(out, "testdata/compressed_dat", false, workers); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Fatalf("output differs from serial writer")
+			}
+		})
+	}
+}
+
+func TestQar_WriteParallel(t *testing.T) {
+	baseDir := t.TempDir()
+
+	q := &Qar{
+		Magic:   magic,
+		Flags:   3150304,
+		Version: 1,
+	}
+
+	for i := 0; i < 64; i++ {
+		e := Entry{
+			Header: EntryHeader{
+				FilePath:   fmt.Sprintf("/Assets/test/dir%d/test%d.lua", i%4, i),
+				Compressed: i%2 == 0,
+			},
+		}
+
+		if i%3 == 0 {
+			e.DataHeader.Key = 0xCAFEBABE + uint32(i)
+		}
+
+		data := bytes.Repeat([]byte(fmt.Sprintf("data%d\n", i)), 100+i*10)
+		if i%5 == 0 {
+			// read from disk during packing
+			p := filepath.Join(baseDir, e.Header.FilePath)
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			if err := os.WriteFile(p, data, 0644); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+		} else {
+			e.Data = data
+		}
+
+		q.Entries = append(q.Entries, e)
+	}
+
+	serial := &util.ByteArrayReaderWriter{}
+	if err := q.Write(serial, baseDir, false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for _, workers := range []int{2, 8, 0} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			parallel := &util.ByteArrayReaderWriter{}
+			if err := q.WriteParallel(parallel, baseDir, false, workers); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if bytes.Compare(serial.Bytes(), parallel.Bytes()) != 0 {
+				t.Fatalf("parallel output differs from serial")
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		broken := *q
+		broken.Entries = append([]Entry{}, q.Entries...)
+		broken.Entries[10].Data = nil
+		broken.Entries[10].Header.FilePath = "/Assets/test/missing.lua"
+
+		out := &util.ByteArrayReaderWriter{}
+		if err := broken.WriteParallel(out, baseDir, false, 4); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestQar_WritePassthrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		modify   string // entry to change after extraction
+	}{
+		{name: "plain", filename: "plain.dat"},
+		{name: "compressed", filename: "compressed.dat"},
+		{name: "generated"},
+		{name: "generated modified", modify: "/Assets/test/test3.lua"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			datPath := filepath.Join(dir, "test.dat")
+
+			if tt.filename != "" {
+				data, err := os.ReadFile(filepath.Join(dataDir, tt.filename))
+				if err != nil {
+					t.Fatalf("%s", err.Error())
+				}
+				if err = os.WriteFile(datPath, data, 0644); err != nil {
+					t.Fatalf("%s", err.Error())
+				}
+			} else {
+				_, file, _ := patchTestQar(t, 8)
+				if err := os.WriteFile(datPath, file.Bytes(), 0644); err != nil {
+					t.Fatalf("%s", err.Error())
+				}
+			}
+
+			source := &Qar{}
+			if err := source.ReadFrom(datPath); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			defer source.Close()
+
+			names := map[uint64]string{}
+			for i := 0; i < 8; i++ {
+				p := fmt.Sprintf("/Assets/test/test%d.lua", i)
+				names[hashing.HashFileNameWithExtension(p)] = p
+			}
+			dict := hashing.Dictionary{}
+			source.Resolve(func(hash uint64) (string, bool) {
+				if v, ok := names[hash]; ok {
+					return v, ok
+				}
+				return dict.GetByHash(hash)
+			})
+
+			outDir := filepath.Join(dir, "out")
+			if err := source.ExtractAll(outDir, 2); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if tt.modify != "" {
+				if err := os.WriteFile(filepath.Join(outDir, tt.modify), []byte("modified"), 0644); err != nil {
+					t.Fatalf("%s", err.Error())
+				}
+			}
+
+			def := &bytes.Buffer{}
+			if err := source.SaveDefinition(def); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			q := &Qar{}
+			if err := json.Unmarshal(def.Bytes(), q); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			if q.SourcePath != "test.dat" {
+				t.Errorf("source path: have %q", q.SourcePath)
+			}
+			q.Source = source
+
+			out := &util.ByteArrayReaderWriter{}
+			if err := q.WriteParallel(out, outDir, false, 4); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			original, _ := os.ReadFile(datPath)
+			same := bytes.Equal(out.Bytes(), original)
+			if same != (tt.modify == "") {
+				t.Errorf("byte-identical: have %t, want %t", same, tt.modify == "")
+			}
+
+			written := &Qar{}
+			if err := written.Read(util.NewByteArrayReaderWriter(out.Bytes())); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			report, err := written.Verify()
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			if !report.OK() {
+				t.Errorf("unexpected corrupt entries: %+v", report.Corrupt)
+			}
+		})
+	}
+}
+
+func TestQar_Resolve(t *testing.T) {
+	q := &Qar{Entries: []Entry{
+		{Header: EntryHeader{PathHash: 1}},
+		{Header: EntryHeader{PathHash: 2}},
+		{Header: EntryHeader{PathHash: 3}},
+	}}
+
+	names := map[uint64]string{1: "/Assets/a.lua", 2: "/Assets/b.lua", 3: "/Assets/a.lua"}
+	resolver := func(hash uint64) (string, bool) {
+		v, ok := names[hash]
+		return v, ok
+	}
+
+	err := q.Resolve(resolver)
+	var de *DuplicatePathError
+	if !errors.As(err, &de) {
+		t.Fatalf("want DuplicatePathError, got %v", err)
+	}
+
+	if de.Path != "/Assets/a.lua" || !slices.Equal(de.Hashes, []uint64{1, 3}) {
+		t.Fatalf("bad error %+v", de)
+	}
+
+	if q.Entries[0].Header.FilePath != "/Assets/a.lua" || q.Entries[0].Header.NameHashForPacking != 0 {
+		t.Fatalf("first entry does not keep path, %+v", q.Entries[0].Header)
+	}
+
+	if q.Entries[2].Header.FilePath != "/Assets/a_3.lua" || q.Entries[2].Header.NameHashForPacking != 3 {
+		t.Fatalf("duplicate entry is not renamed, %+v", q.Entries[2].Header)
+	}
+
+	names[3] = "/Assets/c.lua"
+	if err = q.Resolve(resolver); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+}
+
+func TestQar_ExtractFiltered(t *testing.T) {
+	_, file, want := patchTestQar(t, 8)
+
+	dir := t.TempDir()
+	datPath := filepath.Join(dir, "test.dat")
+	if err := os.WriteFile(datPath, file.Bytes(), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	q := &Qar{}
+	if err := q.ReadFrom(datPath); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+	defer q.Close()
+
+	q.Resolve(func(hash uint64) (string, bool) {
+		for k := range want {
+			if hashing.HashFileNameWithExtension(k) == hash {
+				return k, true
+			}
+		}
+		return "", false
+	})
+
+	filter, err := util.NewFilter(
+		[]string{"/Assets/test/test[0-3].lua"},
+		[]string{"test2.lua"},
+		[]uint64{hashing.PathHashFromHash(hashing.HashFileNameWithExtension("/Assets/test/test7.lua"))},
+	)
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	outDir := filepath.Join(dir, "out")
+	if err = q.ExtractFiltered(outDir, 2, filter); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	selected := []string{"/Assets/test/test0.lua", "/Assets/test/test1.lua", "/Assets/test/test3.lua", "/Assets/test/test7.lua"}
+	for k, v := range want {
+		data, err := os.ReadFile(filepath.Join(outDir, k))
+		isSelected := slices.Contains(selected, k)
+		if isSelected != (err == nil) {
+			t.Errorf("%s: extracted %t, want %t", k, err == nil, isSelected)
+			continue
+		}
+
+		if isSelected && !bytes.Equal(data, v) {
+			t.Errorf("%s: data mismatch", k)
+		}
+	}
+
+	subset := q.Subset(filter)
+	if len(subset.Entries) != len(selected) {
+		t.Fatalf("subset: have %d entries, want %d", len(subset.Entries), len(selected))
+	}
+
+	for i, e := range subset.Entries {
+		if e.Header.FilePath != selected[i] {
+			t.Errorf("subset entry %d: have %s, want %s", i, e.Header.FilePath, selected[i])
+		}
+
+		if e.Header.DataMd5.Empty() {
+			t.Errorf("subset entry %s: no md5", e.Header.FilePath)
+		}
+	}
+
+	if len(q.Entries) != 8 {
+		t.Errorf("original archive modified")
+	}
+}
+
+func TestQar_ReadErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(dataDir, "plain.dat"))
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	badMagic := slices.Clone(data)
+	badMagic[0] ^= 0xFF
+
+	badVersion := slices.Clone(data)
+	badVersion[24] ^= 0x10
+
+	badFileCount := slices.Clone(data)
+	badFileCount[11] ^= 0x7F
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "bad magic", data: badMagic, want: ErrBadMagic},
+		{name: "unsupported version", data: badVersion, want: ErrUnsupportedVersion},
+		{name: "file count", data: badFileCount, want: util.ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Qar{}
+			if err := q.Read(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("want %v, have %v", tt.want, err)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		q := Qar{}
+		if err = q.Read(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		name := "/missing.lua"
+		hash := hashing.HashFileNameWithExtension(name)
+		_, err = q.ExtractTo(name, hash, new(bytes.Buffer))
+
+		var notFound *EntryNotFoundError
+		if !errors.As(err, &notFound) || notFound.Hash != hash {
+			t.Errorf("want *EntryNotFoundError with hash %x, have %v", hash, err)
+		}
+
+		var corrupt *CorruptEntryError
+		if errors.As(err, &corrupt) {
+			t.Errorf("missing entry reported as corrupt: %v", err)
+		}
+	})
+}
+
+func FuzzQar_Read(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join(dataDir, "*.dat"))
+	if err != nil {
+		f.Fatalf("%s", err.Error())
+	}
+
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatalf("%s", err.Error())
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		q := Qar{}
+		r := bytes.NewReader(data)
+		if err := q.Read(r); err != nil {
+			return
+		}
+
+		for _, e := range q.Entries {
+			_ = e.ReadData(r)
+		}
+	})
+}
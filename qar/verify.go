@@ -0,0 +1,149 @@
+package qar
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+
+	"github.com/unknown321/datfpk/crypto"
+)
+
+type VerifyProblem string
+
+const (
+	ProblemRead         VerifyProblem = "read failed"
+	ProblemMd5Mismatch  VerifyProblem = "md5 mismatch"
+	ProblemDecompress   VerifyProblem = "decompression failed"
+	ProblemSizeMismatch VerifyProblem = "size mismatch"
+)
+
+// EntryVerification is a verification result of a single entry.
+type EntryVerification struct {
+	Path   string `json:"path"`
+	Hash   uint64 `json:"hash"`
+	Offset int64  `json:"offset"`
+
+	StoredMd5   Md5Sum `json:"storedMd5"`
+	ComputedMd5 Md5Sum `json:"computedMd5"`
+	// Md5OfDecompressed is set if stored md5 sum matches decompressed data instead of stored one,
+	// GzsTool writes compressed entries this way
+	Md5OfDecompressed bool `json:"md5OfDecompressed,omitempty"`
+
+	// ExpectedSize is the size of decrypted and decompressed data according to entry header
+	ExpectedSize int64 `json:"expectedSize"`
+	ActualSize   int64 `json:"actualSize"`
+
+	Problems []VerifyProblem `json:"problems,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+func (v *EntryVerification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+type VerifyReport struct {
+	FilePath string              `json:"filePath,omitempty"`
+	Entries  int                 `json:"entries"`
+	Corrupt  []EntryVerification `json:"corrupt"`
+	// DecompressedMd5 holds intact entries with md5 sum of decompressed data, see EntryVerification.Md5OfDecompressed
+	DecompressedMd5 []EntryVerification `json:"decompressedMd5"`
+}
+
+func (r *VerifyReport) OK() bool {
+	return len(r.Corrupt) == 0
+}
+
+// Verify checks md5 sum and size of every entry. Only I/O errors on the archive itself are returned as error,
+// damaged entries are listed in report.
+func (q *Qar) Verify() (*VerifyReport, error) {
+	if q.handle == nil {
+		return nil, fmt.Errorf("qar is not opened")
+	}
+
+	report := &VerifyReport{
+		FilePath: q.FilePath,
+		Entries:  len(q.Entries),
+		Corrupt:  make([]EntryVerification, 0),
+
+		DecompressedMd5: make([]EntryVerification, 0),
+	}
+
+	for i := range q.Entries {
+		v, err := q.Entries[i].Verify(q.handle)
+		if err != nil {
+			return nil, fmt.Errorf("verify %s: %w", q.Entries[i].Header.FilePath, err)
+		}
+
+		switch {
+		case !v.OK():
+			report.Corrupt = append(report.Corrupt, v)
+		case v.Md5OfDecompressed:
+			report.DecompressedMd5 = append(report.DecompressedMd5, v)
+		}
+	}
+
+	return report, nil
+}
+
+// Verify recomputes md5 sum of entry data and compares it with the stored one,
+// compressed data must inflate to the size stored in header.
+//
+// Md5 sum covers data header and payload as stored. Compressed entries made by GzsTool are hashed
+// before compression instead (see testdata/compressed.dat), such entries have Md5OfDecompressed set.
+func (e *Entry) Verify(reader io.ReadSeeker) (EntryVerification, error) {
+	v := EntryVerification{
+		Path:         e.Header.FilePath,
+		Hash:         e.Header.PathHash,
+		Offset:       e.Header.DataOffset,
+		StoredMd5:    e.Header.Md5Sum,
+		ExpectedSize: int64(e.Header.UncompressedSize) - int64(crypto.GetHeaderSize(e.DataHeader.EncryptionMagic)),
+	}
+
+	if _, err := reader.Seek(e.Header.DataOffset, io.SeekStart); err != nil {
+		return v, err
+	}
+
+	stored := md5.New()
+	decompressed := md5.New()
+	raw := io.TeeReader(e.decrypt1(reader), stored)
+
+	r, err := e.decode(raw)
+	if err == nil {
+		v.ActualSize, err = io.Copy(decompressed, r)
+		_ = r.Close()
+	}
+
+	if err != nil {
+		v.Error = err.Error()
+		if e.Header.Compressed {
+			v.Problems = append(v.Problems, ProblemDecompress)
+		} else {
+			v.Problems = append(v.Problems, ProblemRead)
+		}
+	}
+
+	// zlib stream may end before data does
+	if _, err = io.Copy(io.Discard, raw); err != nil {
+		if v.Error == "" {
+			v.Error = err.Error()
+			v.Problems = append(v.Problems, ProblemRead)
+		}
+		return v, nil
+	}
+
+	copy(v.ComputedMd5[:], stored.Sum(nil))
+	if e.Header.Compressed && v.Error == "" && v.ComputedMd5 != v.StoredMd5 && Md5Sum(decompressed.Sum(nil)) == v.StoredMd5 {
+		v.ComputedMd5 = v.StoredMd5
+		v.Md5OfDecompressed = true
+	}
+
+	if v.ComputedMd5 != v.StoredMd5 {
+		v.Problems = append(v.Problems, ProblemMd5Mismatch)
+	}
+
+	if v.Error == "" && v.ActualSize != v.ExpectedSize {
+		v.Problems = append(v.Problems, ProblemSizeMismatch)
+	}
+
+	return v, nil
+}
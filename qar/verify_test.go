@@ -0,0 +1,150 @@
+package qar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/unknown321/datfpk/util"
+)
+
+func TestQar_Verify(t *testing.T) {
+	// compressed.dat is made by GzsTool, its compressed entry has md5 sum of decompressed data
+	for name, decompressedMd5 := range map[string]int{"plain.dat": 0, "compressed.dat": 1} {
+		t.Run(name, func(t *testing.T) {
+			q := Qar{}
+			if err := q.ReadFrom(filepath.Join(dataDir, name)); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+			defer q.Close()
+
+			report, err := q.Verify()
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !report.OK() {
+				t.Errorf("unexpected corrupt entries: %+v", report.Corrupt)
+			}
+
+			if len(report.DecompressedMd5) != decompressedMd5 {
+				t.Errorf("entries with md5 of decompressed data: have %d, want %d", len(report.DecompressedMd5), decompressedMd5)
+			}
+		})
+	}
+}
+
+func TestEntry_Verify(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		corrupt  int64 // offset of byte to flip, 0 for none
+		want     []VerifyProblem
+
+		md5OfDecompressed bool
+	}{
+		{
+			name:     "encrypted",
+			filename: "testdata/foxdat",
+		},
+		{
+			name:     "compressed",
+			filename: "testdata/plfova_cmf0_main0_def_v00.fpk.compressed",
+
+			md5OfDecompressed: true,
+		},
+		{
+			name:     "encrypted corrupt",
+			filename: "testdata/foxdat",
+			corrupt:  HeaderSize + 100,
+			want:     []VerifyProblem{ProblemMd5Mismatch},
+		},
+		{
+			name:     "compressed corrupt",
+			filename: "testdata/plfova_cmf0_main0_def_v00.fpk.compressed",
+			corrupt:  HeaderSize + 20,
+			want:     []VerifyProblem{ProblemDecompress, ProblemMd5Mismatch},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(tt.filename)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if tt.corrupt > 0 {
+				data[tt.corrupt] ^= 0xFF
+			}
+
+			reader := util.NewByteArrayReaderWriter(data)
+			e := Entry{}
+			if err = e.Read(reader, 1); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			v, err := e.Verify(reader)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if !slices.Equal(v.Problems, tt.want) {
+				t.Errorf("problems: have %v, want %v (%s)", v.Problems, tt.want, v.Error)
+			}
+
+			if v.Md5OfDecompressed != tt.md5OfDecompressed {
+				t.Errorf("md5 of decompressed data: have %t, want %t", v.Md5OfDecompressed, tt.md5OfDecompressed)
+			}
+		})
+	}
+}
+
+func TestQar_VerifyWritten(t *testing.T) {
+	q := &Qar{
+		Magic:   magic,
+		Flags:   3150304,
+		Version: 1,
+	}
+
+	for i := 0; i < 8; i++ {
+		e := Entry{
+			Header: EntryHeader{
+				FilePath:   fmt.Sprintf("/Assets/test/test%d.lua", i),
+				Compressed: i%2 == 0,
+			},
+			Data: []byte(fmt.Sprintf("some data %d for testing, some data %d for testing\n", i, i)),
+		}
+
+		if i%3 == 0 {
+			e.DataHeader.Key = 0xCAFEBABE
+		}
+
+		q.Entries = append(q.Entries, e)
+	}
+
+	out := &util.ByteArrayReaderWriter{}
+	if err := q.Write(out, "", false); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	written := &Qar{}
+	if err := written.Read(util.NewByteArrayReaderWriter(out.Bytes())); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	report, err := written.Verify()
+	if err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !report.OK() {
+		t.Errorf("unexpected corrupt entries: %+v", report.Corrupt)
+	}
+
+	// written entries are hashed as stored
+	if len(report.DecompressedMd5) > 0 {
+		t.Errorf("unexpected entries with md5 of decompressed data: %+v", report.DecompressedMd5)
+	}
+}
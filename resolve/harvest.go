@@ -0,0 +1,190 @@
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/unknown321/datfpk/detect"
+	"github.com/unknown321/datfpk/fox2"
+	"github.com/unknown321/datfpk/fox2/datatypes/fox"
+	"github.com/unknown321/datfpk/fpk"
+)
+
+// Kind of harvested string
+type Kind int
+
+const (
+	// Path is a path-like string: fox2 Path and FilePtr value, fpk entry or reference, path in lua or text file
+	Path Kind = iota
+	// Literal is a fox2 string literal, such as class or property name, hashed with StrCode64
+	Literal
+)
+
+// textExtensions are files searched for paths by Walk, besides supported formats
+var textExtensions = []string{".lua", ".xml", ".json", ".txt"}
+
+// maxTextSize skips huge text files
+const maxTextSize = 64 << 20
+
+// pathPattern matches strings with at least one slash, such as /Assets/tpp/pack/player/fova/plfova_cmf0_main0_def_v00.fpk
+var pathPattern = regexp.MustCompile(`[A-Za-z0-9_\-.]*(?:/[A-Za-z0-9_\-.]+)+`)
+
+// Walk calls fn for strings found in files of dir: fox2 files and their xml, fpk/fpkd files and their definitions,
+// lua, xml, json and txt files. Unreadable files are skipped.
+func Walk(dir string, fn func(s string, kind Kind)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		format, err := detect.File(path)
+		if err != nil {
+			slog.Warn("harvest", "path", path, "error", err.Error())
+			return nil
+		}
+
+		switch format {
+		case detect.Fox2, detect.Fox2XML:
+			err = walkFox2(path, format, fn)
+		case detect.Fpk, detect.Fpkd, detect.FpkDefinition, detect.FpkdDefinition:
+			err = walkFpk(path, format, fn)
+		default:
+			if slices.Contains(textExtensions, strings.ToLower(filepath.Ext(path))) {
+				err = walkText(path, fn)
+			}
+		}
+
+		if err != nil {
+			slog.Warn("harvest", "path", path, "error", err.Error())
+		}
+
+		return nil
+	})
+}
+
+func walkFox2(path string, format detect.Format, fn func(s string, kind Kind)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	f := fox2.Fox2{}
+	if format == detect.Fox2 {
+		if err = f.Read(bytes.NewReader(data)); err != nil {
+			return err
+		}
+
+		for _, s := range f.StringLookupLiterals {
+			fn(s.Literal, Literal)
+		}
+	} else {
+		if err = f.FromXML(bytes.NewReader(data)); err != nil {
+			return err
+		}
+
+		for _, e := range f.Entities {
+			for _, s := range e.GetStrings() {
+				if s != "" && !fox.IsUnresolvedName(s) {
+					fn(s, Literal)
+				}
+			}
+		}
+	}
+
+	for _, e := range f.Entities {
+		for _, props := range [][]fox2.Property{e.StaticProperties, e.DynamicProperties} {
+			for _, p := range props {
+				if p.Header.DataType != fox.FPath && p.Header.DataType != fox.FFilePtr {
+					continue
+				}
+
+				for _, s := range p.Value.GetStrings() {
+					if s != "" {
+						fn(s, Path)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func walkFpk(path string, format detect.Format, fn func(s string, kind Kind)) error {
+	f := &fpk.Fpk{}
+	if format.IsDefinition() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err = json.Unmarshal(data, f); err != nil {
+			return err
+		}
+	} else {
+		defer f.Close()
+		if err := f.ReadFrom(path, false); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range f.Entries {
+		fn(e.FilePath.Data, Path)
+	}
+
+	for _, r := range f.References {
+		fn(r.FilePath.Data, Path)
+	}
+
+	return nil
+}
+
+func walkText(path string, fn func(s string, kind Kind)) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxTextSize {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pathPattern.FindAll(data, -1) {
+		fn(string(m), Path)
+	}
+
+	return nil
+}
+
+// Harvest tries every string found by Walk in files of dir.
+func (r *Resolver) Harvest(dir string) error {
+	return Walk(dir, func(s string, _ Kind) {
+		r.TryString(s)
+	})
+}
+
+// TryString tries string as path, relative paths are tried with / and /Assets/ prefixes.
+func (r *Resolver) TryString(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	if strings.HasPrefix(s, "/") {
+		return r.Try(s)
+	}
+
+	res := r.Try("/" + s)
+	return r.Try("/Assets/"+s) || res
+}
@@ -0,0 +1,145 @@
+// Package resolve recovers paths of dat/qar entries missing from dictionary by trying candidate paths
+// generated from templates or harvested from extracted files.
+package resolve
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/datfpk/qar"
+
+	"github.com/unknown321/hashing"
+)
+
+// Resolver matches candidate paths against hashes of unresolved entries.
+type Resolver struct {
+	targets    map[uint64]bool   // entry hash -> found
+	extensions []string          // extensions of unresolved entries
+	found      map[uint64]string // entry hash -> path without extension
+
+	// Tried is a number of checked candidates
+	Tried int
+}
+
+// New returns resolver looking for paths of entry hashes.
+func New(hashes []uint64) *Resolver {
+	r := &Resolver{
+		targets: make(map[uint64]bool, len(hashes)),
+		found:   make(map[uint64]string),
+	}
+
+	for _, h := range hashes {
+		r.targets[h] = false
+		ext, ok := hashing.ExtensionsByHash[hashing.ExtHashFromHash(h)]
+		if ok && !slices.Contains(r.extensions, ext) {
+			r.extensions = append(r.extensions, ext)
+		}
+	}
+
+	return r
+}
+
+// Unresolved returns hashes of entries missing from names. Only entries keeping their hash for packing are
+// checked, see qar.Qar.Resolve.
+func Unresolved(q *qar.Qar, names qar.Resolver) []uint64 {
+	var res []uint64
+	for _, e := range q.Entries {
+		if e.Header.NameHashForPacking == 0 {
+			continue
+		}
+
+		if _, ok := names(e.Header.NameHashForPacking); !ok {
+			res = append(res, e.Header.NameHashForPacking)
+		}
+	}
+
+	return res
+}
+
+// Try checks path with every extension of unresolved entries, extension of path is ignored.
+// Returns true if path belongs to one of entries.
+func (r *Resolver) Try(path string) bool {
+	r.Tried++
+
+	base := trimExtension(path)
+	if !dictionary.PathCode64.CanHash(base) {
+		return false
+	}
+
+	// same as hashing.Dictionary.Read does for dictionary lines
+	h := hashing.HashFileName(base, true)
+	res := false
+	for _, ext := range r.extensions {
+		full := hashing.JustAddExtension(h, ext)
+		if found, ok := r.targets[full]; ok && !found {
+			r.targets[full] = true
+			r.found[full] = base
+			res = true
+		}
+	}
+
+	return res
+}
+
+// Done is true when every entry is resolved.
+func (r *Resolver) Done() bool {
+	return len(r.found) == len(r.targets)
+}
+
+// Found returns recovered paths with extensions by entry hash.
+func (r *Resolver) Found() map[uint64]string {
+	res := make(map[uint64]string, len(r.found))
+	for h, base := range r.found {
+		res[h] = base + "." + hashing.ExtensionsByHash[hashing.ExtHashFromHash(h)]
+	}
+
+	return res
+}
+
+// Lookup returns qar.Resolver using recovered paths first, then fallback.
+func (r *Resolver) Lookup(fallback qar.Resolver) qar.Resolver {
+	found := r.Found()
+	return func(hash uint64) (string, bool) {
+		if name, ok := found[hash]; ok {
+			return name, true
+		}
+
+		return fallback(hash)
+	}
+}
+
+// Delta returns recovered paths in qar dictionary format: sorted, without extension.
+func (r *Resolver) Delta() []string {
+	var lines []string
+	for _, base := range r.found {
+		lines = append(lines, base)
+	}
+	slices.Sort(lines)
+
+	return slices.Compact(lines)
+}
+
+// WriteDelta writes Delta, one path per line.
+func (r *Resolver) WriteDelta(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, l := range r.Delta() {
+		if _, err := fmt.Fprintln(bw, l); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// trimExtension removes extension the same way hashing.HashFileName does
+func trimExtension(path string) string {
+	if i := strings.LastIndex(path, "."); i > strings.LastIndex(path, "/")+1 {
+		return path[:i]
+	}
+
+	return path
+}
@@ -0,0 +1,158 @@
+package resolve
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unknown321/datfpk/dictionary"
+	"github.com/unknown321/hashing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "plain", template: "/a/b.lua", want: []string{"/a/b.lua"}},
+		{name: "digits", template: "/a{N}", want: []string{"/a0", "/a1", "/a2", "/a3", "/a4", "/a5", "/a6", "/a7", "/a8", "/a9"}},
+		{name: "alternatives", template: "/{a|b}/{c|}.lua", want: []string{"/a/c.lua", "/a/.lua", "/b/c.lua", "/b/.lua"}},
+		{name: "unclosed", template: "/a{NN", wantErr: true},
+		{name: "unexpected }", template: "/a}{NN}", wantErr: true},
+		{name: "nested", template: "/a{{N}}", wantErr: true},
+		{name: "too many digits", template: "/a{NNNNNNN}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var have []string
+			err := Expand(tt.template, func(path string) { have = append(have, path) })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !slices.Equal(have, tt.want) {
+				t.Errorf("want %v, have %v", tt.want, have)
+			}
+		})
+	}
+
+	count := 0
+	if err := Expand("/Assets/tpp/pack/mission2/free/f30010/f30010_area{NN}.fpkd", func(string) { count++ }); err != nil || count != 100 {
+		t.Errorf("want 100 paths, have %d, %v", count, err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	paths := []string{
+		"/Assets/tpp/pack/mission2/free/f30010/f30010_area07.fpkd",
+		"/Assets/tpp/pack/mission2/free/f30010/f30010_area07.fpk",
+		"/Assets/tpp/level_asset/weapon/ParameterTables/EquipIdTable.lua",
+	}
+
+	var hashes []uint64
+	for _, p := range paths {
+		hashes = append(hashes, hashing.HashFileNameWithExtension(p))
+	}
+
+	r := New(hashes)
+	if err := r.Template("/Assets/tpp/pack/mission2/free/f30010/f30010_area{NN}.fpkd"); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if r.Tried != 100 || r.Done() {
+		t.Fatalf("unexpected state, tried %d, found %v", r.Tried, r.Found())
+	}
+
+	dir := t.TempDir()
+	lua := []byte(`local path = "Assets/tpp/level_asset/weapon/ParameterTables/EquipIdTable.lua"`)
+	if err := os.WriteFile(filepath.Join(dir, "script.lua"), lua, 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if err := r.Harvest(dir); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !r.Done() {
+		t.Fatalf("want all resolved, have %v", r.Found())
+	}
+
+	found := r.Found()
+	for i, h := range hashes {
+		if found[h] != paths[i] {
+			t.Errorf("want %s, have %s", paths[i], found[h])
+		}
+	}
+
+	delta := &bytes.Buffer{}
+	if err := r.WriteDelta(delta); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	dict := hashing.Dictionary{}
+	if err := dict.Read(delta); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	for i, h := range hashes {
+		if name, ok := dict.GetByHash(h); !ok || name != paths[i] {
+			t.Errorf("delta: want %s, have %s", paths[i], name)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"../fox2/testdata/game/player2_add_parts_prqst_x1.fox2", "../fpk/testdata/title.fpkd"} {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, filepath.Base(f)), data, 0644); err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "readme.md"), []byte("/Assets/not/searched.lua"), 0644); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	have := map[Kind][]string{}
+	if err := Walk(dir, func(s string, kind Kind) { have[kind] = append(have[kind], s) }); err != nil {
+		t.Fatalf("%s", err.Error())
+	}
+
+	if !slices.Contains(have[Literal], "TexturePackLoadConditioner") {
+		t.Errorf("want fox2 literal, have %v", have[Literal])
+	}
+
+	if len(have[Path]) == 0 {
+		t.Errorf("want paths, have none")
+	}
+
+	for _, s := range have[Path] {
+		if strings.Contains(s, "searched") {
+			t.Errorf("unexpected path from unsupported file %s", s)
+		}
+	}
+}
+
+func TestResolver_LongPath(t *testing.T) {
+	// /Assets/ prefix and extension are not hashed
+	long := "/Assets/tpp/" + strings.Repeat("a", dictionary.MaxHashLength-4) + ".lua"
+	r := New([]uint64{hashing.HashFileNameWithExtension(long)})
+	if r.Try("/Assets/tpp/" + strings.Repeat("a", dictionary.MaxHashLength-3) + ".lua") {
+		t.Fatalf("too long path is resolved")
+	}
+
+	if !r.Try(long) {
+		t.Fatalf("path is not resolved")
+	}
+}
@@ -0,0 +1,111 @@
+package resolve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxDigits limits {N...} placeholder, {NNNNNN} is already a million candidates
+const maxDigits = 6
+
+// part of template, either alternatives or zero-padded number of digits
+type part struct {
+	alternatives []string
+	digits       int
+}
+
+func parseTemplate(template string) ([]part, error) {
+	var parts []part
+	for template != "" {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			start = len(template)
+		}
+
+		if strings.Contains(template[:start], "}") {
+			return nil, fmt.Errorf("unexpected }")
+		}
+
+		if start > 0 {
+			parts = append(parts, part{alternatives: []string{template[:start]}})
+		}
+
+		if start == len(template) {
+			break
+		}
+
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed {")
+		}
+		end += start
+
+		inner := template[start+1 : end]
+		switch {
+		case strings.Contains(inner, "{"):
+			return nil, fmt.Errorf("nested {")
+		case inner != "" && strings.Trim(inner, "N") == "":
+			if len(inner) > maxDigits {
+				return nil, fmt.Errorf("too many digits in {%s}, max %d", inner, maxDigits)
+			}
+			parts = append(parts, part{digits: len(inner)})
+		default:
+			parts = append(parts, part{alternatives: strings.Split(inner, "|")})
+		}
+
+		template = template[end+1:]
+	}
+
+	return parts, nil
+}
+
+// Expand calls fn for every path produced by template. Placeholders:
+//
+//	{NN}    zero-padded decimal numbers, width is a count of N: {NN} is 00-99
+//	{a|b|c} alternatives
+//
+// Example: /Assets/tpp/pack/mission2/free/f30010/f30010_area{NN}.fpkd
+func Expand(template string, fn func(path string)) error {
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", template, err)
+	}
+
+	expand(parts, nil, fn)
+
+	return nil
+}
+
+func expand(parts []part, prefix []byte, fn func(path string)) {
+	if len(parts) == 0 {
+		fn(string(prefix))
+		return
+	}
+
+	p := parts[0]
+	if p.digits == 0 {
+		for _, a := range p.alternatives {
+			expand(parts[1:], append(prefix, a...), fn)
+		}
+		return
+	}
+
+	limit := 1
+	for range p.digits {
+		limit *= 10
+	}
+
+	for i := 0; i < limit; i++ {
+		n := strconv.Itoa(i)
+		b := append(prefix, strings.Repeat("0", p.digits-len(n))...)
+		expand(parts[1:], append(b, n...), fn)
+	}
+}
+
+// Template tries every path produced by template, see Expand.
+func (r *Resolver) Template(template string) error {
+	return Expand(template, func(path string) {
+		r.Try(path)
+	})
+}
@@ -0,0 +1,13 @@
+package util
+
+import (
+	"errors"
+)
+
+// Format-independent errors, sentinel errors of qar, fpk, fox2 and lng wrap them.
+var (
+	// ErrBadMagic is returned when data does not start with magic of expected format.
+	ErrBadMagic = errors.New("bad magic")
+	// ErrUnsupportedVersion is returned for known format of unknown version.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+)
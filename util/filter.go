@@ -0,0 +1,140 @@
+package util
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unknown321/hashing"
+)
+
+// Filter selects archive entries by path globs and hashes.
+// Entry is selected if it matches any include pattern or hash (everything is included if there are none)
+// and does not match any exclude pattern.
+//
+// Patterns without slash are matched against file name, others against full path with leading slash
+// being optional. `*` and `?` do not match slash, `**` matches any number of directories.
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	hashes  map[uint64]bool
+}
+
+func NewFilter(include []string, exclude []string, hashes []uint64) (*Filter, error) {
+	f := &Filter{hashes: map[uint64]bool{}}
+
+	var err error
+	var r *regexp.Regexp
+	for _, v := range include {
+		if r, err = compileGlob(v); err != nil {
+			return nil, fmt.Errorf("include %s: %w", v, err)
+		}
+		f.include = append(f.include, r)
+	}
+
+	for _, v := range exclude {
+		if r, err = compileGlob(v); err != nil {
+			return nil, fmt.Errorf("exclude %s: %w", v, err)
+		}
+		f.exclude = append(f.exclude, r)
+	}
+
+	for _, v := range hashes {
+		f.hashes[v] = true
+	}
+
+	return f, nil
+}
+
+// ParseHash parses hash in hex with optional 0x prefix.
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 64)
+}
+
+// Empty is true if filter selects everything, nil filter is empty.
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.include) == 0 && len(f.exclude) == 0 && len(f.hashes) == 0)
+}
+
+// Match reports whether entry with given path and hash is selected.
+// Hash also matches if it is a path hash without extension (38dd243657e7f in 38dd243657e7f.lua).
+func (f *Filter) Match(entryPath string, hash uint64) bool {
+	if f.Empty() {
+		return true
+	}
+
+	for _, v := range f.exclude {
+		if matchGlob(v, entryPath) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 && len(f.hashes) == 0 {
+		return true
+	}
+
+	if f.hashes[hash] || f.hashes[hashing.PathHashFromHash(hash)] {
+		return true
+	}
+
+	for _, v := range f.include {
+		if matchGlob(v, entryPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchGlob(r *regexp.Regexp, entryPath string) bool {
+	return r.MatchString(strings.TrimPrefix(entryPath, "/"))
+}
+
+// compileGlob converts glob to regexp matching path without leading slash
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(glob, "/")
+	if _, err := path.Match(strings.ReplaceAll(glob, "**", "*"), ""); err != nil {
+		return nil, err
+	}
+
+	b := strings.Builder{}
+	b.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/unknown321/hashing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	fpkdHash := hashing.HashFileNameWithExtension("/Assets/tpp/pack/mission2/free/f30010/f30010.fpkd")
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		hashes  []uint64
+		path    string
+		hash    uint64
+		want    bool
+	}{
+		{name: "empty", path: "/Assets/a.lua", want: true},
+		{name: "double star", include: []string{"/Assets/tpp/pack/**/*.fpkd"}, path: "/Assets/tpp/pack/mission2/free/f30010/f30010.fpkd", want: true},
+		{name: "double star zero dirs", include: []string{"/Assets/tpp/pack/**/*.fpkd"}, path: "/Assets/tpp/pack/a.fpkd", want: true},
+		{name: "double star wrong ext", include: []string{"/Assets/tpp/pack/**/*.fpkd"}, path: "/Assets/tpp/pack/a/a.fpk", want: false},
+		{name: "star does not cross dirs", include: []string{"/Assets/*.lua"}, path: "/Assets/a/b.lua", want: false},
+		{name: "no leading slash", include: []string{"Assets/*.lua"}, path: "/Assets/b.lua", want: true},
+		{name: "name pattern", include: []string{"*.ftexs"}, path: "/Assets/a/b.1.ftexs", want: true},
+		{name: "exclude only", exclude: []string{"*.ftexs"}, path: "/Assets/a/b.1.ftexs", want: false},
+		{name: "exclude only other", exclude: []string{"*.ftexs"}, path: "/Assets/a/b.ftex", want: true},
+		{name: "exclude wins", include: []string{"/Assets/**"}, exclude: []string{"*.ftexs"}, path: "/Assets/a/b.1.ftexs", want: false},
+		{name: "class", include: []string{"/Assets/[ab].lua"}, path: "/Assets/b.lua", want: true},
+		{name: "negated class", include: []string{"/Assets/[!ab].lua"}, path: "/Assets/b.lua", want: false},
+		{name: "hash", hashes: []uint64{fpkdHash}, path: "unresolved", hash: fpkdHash, want: true},
+		{name: "path hash", hashes: []uint64{hashing.PathHashFromHash(fpkdHash)}, path: "unresolved", hash: fpkdHash, want: true},
+		{name: "hash mismatch", hashes: []uint64{1}, path: "unresolved", hash: fpkdHash, want: false},
+		{name: "hash or include", include: []string{"*.lua"}, hashes: []uint64{1}, path: "/a.lua", hash: 2, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.include, tt.exclude, tt.hashes)
+			if err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			if got := f.Match(tt.path, tt.hash); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilter_BadPattern(t *testing.T) {
+	if _, err := NewFilter([]string{"/Assets/[a.lua"}, nil, nil); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	for _, s := range []string{"0x38dd243657e7f", "38DD243657E7F"} {
+		h, err := ParseHash(s)
+		if err != nil {
+			t.Fatalf("%s", err.Error())
+		}
+
+		if h != 0x38dd243657e7f {
+			t.Errorf("%s: have %x", s, h)
+		}
+	}
+}
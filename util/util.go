@@ -40,6 +40,45 @@ func AlignRead(reader io.ReadSeeker, alignment int64) (int64, error) {
 	return pos, nil
 }
 
+// Remaining returns number of bytes between current position and end of seeker, -1 on error.
+func Remaining(seeker io.Seeker) int64 {
+	cur, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+
+	if _, err = seeker.Seek(cur, io.SeekStart); err != nil {
+		return -1
+	}
+
+	return end - cur
+}
+
+// MaxAllocSize limits memory allocated at once for data which size is read from file.
+var MaxAllocSize int64 = 1 << 30
+
+// ErrTooLarge is returned when size read from file is negative, exceeds MaxAllocSize or
+// there is not enough data in stream.
+var ErrTooLarge = errors.New("size out of bounds")
+
+// CheckSize returns ErrTooLarge if size bytes cannot be allocated or read from current position of seeker.
+func CheckSize(seeker io.Seeker, size int64) error {
+	if size < 0 || size > MaxAllocSize {
+		return fmt.Errorf("%w: %d", ErrTooLarge, size)
+	}
+
+	if remaining := Remaining(seeker); size > remaining {
+		return fmt.Errorf("%w: %d, %d bytes left", ErrTooLarge, size, remaining)
+	}
+
+	return nil
+}
+
 // ByteArrayReaderWriter used for testing
 type ByteArrayReaderWriter struct {
 	data []byte
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCheckSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		wantErr bool
+	}{
+		{name: "fits", size: 6, wantErr: false},
+		{name: "empty", size: 0, wantErr: false},
+		{name: "past end", size: 7, wantErr: true},
+		{name: "negative", size: -1, wantErr: true},
+		{name: "over limit", size: MaxAllocSize + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader([]byte("0123456789"))
+			if _, err := r.Seek(4, io.SeekStart); err != nil {
+				t.Fatalf("%s", err.Error())
+			}
+
+			err := CheckSize(r, tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, ErrTooLarge) {
+				t.Errorf("want ErrTooLarge, have %v", err)
+			}
+
+			if pos, _ := r.Seek(0, io.SeekCurrent); pos != 4 {
+				t.Errorf("position changed to %d", pos)
+			}
+		})
+	}
+}